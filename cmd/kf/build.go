@@ -0,0 +1,88 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func runBuild(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("o", "", "write the schema to `file` instead of stdout")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintln(stderr, "kf: build takes a single file")
+		return 2
+	}
+
+	s, code := compileFile(fs.Arg(0), stdin, stderr)
+	if s == nil {
+		return code
+	}
+	sb := strings.Builder{}
+	enc := json.NewEncoder(&sb)
+	enc.SetIndent("", "  ")
+	// bodies are full of comparisons, which are unreadable escaped
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+	return writeOutput(*output, sb.String(), stdout, stderr)
+}
+
+// compileFile compiles a file, or stdin when the name is empty, printing the
+// diagnostics if there are any. On failure it returns the exit code.
+func compileFile(file string, stdin io.Reader, stderr io.Writer) (*lang.Schema, int) {
+	if file == "" {
+		file = "-"
+	}
+	src, err := readSource(file, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return nil, 2
+	}
+	s, diags := lang.Compile(lang.ParseFile(src.text))
+	if len(diags) > 0 {
+		errs := diagnosticPrinter{stdout: stderr}
+		errs.print(src, diags)
+		errs.flush()
+		return nil, 1
+	}
+	return s, 0
+}
+
+// writeOutput writes the result of a command to a file, or to stdout when
+// there is no file.
+func writeOutput(file string, text string, stdout io.Writer, stderr io.Writer) int {
+	if file == "" {
+		fmt.Fprint(stdout, text)
+		return 0
+	}
+	if err := os.WriteFile(file, []byte(text), 0644); err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+type jsonDiagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+func runCheck(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJson := fs.Bool("json", false, "print diagnostics as JSON")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	files, err := inputFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+
+	out := diagnosticPrinter{stdout: stdout, asJson: *asJson}
+	for _, f := range files {
+		src, err := readSource(f, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "kf: %v\n", err)
+			return 2
+		}
+		out.print(src, lang.Check(lang.ParseFile(src.text)))
+	}
+	out.flush()
+
+	if out.errors {
+		return 1
+	}
+	return 0
+}
+
+// diagnosticPrinter prints diagnostics one per line as they come, or collects
+// them into a single JSON array printed by flush.
+type diagnosticPrinter struct {
+	stdout   io.Writer
+	asJson   bool
+	all      []jsonDiagnostic
+	errors   bool
+	warnings bool
+}
+
+func (p *diagnosticPrinter) print(src source, ds []lang.Diagnostic) {
+	li := lang.NewLineIndex(src.text)
+	for _, d := range ds {
+		start := li.Position(d.Start)
+		end := li.Position(d.End)
+		jd := jsonDiagnostic{
+			File:      src.name,
+			Line:      start.Line + 1,
+			Column:    start.Character + 1,
+			EndLine:   end.Line + 1,
+			EndColumn: end.Character + 1,
+			Severity:  d.Severity.String(),
+			Code:      d.Code,
+			Message:   d.Message,
+		}
+		p.all = append(p.all, jd)
+		if d.Severity == lang.SevError {
+			p.errors = true
+		} else {
+			p.warnings = true
+		}
+		if !p.asJson {
+			fmt.Fprintf(p.stdout, "%s:%d:%d: %s: %s [%s]\n", jd.File, jd.Line, jd.Column, jd.Severity, jd.Message, jd.Code)
+		}
+	}
+}
+
+func (p *diagnosticPrinter) flush() {
+	if !p.asJson {
+		return
+	}
+	if p.all == nil {
+		p.all = []jsonDiagnostic{}
+	}
+	enc := json.NewEncoder(p.stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(p.all)
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func runDecompile(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("decompile", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("o", "", "write the source to `file` instead of stdout")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintln(stderr, "kf: decompile takes a single file")
+		return 2
+	}
+
+	file := fs.Arg(0)
+	if file == "" {
+		file = "-"
+	}
+	src, err := readSource(file, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+	s := &lang.Schema{}
+	if err := json.Unmarshal([]byte(src.text), s); err != nil {
+		fmt.Fprintf(stderr, "kf: %s: %v\n", src.name, err)
+		return 1
+	}
+	text, err := lang.Decompile(s)
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %s: %v\n", src.name, err)
+		return 1
+	}
+	return writeOutput(*output, text, stdout, stderr)
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+type jsonChange struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Breaking bool   `json:"breaking"`
+	Message  string `json:"message"`
+}
+
+func runDiff(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJson := fs.Bool("json", false, "print changes as JSON")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(stderr, "kf: diff takes the old and the new file")
+		return 2
+	}
+
+	srcs := [2]source{}
+	frs := [2]*lang.FileRoot{}
+	errs := diagnosticPrinter{stdout: stderr}
+	for i, f := range fs.Args() {
+		src, err := readSource(f, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "kf: %v\n", err)
+			return 2
+		}
+		srcs[i] = src
+		frs[i] = lang.ParseFile(src.text)
+		errs.print(src, lang.Check(frs[i]))
+	}
+	// 1 is for breaking changes, so that CI can tell them from broken files
+	if errs.errors {
+		return 2
+	}
+
+	lis := [2]*lang.LineIndex{lang.NewLineIndex(srcs[0].text), lang.NewLineIndex(srcs[1].text)}
+	changes := []jsonChange{}
+	breaking := false
+	for _, c := range lang.CompareSchemas(frs[0], frs[1]) {
+		i, n := 1, c.New
+		if n == nil {
+			i, n = 0, c.Old
+		}
+		pos := lis[i].Position(n.Start())
+		jc := jsonChange{File: srcs[i].name, Line: pos.Line + 1, Column: pos.Character + 1, Breaking: c.Breaking, Message: c.Message}
+		changes = append(changes, jc)
+		breaking = breaking || c.Breaking
+		if !*asJson {
+			kind := "compatible"
+			if c.Breaking {
+				kind = "breaking"
+			}
+			fmt.Fprintf(stdout, "%s:%d:%d: %s: %s\n", jc.File, jc.Line, jc.Column, kind, jc.Message)
+		}
+	}
+	if *asJson {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		enc.Encode(changes)
+	}
+
+	if breaking {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func runDoc(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("doc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("o", "", "write the documentation to `file` instead of stdout")
+	asHtml := fs.Bool("html", false, "generate HTML instead of Markdown")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintln(stderr, "kf: doc takes a single file")
+		return 2
+	}
+
+	file := fs.Arg(0)
+	if file == "" {
+		file = "-"
+	}
+	src, err := readSource(file, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+	format := lang.DocMarkdown
+	if *asHtml {
+		format = lang.DocHTML
+	}
+	text, diags := lang.GenerateDocs(lang.ParseFile(src.text), format)
+	if len(diags) > 0 {
+		errs := diagnosticPrinter{stdout: stderr}
+		errs.print(src, diags)
+		errs.flush()
+		return 1
+	}
+	return writeOutput(*output, text, stdout, stderr)
+}
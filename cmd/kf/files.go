@@ -0,0 +1,74 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const stdinName = "<stdin>"
+
+type source struct {
+	name string
+	text string
+}
+
+// inputFiles expands directories into the .kf files they contain. No
+// arguments means stdin, which is represented by "-".
+func inputFiles(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return []string{"-"}, nil
+	}
+	res := []string{}
+	for _, a := range args {
+		if a == "-" {
+			res = append(res, a)
+			continue
+		}
+		info, err := os.Stat(a)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			res = append(res, a)
+			continue
+		}
+		err = filepath.WalkDir(a, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".kf") {
+				res = append(res, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func readSource(file string, stdin io.Reader) (source, error) {
+	if file == "-" {
+		b, err := io.ReadAll(stdin)
+		return source{name: stdinName, text: string(b)}, err
+	}
+	b, err := os.ReadFile(file)
+	return source{name: file, text: string(b)}, err
+}
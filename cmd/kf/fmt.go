@@ -0,0 +1,85 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func runFmt(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	write := fs.Bool("w", false, "write result to the source file instead of stdout")
+	list := fs.Bool("l", false, "list files whose formatting differs")
+	diff := fs.Bool("d", false, "display diffs instead of rewriting files")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	files, err := inputFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+
+	res := 0
+	for _, f := range files {
+		src, err := readSource(f, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "kf: %v\n", err)
+			return 2
+		}
+		fr := lang.ParseFile(src.text)
+		if len(fr.SyntaxErrors()) > 0 {
+			fmt.Fprintf(stderr, "kf: %s: %v\n", src.name, lang.ErrSyntax)
+			res = 1
+			continue
+		}
+		formatted, err := lang.Format(fr, lang.DefaultFormatOptions)
+		if err != nil {
+			fmt.Fprintf(stderr, "kf: %s: %v\n", src.name, err)
+			res = 1
+			continue
+		}
+
+		changed := formatted != src.text
+		if *list && changed {
+			fmt.Fprintln(stdout, src.name)
+		}
+		if *diff && changed {
+			fmt.Fprint(stdout, unifiedDiff(src.name, src.text, formatted))
+		}
+		if *write {
+			if f == "-" {
+				fmt.Fprintln(stderr, "kf: can't use -w with stdin")
+				return 2
+			}
+			if changed {
+				if err := os.WriteFile(f, []byte(formatted), 0644); err != nil {
+					fmt.Fprintf(stderr, "kf: %v\n", err)
+					return 2
+				}
+			}
+		}
+		if !*list && !*diff && !*write {
+			fmt.Fprint(stdout, formatted)
+		}
+	}
+	return res
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func runGen(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "go" && args[0] != "ts" {
+		fmt.Fprintln(stderr, "kf: gen takes the language to generate: go or ts")
+		return 2
+	}
+	language := args[0]
+
+	fs := flag.NewFlagSet("gen "+language, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("o", "", "write the code to `file` instead of stdout")
+	pkg := new(string)
+	if language == "go" {
+		pkg = fs.String("package", "", "the `name` of the Go package, the database name by default")
+	}
+	if fs.Parse(args[1:]) != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintln(stderr, "kf: gen takes a single file")
+		return 2
+	}
+
+	b, code := bindFile(fs.Arg(0), stdin, stderr)
+	if b == nil {
+		return code
+	}
+	if language == "ts" {
+		return writeOutput(*output, lang.GenerateTypeScript(b), stdout, stderr)
+	}
+	if *pkg == "" {
+		*pkg = lang.GoPackageName(b.Name)
+	}
+	text, err := lang.GenerateGo(b, *pkg)
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+	return writeOutput(*output, text, stdout, stderr)
+}
+
+// bindFile returns the bindings of a file, or of stdin when the name is
+// empty, printing the diagnostics if there are any. On failure it returns the
+// exit code.
+func bindFile(file string, stdin io.Reader, stderr io.Writer) (*lang.Bindings, int) {
+	if file == "" {
+		file = "-"
+	}
+	src, err := readSource(file, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return nil, 2
+	}
+	b, diags := lang.NewBindings(lang.ParseFile(src.text))
+	if len(diags) > 0 {
+		errs := diagnosticPrinter{stdout: stderr}
+		errs.print(src, diags)
+		errs.flush()
+		return nil, 1
+	}
+	return b, 0
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func runLint(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJson := fs.Bool("json", false, "print diagnostics as JSON")
+	config := fs.String("config", "", "read the configuration from `file` instead of the nearest "+lang.LintConfigFile)
+	rules := fs.Bool("rules", false, "list the rules and exit")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	if *rules {
+		for _, r := range lang.LintRules {
+			fmt.Fprintf(stdout, "%-18s %s\n", r.Name, r.Description)
+		}
+		return 0
+	}
+
+	files, err := inputFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+
+	var cfg *lang.LintConfig
+	if *config != "" {
+		c, err := readLintConfig(*config)
+		if err != nil {
+			fmt.Fprintf(stderr, "kf: %v\n", err)
+			return 2
+		}
+		cfg = &c
+	}
+
+	out := diagnosticPrinter{stdout: stdout, asJson: *asJson}
+	for _, f := range files {
+		src, err := readSource(f, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "kf: %v\n", err)
+			return 2
+		}
+		c := cfg
+		if c == nil {
+			dir := "."
+			if f != "-" {
+				dir = filepath.Dir(f)
+			}
+			found, err := findLintConfig(dir)
+			if err != nil {
+				fmt.Fprintf(stderr, "kf: %v\n", err)
+				return 2
+			}
+			c = &found
+		}
+		out.print(src, lang.Lint(lang.ParseFile(src.text), *c))
+	}
+	out.flush()
+
+	if out.errors || out.warnings {
+		return 1
+	}
+	return 0
+}
+
+func readLintConfig(path string) (lang.LintConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return lang.LintConfig{}, err
+	}
+	cfg, err := lang.ParseLintConfig(b)
+	if err != nil {
+		return lang.LintConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// findLintConfig reads the configuration file in dir or the closest of its
+// parents. All rules are enabled if there is none.
+func findLintConfig(dir string) (lang.LintConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return lang.LintConfig{}, err
+	}
+	for {
+		cfg, err := readLintConfig(filepath.Join(dir, lang.LintConfigFile))
+		if !errors.Is(err, fs.ErrNotExist) {
+			return cfg, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return lang.LintConfig{}, nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `kf is a tool for Kuneiform schemas.
+
+Usage:
+
+	kf <command> [arguments] [files or directories]
+
+The commands are:
+
+	build     compile a file to a JSON schema for deployment
+	check     report syntax and semantic errors
+	decompile convert a JSON schema back to source
+	diff      compare two versions of a schema, failing on breaking changes
+	doc       generate Markdown or HTML documentation from doc comments
+	fmt       format files
+	gen       generate typed client code, see kf gen go and kf gen ts
+	lint      report likely mistakes, see kf lint -rules
+	parse     parse files and print their syntax trees
+	run       call an action or procedure against an in-memory SQLite database
+	sql       print the PostgreSQL statements creating the tables and functions
+
+Files are read from stdin when none are given or when the name is "-".
+`
+
+type command func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int
+
+var commands = map[string]command{
+	"build":     runBuild,
+	"check":     runCheck,
+	"decompile": runDecompile,
+	"diff":      runDiff,
+	"doc":       runDoc,
+	"fmt":       runFmt,
+	"gen":       runGen,
+	"lint":      runLint,
+	"parse":     runParse,
+	"run":       runRun,
+	"sql":       runSql,
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "kf: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	return cmd(args[1:], stdin, stdout, stderr)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
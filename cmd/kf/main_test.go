@@ -0,0 +1,299 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runKf(stdin string, args ...string) (int, string, string) {
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, dir string, name string, text string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte(text), 0644))
+	return path
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "ok.kf", "database d;\ntable t { a int }\n")
+	bad := writeFile(t, dir, "bad.kf", "database d;\naction a() { SELECT * FROM t; }\n")
+	writeFile(t, dir, "notes.txt", "not kuneiform")
+
+	code, out, _ := runKf("", "check", dir)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, bad+":2:28: error: unknown table 't' [unknown-table]\n", out)
+
+	code, out, _ = runKf("database d;", "check", "-json")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "[]\n", out)
+}
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "a.kf", "database d;\ntable t { a int }\n")
+
+	code, out, _ := runKf("", "lint", dir)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, path+":2:7: warning: table 't' has no primary key [no-primary-key]\n", out)
+
+	writeFile(t, dir, "kflint.json", `{"rules": {"no-primary-key": false}}`)
+	code, out, _ = runKf("", "lint", path)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "", out)
+
+	code, _, errOut := runKf("", "lint", "-config", writeFile(t, dir, "bad.json", `{"rules": {"x": true}}`), path)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, `unknown lint rule "x"`)
+
+	code, out, _ = runKf("", "lint", "-rules")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "select-star")
+}
+
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "a.kf", "database d;table t {a int}")
+
+	code, out, _ := runKf("", "fmt", "-l", dir)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, path+"\n", out)
+
+	code, out, _ = runKf("", "fmt", "-d", path)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "-database d;table t {a int}\n+database d;\n+\n+table t {\n")
+
+	code, _, _ = runKf("", "fmt", "-w", path)
+	assert.Equal(t, 0, code)
+	b, _ := os.ReadFile(path)
+	assert.Equal(t, "database d;\n\ntable t {\n    a int\n}\n", string(b))
+
+	code, out, _ = runKf("", "fmt", "-l", dir)
+	assert.Equal(t, 0, code)
+	assert.Empty(t, out)
+
+	code, _, errOut := runKf("database d; table t {", "fmt")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "syntax errors")
+}
+
+func TestParseDump(t *testing.T) {
+	code, out, _ := runKf("database d;", "parse", "--dump")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "FileRoot@0..11\n  DbDirective@0..11\n    DATABASE@0..8 \"database\"\n    WS@8..9 \" \"\n    ID@9..10 \"d\"\n    SEMICOLON@10..11 \";\"\n", out)
+
+	code, out, _ = runKf("database d;", "parse", "-json")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, `"kind": "DbDirective"`)
+}
+
+func TestUnknownCommand(t *testing.T) {
+	code, _, errOut := runKf("", "frobnicate")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "unknown command")
+}
+
+func TestSql(t *testing.T) {
+	code, out, _ := runKf("database d;\ntable t { id int primary }\n", "sql")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "CREATE SCHEMA IF NOT EXISTS d;\n\nCREATE TABLE d.t (\n    id INT8 PRIMARY KEY\n);\n", out)
+
+	dir := t.TempDir()
+	path := writeFile(t, dir, "a.kf", "database d;\ntable t { at timestamp }\n")
+	code, out, errOut := runKf("", "sql", path)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "", out)
+	assert.Equal(t, path+":2:14: error: unknown type 'timestamp' [unsupported-type]\n", errOut)
+
+	code, out, errOut = runKf("database d;\ntable t { id int primary }\naction a() public { INSERT INTO t (id) VALUES (@nonce); }\n", "sql")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "CREATE SCHEMA IF NOT EXISTS d;\n\nCREATE TABLE d.t (\n    id INT8 PRIMARY KEY\n);\n", out)
+	assert.Equal(t, "<stdin>:3:48: error: unknown context variable '@nonce' [unknown-context-variable]\n", errOut)
+
+	output := filepath.Join(dir, "schema.sql")
+	code, _, _ = runKf("database d;\ntable t { id int primary }\n", "sql", "-o", output)
+	assert.Equal(t, 0, code)
+	b, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "CREATE TABLE d.t (")
+}
+
+func TestBuild(t *testing.T) {
+	code, out, _ := runKf("database d;\ntable t { id int primary }\naction a() public view { SELECT id FROM t WHERE id > 1; }\n", "build")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, `"name": "d",`)
+	assert.Contains(t, out, `"body": "SELECT id FROM t WHERE id > 1;"`)
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "schema.json")
+	code, out, _ = runKf("database d;\ntable t { id int primary }\n", "build", "-o", output)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "", out)
+	b, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"tables": [`)
+
+	code, _, errOut := runKf("database d;\ntable t { at timestamp }\n", "build")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "<stdin>:2:14: error: unknown type 'timestamp' [unsupported-type]\n", errOut)
+
+	code, _, errOut = runKf("", "build", "a.kf", "b.kf")
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "kf: build takes a single file\n", errOut)
+}
+
+func TestDecompile(t *testing.T) {
+	_, schema, _ := runKf("database d;\ntable t { id int primary }\naction a() public view { SELECT id FROM t; }\n", "build")
+	code, out, _ := runKf(schema, "decompile")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "database d;\n\ntable t {\n    id int primary\n}\n\naction a() public view {\n    SELECT id FROM t;\n}\n", out)
+
+	code, _, errOut := runKf(`{"name": "d", "tables": [{"name": "t", "columns": [{"name": "c", "type": {"name": "float"}}]}]}`, "decompile")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "kf: <stdin>: t: unknown type \"float\"\n", errOut)
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	old := writeFile(t, dir, "old.kf", "database d;\ntable t {\n  id int primary,\n  name text\n}\naction a() public view { SELECT id FROM t; }\n")
+	added := writeFile(t, dir, "added.kf", "database d;\ntable t {\n  id int primary,\n  name text,\n  age int\n}\naction a() public view { SELECT id FROM t; }\n")
+	removed := writeFile(t, dir, "removed.kf", "database d;\ntable t {\n  id int primary\n}\naction a() private view { SELECT id FROM t; }\n")
+
+	code, out, _ := runKf("", "diff", old, added)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, added+":5:3: compatible: column 't.age' added\n", out)
+
+	code, out, _ = runKf("", "diff", old, removed)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, old+":4:3: breaking: column 't.name' removed\n"+removed+":5:1: breaking: action 'a' is now private\n", out)
+
+	code, out, _ = runKf("", "diff", "-json", old, added)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, `"breaking": false,`)
+
+	code, _, errOut := runKf("", "diff", old)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "kf: diff takes the old and the new file\n", errOut)
+
+	invalid := writeFile(t, dir, "invalid.kf", "database d;\naction a() public { SELECT * FROM missing; }\n")
+	code, _, errOut = runKf("", "diff", old, invalid)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "unknown table 'missing'")
+}
+
+func TestGen(t *testing.T) {
+	text := "database my_db;\ntable t { id int primary }\naction get($id) public view { SELECT * FROM t WHERE id = $id; }\n"
+	code, out, _ := runKf(text, "gen", "go")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "package mydb\n")
+	assert.Contains(t, out, "func (c *Client) Get(ctx context.Context, id int64) ([]TRow, error) {")
+
+	code, out, _ = runKf(text, "gen", "go", "-package", "client")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "package client\n")
+
+	code, _, errOut := runKf("database d;\ntable t { id float }\n", "gen", "go")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "<stdin>:2:14: error: unknown type 'float' [unsupported-type]\n", errOut)
+
+	code, _, errOut = runKf(text, "gen", "rust")
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "kf: gen takes the language to generate: go or ts\n", errOut)
+
+	code, out, _ = runKf(text, "gen", "ts")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "export type GetRow = TRow;\n")
+	assert.Contains(t, out, "export type ActionName = \"get\";\n")
+
+	code, _, _ = runKf(text, "gen", "ts", "-package", "client")
+	assert.Equal(t, 2, code)
+}
+
+func TestDoc(t *testing.T) {
+	text := "database d;\n// Things.\ntable t { id int primary }\n"
+	code, out, _ := runKf(text, "doc")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "### t\n\nThings.\n")
+
+	code, out, _ = runKf(text, "doc", "-html")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "<h3 id=\"table-t\">t</h3>\n<p>Things.</p>\n")
+
+	code, _, errOut := runKf("database d;\naction a() public { SELECT * FROM t; }\n", "doc")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "unknown table 't'")
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "a.kf", `database d;
+table notes { id int primary, author text, body text notnull }
+action add($id, $body) public {
+	if length($body) > 5 {
+		error('too long');
+	}
+	INSERT INTO notes VALUES ($id, @caller, $body);
+}
+action notes() public view {
+	SELECT * FROM notes ORDER BY id;
+}
+action div($a, $b) public view {
+	SELECT $a / $b AS q;
+}
+`)
+	state := filepath.Join(dir, "state.json")
+
+	code, out, errOut := runKf("", "run", "-state", state, "-caller", "alice", file, "add", "1", "hello")
+	assert.Equal(t, 0, code, errOut)
+	assert.Equal(t, "", out)
+	code, _, errOut = runKf("", "run", "-state", state, "-caller", "bob", file, "add", "2", "12")
+	assert.Equal(t, 0, code, errOut)
+
+	code, out, _ = runKf("", "run", "-state", state, file, "notes")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "id  author  body\n1   alice   hello\n2   bob     12\n", out)
+	code, out, _ = runKf("", "run", "-json", "-state", state, file, "notes")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "\"rows\": [\n    [\n      1,\n      \"alice\",\n      \"hello\"\n    ],")
+
+	code, _, errOut = runKf("", "run", "-state", state, file, "add", "3", "goodbye")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, file+":5:3: error: too long\n", errOut)
+	code, _, errOut = runKf("", "run", "-state", state, file, "add", "1", "null")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "null value in column 'body'")
+
+	code, out, errOut = runKf("", "run", file, "div", "--args", "7", "2")
+	assert.Equal(t, 0, code, errOut)
+	assert.Equal(t, "q\n3\n", out)
+	code, _, errOut = runKf("", "run", file, "add", "--args", "4", `"null"`)
+	assert.Equal(t, 0, code, errOut)
+
+	code, _, errOut = runKf("", "run", file, "missing")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "kf: unknown action or procedure 'missing'\n", errOut)
+	code, _, _ = runKf("", "run", file)
+	assert.Equal(t, 2, code)
+}
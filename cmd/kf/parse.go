@@ -0,0 +1,69 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func runParse(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("parse", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dump := fs.Bool("dump", false, "print the syntax tree")
+	asJson := fs.Bool("json", false, "print the syntax tree as JSON")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	files, err := inputFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+
+	res := 0
+	for _, f := range files {
+		src, err := readSource(f, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "kf: %v\n", err)
+			return 2
+		}
+		fr := lang.ParseFile(src.text)
+		if *dump {
+			if len(files) > 1 {
+				fmt.Fprintf(stdout, "# %s\n", src.name)
+			}
+			fmt.Fprint(stdout, lang.Dump(fr))
+		}
+		if *asJson {
+			b, err := lang.DumpJSON(fr)
+			if err != nil {
+				fmt.Fprintf(stderr, "kf: %v\n", err)
+				return 2
+			}
+			fmt.Fprintln(stdout, string(b))
+		}
+		li := lang.NewLineIndex(src.text)
+		for _, d := range fr.SyntaxErrors() {
+			p := li.Position(d.Start)
+			fmt.Fprintf(stderr, "%s:%d:%d: %s\n", src.name, p.Line+1, p.Character+1, d.Message)
+			res = 1
+		}
+	}
+	return res
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func runRun(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJson := fs.Bool("json", false, "print the result as JSON")
+	state := fs.String("state", "", "load the tables from `file` and save them back after the call")
+	owner := fs.String("owner", "", "the `address` of the owner of the database")
+	cc := lang.CallContext{}
+	fs.StringVar(&cc.Caller, "caller", "", "the `address` of the caller")
+	fs.StringVar(&cc.TxID, "txid", "", "the `id` of the transaction")
+	fs.Int64Var(&cc.Height, "height", 0, "the block `height`")
+	fs.Int64Var(&cc.BlockTimestamp, "timestamp", 0, "the block timestamp in `seconds`")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+	if fs.NArg() < 2 {
+		fmt.Fprintln(stderr, "kf: run takes a file, an action or procedure and its arguments")
+		return 2
+	}
+	if *owner == "" {
+		*owner = cc.Caller
+	}
+
+	src, err := readSource(fs.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+	fr := lang.ParseFile(src.text)
+	in, diags := lang.NewInterpreter(fr)
+	if len(diags) > 0 {
+		errs := diagnosticPrinter{stdout: stderr}
+		errs.print(src, diags)
+		errs.flush()
+		return 1
+	}
+	defer in.Close()
+	in.Owner = *owner
+	if *state != "" {
+		if err := loadState(in, *state); err != nil {
+			fmt.Fprintf(stderr, "kf: %v\n", err)
+			return 1
+		}
+	}
+
+	name := fs.Arg(1)
+	var params []*lang.BoundField
+	if b, _ := lang.NewBindings(fr); b != nil {
+		for _, a := range b.Actions {
+			if strings.EqualFold(a.Name, name) {
+				params = a.Params
+			}
+		}
+	}
+	callArgs := fs.Args()[2:]
+	if len(callArgs) > 0 && (callArgs[0] == "--args" || callArgs[0] == "-args") {
+		callArgs = callArgs[1:]
+	}
+	values := []any{}
+	for i, a := range callArgs {
+		var typ *lang.DataType
+		if i < len(params) && !params[i].Guessed {
+			typ = params[i].Type
+		}
+		values = append(values, parseArg(a, typ))
+	}
+
+	res, err := in.Call(cc, name, values...)
+	var re *lang.RunError
+	if errors.As(err, &re) {
+		pos := lang.NewLineIndex(src.text).Position(re.Start)
+		fmt.Fprintf(stderr, "%s:%d:%d: error: %s\n", src.name, pos.Line+1, pos.Character+1, re.Message)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 1
+	}
+	if *state != "" {
+		if err := saveState(in, *state); err != nil {
+			fmt.Fprintf(stderr, "kf: %v\n", err)
+			return 1
+		}
+	}
+
+	if *asJson {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		enc.Encode(res)
+		return 0
+	}
+	if len(res.Columns) > 0 {
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(res.Columns, "\t"))
+		for _, row := range res.Rows {
+			values := []string{}
+			for _, v := range row {
+				values = append(values, lang.FormatValue(v))
+			}
+			fmt.Fprintln(tw, strings.Join(values, "\t"))
+		}
+		tw.Flush()
+	}
+	return 0
+}
+
+// parseArg converts an argument of the command line to a value. Arguments of
+// text parameters are taken as they are unless they are JSON strings, so that
+// '"null"' is the text null rather than null. The others, including those of
+// parameters with unknown types, are read as JSON when they can be, so that 1
+// is a number and ["a", "b"] an array.
+func parseArg(arg string, typ *lang.DataType) any {
+	if arg == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(strings.NewReader(arg))
+	dec.UseNumber()
+	var v any
+	if dec.Decode(&v) != nil || dec.More() {
+		return arg
+	}
+	if typ != nil && !typ.IsArray && (typ.Name == "text" || typ.Name == "uuid") {
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return arg
+	}
+	return v
+}
+
+func loadState(in *lang.Interpreter, file string) error {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	state := map[string][][]any{}
+	if err := dec.Decode(&state); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	if err := in.SetState(state); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	return nil
+}
+
+func saveState(in *lang.Interpreter, file string) error {
+	state, err := in.State()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(b, '\n'), 0644)
+}
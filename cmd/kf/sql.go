@@ -0,0 +1,68 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func runSql(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("sql", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("o", "", "write the SQL to `file` instead of stdout")
+	if fs.Parse(args) != nil {
+		return 2
+	}
+
+	files, err := inputFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "kf: %v\n", err)
+		return 2
+	}
+
+	sb := strings.Builder{}
+	errs := diagnosticPrinter{stdout: stderr}
+	for _, f := range files {
+		src, err := readSource(f, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "kf: %v\n", err)
+			return 2
+		}
+		sql, diags := lang.GenerateSQL(lang.ParseFile(src.text))
+		errs.print(src, diags)
+		if sql == "" {
+			continue
+		}
+		if len(files) > 1 {
+			if sb.Len() > 0 {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "-- %s\n", src.name)
+		}
+		sb.WriteString(sql)
+	}
+	errs.flush()
+	// The statements generated in spite of errors are written too, the errors
+	// only change the exit code.
+	res := writeOutput(*output, sb.String(), stdout, stderr)
+	if res == 0 && errs.errors {
+		return 1
+	}
+	return res
+}
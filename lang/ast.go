@@ -13,8 +13,14 @@
 // limitations under the License.
 package lang
 
+import (
+	"slices"
+	"strings"
+)
+
 type FileRoot struct {
 	compNode
+	errors []Diagnostic
 }
 
 type DbDirective struct {
@@ -25,18 +31,73 @@ type ExtDirective struct {
 	compNode
 }
 
+type ExtParam struct {
+	compNode
+}
+
 type TableDecl struct {
 	compNode
+	docComment
+}
+
+type ColumnDecl struct {
+	compNode
+	docComment
+}
+
+type TypeRef struct {
+	compNode
+}
+
+type ColumnAttr struct {
+	compNode
+}
+
+type IndexDecl struct {
+	compNode
+}
+
+type ForeignKeyDecl struct {
+	compNode
+}
+
+type FkAction struct {
+	compNode
+}
+
+type ColumnList struct {
+	compNode
 }
 
 type ActionDecl struct {
 	compNode
+	docComment
 }
 
 type ParamDecl struct {
 	compNode
 }
 
+type Annotation struct {
+	compNode
+}
+
+type ReturnsClause struct {
+	compNode
+}
+
+type ReturnColumn struct {
+	compNode
+}
+
+type Block struct {
+	compNode
+}
+
+type ErrorNode struct {
+	compNode
+}
+
 type Stmt interface {
 	AstNode
 	IsStmt()
@@ -46,6 +107,74 @@ type AssignStmt struct {
 	compNode
 }
 
+type SelectStmt struct {
+	compNode
+}
+
+type InsertStmt struct {
+	compNode
+}
+
+type UpdateStmt struct {
+	compNode
+}
+
+type DeleteStmt struct {
+	compNode
+}
+
+type ReturnStmt struct {
+	compNode
+}
+
+type IfStmt struct {
+	compNode
+}
+
+type ForStmt struct {
+	compNode
+}
+
+type CallStmt struct {
+	compNode
+}
+
+type ResultColumn struct {
+	compNode
+}
+
+type TableRef struct {
+	compNode
+}
+
+type JoinClause struct {
+	compNode
+}
+
+type WhereClause struct {
+	compNode
+}
+
+type OrderClause struct {
+	compNode
+}
+
+type LimitClause struct {
+	compNode
+}
+
+type ValuesRow struct {
+	compNode
+}
+
+type OnConflictClause struct {
+	compNode
+}
+
+type SetItem struct {
+	compNode
+}
+
 type Expr interface {
 	AstNode
 	IsExpr()
@@ -63,6 +192,72 @@ type IntLitExpr struct {
 	compNode
 }
 
+type StringLitExpr struct {
+	compNode
+}
+
+type BoolLitExpr struct {
+	compNode
+}
+
+type NullLitExpr struct {
+	compNode
+}
+
+type CtxVarExpr struct {
+	compNode
+}
+
+type ColumnExpr struct {
+	compNode
+}
+
+type CallExpr struct {
+	compNode
+}
+
+type ParenExpr struct {
+	compNode
+}
+
+type UnaryExpr struct {
+	compNode
+}
+
+type IsNullExpr struct {
+	compNode
+}
+
+// docComment holds the comments right before a declaration. They aren't its
+// children, so the tree builder sets them, see leadingComments.
+type docComment struct {
+	comments []*TokNode
+}
+
+func (dc *docComment) setComments(comments []*TokNode) {
+	dc.comments = comments
+}
+
+// DocComment returns the text of the comments right before a declaration,
+// without the comment markers.
+func (dc *docComment) DocComment() string {
+	lines := []string{}
+	for _, c := range dc.comments {
+		text := c.tok.text
+		if rest, ok := strings.CutPrefix(text, "//"); ok {
+			lines = append(lines, strings.TrimPrefix(rest, " "))
+			continue
+		}
+		text = strings.TrimSuffix(strings.TrimLeft(strings.TrimPrefix(text, "/*"), "*"), "*/")
+		for _, l := range strings.Split(text, "\n") {
+			l = strings.TrimSpace(l)
+			l = strings.TrimPrefix(strings.TrimPrefix(l, "*"), " ")
+			lines = append(lines, l)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
 func (fr *FileRoot) DbDirective() *DbDirective {
 	for _, c := range fr.Children() {
 		r, ok := c.(*DbDirective)
@@ -85,6 +280,28 @@ func (fr *FileRoot) ActionDecls() []*ActionDecl {
 	return typedChildren[*ActionDecl](fr.Children())
 }
 
+func (fr *FileRoot) SyntaxErrors() []Diagnostic {
+	return fr.errors
+}
+
+func (fr *FileRoot) TableDecl(name string) *TableDecl {
+	for _, td := range fr.TableDecls() {
+		if strings.EqualFold(td.Name(), name) {
+			return td
+		}
+	}
+	return nil
+}
+
+func (fr *FileRoot) ActionDecl(name string) *ActionDecl {
+	for _, ad := range fr.ActionDecls() {
+		if strings.EqualFold(ad.Name(), name) {
+			return ad
+		}
+	}
+	return nil
+}
+
 func (dd *DbDirective) Name() string {
 	return idText(dd.Children())
 }
@@ -93,121 +310,941 @@ func (ed *ExtDirective) Name() string {
 	return idText(ed.Children())
 }
 
+func (ed *ExtDirective) Params() []*ExtParam {
+	return typedChildren[*ExtParam](ed.Children())
+}
+
+func (ed *ExtDirective) Alias() string {
+	return tokTextAfter(ed.Children(), T_AS, T_ID)
+}
+
+func (ep *ExtParam) Name() string {
+	return idText(ep.Children())
+}
+
+func (ep *ExtParam) Value() *Expr {
+	return firstExpr(ep.Children())
+}
+
 func (td *TableDecl) Name() string {
 	return idText(td.Children())
 }
 
-func (ad *ActionDecl) Name() string {
-	return idText(ad.Children())
+func (td *TableDecl) Columns() []*ColumnDecl {
+	return typedChildren[*ColumnDecl](td.Children())
 }
 
-func (ad *ActionDecl) Params() []*ParamDecl {
-	return typedChildren[*ParamDecl](ad.Children())
+func (td *TableDecl) Column(name string) *ColumnDecl {
+	for _, cd := range td.Columns() {
+		if strings.EqualFold(cd.Name(), name) {
+			return cd
+		}
+	}
+	return nil
 }
 
-func (ad *ActionDecl) Stmts() []Stmt {
-	return typedChildren[Stmt](ad.Children())
+func (td *TableDecl) Indexes() []*IndexDecl {
+	return typedChildren[*IndexDecl](td.Children())
 }
 
-func (pd *ParamDecl) Name() string {
-	return "$" + idText(pd.Children())
+func (td *TableDecl) ForeignKeys() []*ForeignKeyDecl {
+	return typedChildren[*ForeignKeyDecl](td.Children())
 }
 
-func (as *AssignStmt) IsStmt() {}
+func (cd *ColumnDecl) Name() string {
+	return idText(cd.Children())
+}
 
-func (as *AssignStmt) Expr() *Expr {
-	exprs := typedChildren[Expr](as.Children())
-	if len(exprs) == 0 {
+func (cd *ColumnDecl) Type() *TypeRef {
+	ts := typedChildren[*TypeRef](cd.Children())
+	if len(ts) == 0 {
 		return nil
 	} else {
-		return &exprs[0]
+		return ts[0]
 	}
 }
 
-func (ve *VarExpr) IsExpr() {}
+func (cd *ColumnDecl) Attrs() []*ColumnAttr {
+	return typedChildren[*ColumnAttr](cd.Children())
+}
 
-func (ve *VarExpr) VarName() string {
-	return "$" + idText(ve.Children())
+func (cd *ColumnDecl) Attr(name string) *ColumnAttr {
+	for _, a := range cd.Attrs() {
+		if a.Name() == name {
+			return a
+		}
+	}
+	return nil
 }
 
-func (be *BinExpr) IsExpr() {}
+func (cd *ColumnDecl) HasAttr(name string) bool {
+	return cd.Attr(name) != nil
+}
 
-func (be *BinExpr) Left() *Expr {
-	exprs := typedChildren[Expr](be.Children())
-	if len(exprs) > 0 {
-		return &exprs[0]
-	} else {
-		return nil
-	}
+func (tr *TypeRef) Name() string {
+	return strings.ToLower(idText(tr.Children()))
 }
 
-func (be *BinExpr) Right() *Expr {
-	exprs := typedChildren[Expr](be.Children())
-	if len(exprs) > 1 {
-		return &exprs[1]
-	} else {
-		return nil
+func (tr *TypeRef) IsArray() bool {
+	return findTok(tr.Children(), T_LBRACKET) != nil
+}
+
+// Args returns the numbers in parentheses after the type name, e.g. the
+// precision and scale of decimal(10, 2).
+func (tr *TypeRef) Args() []string {
+	res := []string{}
+	for _, c := range tr.Children() {
+		t, ok := c.(*TokNode)
+		if ok && t.tok.kind == T_NUM {
+			res = append(res, t.tok.text)
+		}
 	}
+	return res
 }
 
-func (il *IntLitExpr) IsExpr() {}
+// Name returns the lowercased attribute name, so that e.g. NOTNULL and notnull are the same attribute.
+func (ca *ColumnAttr) Name() string {
+	return strings.ToLower(idText(ca.Children()))
+}
 
-func NewFileRoot(ns []AstNode) *FileRoot {
-	return &FileRoot{
-		compNode: *newComp(ns),
-	}
+func (ca *ColumnAttr) Arg() *Expr {
+	return firstExpr(ca.Children())
 }
 
-func NewDbDirective(ns []AstNode) *DbDirective {
-	return &DbDirective{
-		compNode: *newComp(ns),
-	}
+func (id *IndexDecl) Name() string {
+	return idText(id.Children())
 }
 
-func NewExtDirective(ns []AstNode) *ExtDirective {
-	return &ExtDirective{
-		compNode: *newComp(ns),
-	}
+func (id *IndexDecl) Kind() string {
+	return strings.ToLower(tokTextAfter(id.Children(), T_ID, T_ID))
 }
 
-func NewTableDecl(ns []AstNode) *TableDecl {
-	return &TableDecl{
-		compNode: *newComp(ns),
+func (id *IndexDecl) Columns() []string {
+	return columnListNames(id.Children(), 0)
+}
+
+func (fk *ForeignKeyDecl) Columns() []string {
+	return columnListNames(fk.Children(), 0)
+}
+
+func (fk *ForeignKeyDecl) RefTable() string {
+	ns := fk.Children()
+	for i, c := range ns {
+		t, ok := c.(*TokNode)
+		if ok && (t.tok.kind == T_REFERENCES || t.tok.kind == T_ID && strings.EqualFold(t.tok.text, "ref")) {
+			return idText(ns[i+1:])
+		}
 	}
+	return ""
 }
 
-func NewActionDecl(ns []AstNode) *ActionDecl {
-	return &ActionDecl{
-		compNode: *newComp(ns),
+func (fk *ForeignKeyDecl) RefColumns() []string {
+	return columnListNames(fk.Children(), 1)
+}
+
+func (fk *ForeignKeyDecl) Actions() []*FkAction {
+	return typedChildren[*FkAction](fk.Children())
+}
+
+func (fa *FkAction) Event() string {
+	return strings.ToLower(idText(fa.Children()))
+}
+
+func (fa *FkAction) Action() string {
+	return strings.ToLower(tokTextAfter(fa.Children(), T_ID, T_ID))
+}
+
+func (cl *ColumnList) Names() []string {
+	res := []string{}
+	for _, t := range cl.Ids() {
+		res = append(res, t.Text())
 	}
+	return res
 }
 
-func NewParamDecl(ns []AstNode) *ParamDecl {
-	return &ParamDecl{
-		compNode: *newComp(ns),
+func (cl *ColumnList) Ids() []*TokNode {
+	res := []*TokNode{}
+	for _, c := range cl.Children() {
+		t, ok := c.(*TokNode)
+		if ok && t.tok.kind == T_ID {
+			res = append(res, t)
+		}
 	}
+	return res
 }
 
-func NewAssignStmt(ns []AstNode) *AssignStmt {
-	return &AssignStmt{
-		compNode: *newComp(ns),
+func (ad *ActionDecl) Name() string {
+	return idText(ad.Children())
+}
+
+func (ad *ActionDecl) Params() []*ParamDecl {
+	return typedChildren[*ParamDecl](ad.Children())
+}
+
+func (ad *ActionDecl) IsProcedure() bool {
+	return findTok(ad.Children(), T_PROCEDURE) != nil
+}
+
+// Kind returns "action" or "procedure" for use in messages.
+func (ad *ActionDecl) Kind() string {
+	if ad.IsProcedure() {
+		return "procedure"
 	}
+	return "action"
 }
 
-func NewVarExpr(ns []AstNode) *VarExpr {
-	return &VarExpr{
-		compNode: *newComp(ns),
+func (ad *ActionDecl) Annotations() []*Annotation {
+	return typedChildren[*Annotation](ad.Children())
+}
+
+func (ad *ActionDecl) Returns() *ReturnsClause {
+	return firstTyped[*ReturnsClause](ad.Children())
+}
+
+func (ad *ActionDecl) Stmts() []Stmt {
+	return typedChildren[Stmt](ad.Children())
+}
+
+// AllStmts returns the statements of the body including the ones nested in
+// if and for blocks, in source order.
+func (ad *ActionDecl) AllStmts() []Stmt {
+	res := []Stmt{}
+	for _, c := range ad.Children() {
+		Walk(c, func(n AstNode) bool {
+			if s, ok := n.(Stmt); ok {
+				res = append(res, s)
+			}
+			return true
+		})
 	}
+	return res
 }
 
-func NewBinExpr(ns []AstNode) *BinExpr {
-	return &BinExpr{
-		compNode: *newComp(ns),
+func (ad *ActionDecl) Modifiers() []TokKind {
+	res := []TokKind{}
+	for _, c := range ad.Children() {
+		t, ok := c.(*TokNode)
+		if ok && isActionModifier(t.tok.kind) {
+			res = append(res, t.tok.kind)
+		}
 	}
+	return res
 }
 
-func NewIntLitExpr(ns []AstNode) *IntLitExpr {
-	return &IntLitExpr{
+func (ad *ActionDecl) HasModifier(k TokKind) bool {
+	return slices.Contains(ad.Modifiers(), k)
+}
+
+func (pd *ParamDecl) Name() string {
+	return "$" + idText(pd.Children())
+}
+
+func (pd *ParamDecl) Type() *TypeRef {
+	return firstTyped[*TypeRef](pd.Children())
+}
+
+func (an *Annotation) Name() string {
+	return idText(an.Children())
+}
+
+// String returns the annotation without the @ in its canonical form, e.g.
+// kgw(authn='true'), which is how schemas store it.
+func (an *Annotation) String() string {
+	return strings.TrimPrefix(inlineText(an), "@")
+}
+
+func (rc *ReturnsClause) IsTable() bool {
+	return findTok(rc.Children(), T_TABLE) != nil
+}
+
+func (rc *ReturnsClause) Columns() []*ReturnColumn {
+	return typedChildren[*ReturnColumn](rc.Children())
+}
+
+// Name returns the column name, or an empty string if only the type is given.
+func (rc *ReturnColumn) Name() string {
+	return idText(rc.Children())
+}
+
+func (rc *ReturnColumn) Type() *TypeRef {
+	return firstTyped[*TypeRef](rc.Children())
+}
+
+func (b *Block) Stmts() []Stmt {
+	return typedChildren[Stmt](b.Children())
+}
+
+func (as *AssignStmt) IsStmt() {}
+
+func (as *AssignStmt) VarName() string {
+	return "$" + idText(as.Children())
+}
+
+func (as *AssignStmt) Type() *TypeRef {
+	return firstTyped[*TypeRef](as.Children())
+}
+
+func (as *AssignStmt) Expr() *Expr {
+	exprs := typedChildren[Expr](as.Children())
+	if len(exprs) == 0 {
+		return nil
+	} else {
+		return &exprs[0]
+	}
+}
+
+func (ss *SelectStmt) IsStmt() {}
+
+func (ss *SelectStmt) ResultColumns() []*ResultColumn {
+	return typedChildren[*ResultColumn](ss.Children())
+}
+
+func (ss *SelectStmt) From() *TableRef {
+	return firstTyped[*TableRef](ss.Children())
+}
+
+func (ss *SelectStmt) Joins() []*JoinClause {
+	return typedChildren[*JoinClause](ss.Children())
+}
+
+// TableRefs returns the FROM table followed by the joined ones.
+func (ss *SelectStmt) TableRefs() []*TableRef {
+	res := typedChildren[*TableRef](ss.Children())
+	for _, j := range ss.Joins() {
+		if j.Table() != nil {
+			res = append(res, j.Table())
+		}
+	}
+	return res
+}
+
+func (ss *SelectStmt) Where() *WhereClause {
+	return firstTyped[*WhereClause](ss.Children())
+}
+
+func (ss *SelectStmt) Order() *OrderClause {
+	return firstTyped[*OrderClause](ss.Children())
+}
+
+func (ss *SelectStmt) Limit() *LimitClause {
+	return firstTyped[*LimitClause](ss.Children())
+}
+
+func (is *InsertStmt) IsStmt() {}
+
+func (is *InsertStmt) Table() *TableRef {
+	return firstTyped[*TableRef](is.Children())
+}
+
+func (is *InsertStmt) Columns() *ColumnList {
+	return firstTyped[*ColumnList](is.Children())
+}
+
+func (is *InsertStmt) Rows() []*ValuesRow {
+	return typedChildren[*ValuesRow](is.Children())
+}
+
+func (is *InsertStmt) OnConflict() *OnConflictClause {
+	return firstTyped[*OnConflictClause](is.Children())
+}
+
+func (us *UpdateStmt) IsStmt() {}
+
+func (us *UpdateStmt) Table() *TableRef {
+	return firstTyped[*TableRef](us.Children())
+}
+
+func (us *UpdateStmt) Sets() []*SetItem {
+	return typedChildren[*SetItem](us.Children())
+}
+
+func (us *UpdateStmt) Where() *WhereClause {
+	return firstTyped[*WhereClause](us.Children())
+}
+
+func (ds *DeleteStmt) IsStmt() {}
+
+func (ds *DeleteStmt) Table() *TableRef {
+	return firstTyped[*TableRef](ds.Children())
+}
+
+func (ds *DeleteStmt) Where() *WhereClause {
+	return firstTyped[*WhereClause](ds.Children())
+}
+
+func (rs *ReturnStmt) IsStmt() {}
+
+func (rs *ReturnStmt) Exprs() []Expr {
+	return typedChildren[Expr](rs.Children())
+}
+
+// IsNext reports whether this is a return next, which adds a row to the result
+// of a procedure returning a table instead of leaving it.
+func (rs *ReturnStmt) IsNext() bool {
+	return findTok(rs.Children(), T_NEXT) != nil
+}
+
+func (rs *ReturnStmt) Query() *SelectStmt {
+	return firstTyped[*SelectStmt](rs.Children())
+}
+
+func (is *IfStmt) IsStmt() {}
+
+func (is *IfStmt) Conds() []Expr {
+	return typedChildren[Expr](is.Children())
+}
+
+// Blocks returns the block of each condition followed by the else block if there is one.
+func (is *IfStmt) Blocks() []*Block {
+	return typedChildren[*Block](is.Children())
+}
+
+func (is *IfStmt) Else() *Block {
+	bs := is.Blocks()
+	if findTok(is.Children(), T_ELSE) == nil || len(bs) <= len(is.Conds()) {
+		return nil
+	}
+	return bs[len(bs)-1]
+}
+
+func (fs *ForStmt) IsStmt() {}
+
+func (fs *ForStmt) VarName() string {
+	return "$" + idText(fs.Children())
+}
+
+func (fs *ForStmt) Query() *SelectStmt {
+	return firstTyped[*SelectStmt](fs.Children())
+}
+
+func (fs *ForStmt) IsRange() bool {
+	return findTok(fs.Children(), T_RANGE) != nil
+}
+
+// Exprs returns the bounds of a range loop or the array of an array loop.
+func (fs *ForStmt) Exprs() []Expr {
+	return typedChildren[Expr](fs.Children())
+}
+
+func (fs *ForStmt) Body() *Block {
+	return firstTyped[*Block](fs.Children())
+}
+
+func (cs *CallStmt) IsStmt() {}
+
+func (cs *CallStmt) Call() *CallExpr {
+	return firstTyped[*CallExpr](cs.Children())
+}
+
+func (rc *ResultColumn) IsStar() bool {
+	return findTok(rc.Children(), T_STAR) != nil
+}
+
+func (rc *ResultColumn) Expr() *Expr {
+	return firstExpr(rc.Children())
+}
+
+func (rc *ResultColumn) Alias() string {
+	exprs := typedChildren[Expr](rc.Children())
+	if len(exprs) == 0 {
+		return ""
+	}
+	return idText(rc.Children()[slices.Index(rc.Children(), AstNode(exprs[0]))+1:])
+}
+
+func (tr *TableRef) Name() string {
+	return idText(tr.Children())
+}
+
+func (tr *TableRef) Alias() string {
+	return tokTextAfter(tr.Children(), T_ID, T_ID)
+}
+
+func (jc *JoinClause) Table() *TableRef {
+	return firstTyped[*TableRef](jc.Children())
+}
+
+func (jc *JoinClause) On() *Expr {
+	return firstExpr(jc.Children())
+}
+
+func (jc *JoinClause) IsLeft() bool {
+	return findTok(jc.Children(), T_LEFT) != nil
+}
+
+func (wc *WhereClause) Expr() *Expr {
+	return firstExpr(wc.Children())
+}
+
+func (oc *OrderClause) Exprs() []Expr {
+	return typedChildren[Expr](oc.Children())
+}
+
+// Desc tells for each of the expressions whether it's sorted in descending order.
+func (oc *OrderClause) Desc() []bool {
+	res := []bool{}
+	for _, c := range oc.Children() {
+		if _, ok := c.(Expr); ok {
+			res = append(res, false)
+		}
+		if t, ok := c.(*TokNode); ok && t.tok.kind == T_DESC && len(res) > 0 {
+			res[len(res)-1] = true
+		}
+	}
+	return res
+}
+
+func (lc *LimitClause) Expr() *Expr {
+	return firstExpr(lc.Children())
+}
+
+func (vr *ValuesRow) Exprs() []Expr {
+	return typedChildren[Expr](vr.Children())
+}
+
+func (oc *OnConflictClause) Columns() *ColumnList {
+	return firstTyped[*ColumnList](oc.Children())
+}
+
+func (oc *OnConflictClause) DoNothing() bool {
+	return findTok(oc.Children(), T_NOTHING) != nil
+}
+
+func (oc *OnConflictClause) Sets() []*SetItem {
+	return typedChildren[*SetItem](oc.Children())
+}
+
+func (oc *OnConflictClause) Where() *WhereClause {
+	return firstTyped[*WhereClause](oc.Children())
+}
+
+func (si *SetItem) Column() string {
+	return idText(si.Children())
+}
+
+func (si *SetItem) Expr() *Expr {
+	return firstExpr(si.Children())
+}
+
+func (ve *VarExpr) IsExpr() {}
+
+func (ve *VarExpr) VarName() string {
+	return "$" + idText(ve.Children())
+}
+
+// Field returns the field of a record variable like $row.name, or an empty string.
+func (ve *VarExpr) Field() string {
+	return tokTextAfter(ve.Children(), T_DOT, T_ID)
+}
+
+func (be *BinExpr) IsExpr() {}
+
+func (be *BinExpr) Left() *Expr {
+	exprs := typedChildren[Expr](be.Children())
+	if len(exprs) > 0 {
+		return &exprs[0]
+	} else {
+		return nil
+	}
+}
+
+func (be *BinExpr) Right() *Expr {
+	exprs := typedChildren[Expr](be.Children())
+	if len(exprs) > 1 {
+		return &exprs[1]
+	} else {
+		return nil
+	}
+}
+
+func (be *BinExpr) Op() TokKind {
+	for _, c := range be.Children() {
+		t, ok := c.(*TokNode)
+		if ok && !isTrivia(t.tok.kind) {
+			return t.tok.kind
+		}
+	}
+	return T_NONE
+}
+
+func (il *IntLitExpr) IsExpr() {}
+
+func (sl *StringLitExpr) IsExpr() {}
+
+// Value returns the literal without quotes and with doubled quotes unescaped.
+func (sl *StringLitExpr) Value() string {
+	t := findTok(sl.Children(), T_STRING)
+	if t == nil {
+		return ""
+	}
+	v := strings.TrimPrefix(t.Text(), "'")
+	v = strings.TrimSuffix(v, "'")
+	return strings.ReplaceAll(v, "''", "'")
+}
+
+func (bl *BoolLitExpr) IsExpr() {}
+
+func (bl *BoolLitExpr) Value() bool {
+	return findTok(bl.Children(), T_TRUE) != nil
+}
+
+func (nl *NullLitExpr) IsExpr() {}
+
+func (cv *CtxVarExpr) IsExpr() {}
+
+func (cv *CtxVarExpr) VarName() string {
+	return "@" + idText(cv.Children())
+}
+
+func (ce *ColumnExpr) IsExpr() {}
+
+// Table returns the qualifier of a column reference like t.col, or an empty string.
+func (ce *ColumnExpr) Table() string {
+	if findTok(ce.Children(), T_DOT) == nil {
+		return ""
+	}
+	return idText(ce.Children())
+}
+
+func (ce *ColumnExpr) Column() string {
+	if findTok(ce.Children(), T_DOT) == nil {
+		return idText(ce.Children())
+	}
+	return tokTextAfter(ce.Children(), T_DOT, T_ID)
+}
+
+func (ce *CallExpr) IsExpr() {}
+
+func (ce *CallExpr) Name() string {
+	return idText(ce.Children())
+}
+
+func (ce *CallExpr) Args() []Expr {
+	return typedChildren[Expr](ce.Children())
+}
+
+func (pe *ParenExpr) IsExpr() {}
+
+func (pe *ParenExpr) Expr() *Expr {
+	return firstExpr(pe.Children())
+}
+
+func (ue *UnaryExpr) IsExpr() {}
+
+func (ue *UnaryExpr) Op() TokKind {
+	for _, c := range ue.Children() {
+		t, ok := c.(*TokNode)
+		if ok {
+			return t.tok.kind
+		}
+	}
+	return T_NONE
+}
+
+func (ue *UnaryExpr) Operand() *Expr {
+	return firstExpr(ue.Children())
+}
+
+func (in *IsNullExpr) IsExpr() {}
+
+func (in *IsNullExpr) Expr() *Expr {
+	return firstExpr(in.Children())
+}
+
+func (in *IsNullExpr) Negated() bool {
+	return findTok(in.Children(), T_NOT) != nil
+}
+
+func NewFileRoot(ns []AstNode) *FileRoot {
+	return &FileRoot{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewDbDirective(ns []AstNode) *DbDirective {
+	return &DbDirective{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewExtDirective(ns []AstNode) *ExtDirective {
+	return &ExtDirective{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewTableDecl(ns []AstNode) *TableDecl {
+	return &TableDecl{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewActionDecl(ns []AstNode) *ActionDecl {
+	return &ActionDecl{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewParamDecl(ns []AstNode) *ParamDecl {
+	return &ParamDecl{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewAnnotation(ns []AstNode) *Annotation {
+	return &Annotation{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewReturnsClause(ns []AstNode) *ReturnsClause {
+	return &ReturnsClause{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewReturnColumn(ns []AstNode) *ReturnColumn {
+	return &ReturnColumn{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewBlock(ns []AstNode) *Block {
+	return &Block{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewIfStmt(ns []AstNode) *IfStmt {
+	return &IfStmt{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewForStmt(ns []AstNode) *ForStmt {
+	return &ForStmt{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewCallStmt(ns []AstNode) *CallStmt {
+	return &CallStmt{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewAssignStmt(ns []AstNode) *AssignStmt {
+	return &AssignStmt{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewVarExpr(ns []AstNode) *VarExpr {
+	return &VarExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewBinExpr(ns []AstNode) *BinExpr {
+	return &BinExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewIntLitExpr(ns []AstNode) *IntLitExpr {
+	return &IntLitExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewExtParam(ns []AstNode) *ExtParam {
+	return &ExtParam{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewColumnDecl(ns []AstNode) *ColumnDecl {
+	return &ColumnDecl{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewTypeRef(ns []AstNode) *TypeRef {
+	return &TypeRef{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewColumnAttr(ns []AstNode) *ColumnAttr {
+	return &ColumnAttr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewIndexDecl(ns []AstNode) *IndexDecl {
+	return &IndexDecl{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewForeignKeyDecl(ns []AstNode) *ForeignKeyDecl {
+	return &ForeignKeyDecl{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewFkAction(ns []AstNode) *FkAction {
+	return &FkAction{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewColumnList(ns []AstNode) *ColumnList {
+	return &ColumnList{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewErrorNode(ns []AstNode) *ErrorNode {
+	return &ErrorNode{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewSelectStmt(ns []AstNode) *SelectStmt {
+	return &SelectStmt{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewInsertStmt(ns []AstNode) *InsertStmt {
+	return &InsertStmt{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewUpdateStmt(ns []AstNode) *UpdateStmt {
+	return &UpdateStmt{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewDeleteStmt(ns []AstNode) *DeleteStmt {
+	return &DeleteStmt{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewReturnStmt(ns []AstNode) *ReturnStmt {
+	return &ReturnStmt{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewResultColumn(ns []AstNode) *ResultColumn {
+	return &ResultColumn{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewTableRef(ns []AstNode) *TableRef {
+	return &TableRef{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewJoinClause(ns []AstNode) *JoinClause {
+	return &JoinClause{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewWhereClause(ns []AstNode) *WhereClause {
+	return &WhereClause{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewOrderClause(ns []AstNode) *OrderClause {
+	return &OrderClause{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewLimitClause(ns []AstNode) *LimitClause {
+	return &LimitClause{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewValuesRow(ns []AstNode) *ValuesRow {
+	return &ValuesRow{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewOnConflictClause(ns []AstNode) *OnConflictClause {
+	return &OnConflictClause{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewSetItem(ns []AstNode) *SetItem {
+	return &SetItem{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewStringLitExpr(ns []AstNode) *StringLitExpr {
+	return &StringLitExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewBoolLitExpr(ns []AstNode) *BoolLitExpr {
+	return &BoolLitExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewNullLitExpr(ns []AstNode) *NullLitExpr {
+	return &NullLitExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewCtxVarExpr(ns []AstNode) *CtxVarExpr {
+	return &CtxVarExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewColumnExpr(ns []AstNode) *ColumnExpr {
+	return &ColumnExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewCallExpr(ns []AstNode) *CallExpr {
+	return &CallExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewParenExpr(ns []AstNode) *ParenExpr {
+	return &ParenExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewUnaryExpr(ns []AstNode) *UnaryExpr {
+	return &UnaryExpr{
+		compNode: *newComp(ns),
+	}
+}
+
+func NewIsNullExpr(ns []AstNode) *IsNullExpr {
+	return &IsNullExpr{
 		compNode: *newComp(ns),
 	}
 }
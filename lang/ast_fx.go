@@ -17,6 +17,8 @@ type AstNode interface {
 	Children() []AstNode
 	TextLen() int
 	Text() string
+	Start() int
+	End() int
 }
 
 type TokNode struct {
@@ -24,6 +26,7 @@ type TokNode struct {
 }
 
 type compNode struct {
+	start int
 	len   int
 	nodes []AstNode
 }
@@ -40,6 +43,18 @@ func (t *TokNode) Text() string {
 	return t.tok.text
 }
 
+func (t *TokNode) Start() int {
+	return t.tok.start
+}
+
+func (t *TokNode) End() int {
+	return t.tok.end
+}
+
+func (t *TokNode) Kind() TokKind {
+	return t.tok.kind
+}
+
 func newTok(t Token) *TokNode {
 	return &TokNode{
 		tok: t,
@@ -54,6 +69,14 @@ func (c *compNode) TextLen() int {
 	return c.len
 }
 
+func (c *compNode) Start() int {
+	return c.start
+}
+
+func (c *compNode) End() int {
+	return c.start + c.len
+}
+
 func (c *compNode) Text() string {
 	res := ""
 	for _, c := range c.nodes {
@@ -68,7 +91,13 @@ func newComp(ns []AstNode) *compNode {
 		tl += n.TextLen()
 	}
 
+	start := 0
+	if len(ns) > 0 {
+		start = ns[0].Start()
+	}
+
 	return &compNode{
+		start: start,
 		nodes: ns,
 		len:   tl,
 	}
@@ -103,3 +132,57 @@ func typedChildren[A AstNode](ns []AstNode) []A {
 	}
 	return res
 }
+
+func firstTyped[A AstNode](ns []AstNode) A {
+	var res A
+	for _, c := range ns {
+		i, ok := c.(A)
+		if ok {
+			return i
+		}
+	}
+	return res
+}
+
+func firstExpr(ns []AstNode) *Expr {
+	exprs := typedChildren[Expr](ns)
+	if len(exprs) == 0 {
+		return nil
+	} else {
+		return &exprs[0]
+	}
+}
+
+// tokTextAfter returns the text of the first token of kind tk following the first token of kind after.
+func tokTextAfter(ns []AstNode, after TokKind, tk TokKind) string {
+	for i, n := range ns {
+		t, ok := n.(*TokNode)
+		if ok && t.tok.kind == after {
+			r := findTok(ns[i+1:], tk)
+			if r != nil {
+				return r.Text()
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+func columnListNames(ns []AstNode, idx int) []string {
+	cls := typedChildren[*ColumnList](ns)
+	if idx >= len(cls) {
+		return []string{}
+	}
+	return cls[idx].Names()
+}
+
+// Walk calls f for n and all of its descendants in depth-first order. Children of a
+// node are skipped when f returns false for it.
+func Walk(n AstNode, f func(AstNode) bool) {
+	if !f(n) {
+		return
+	}
+	for _, c := range n.Children() {
+		Walk(c, f)
+	}
+}
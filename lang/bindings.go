@@ -0,0 +1,252 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Bindings is the typed view of a schema code generators work from. Unlike
+// Schema, it has the types of action parameters and results, which are
+// inferred from the bodies.
+type Bindings struct {
+	Name    string
+	Tables  []*BoundTable
+	Actions []*BoundAction
+}
+
+type BoundTable struct {
+	Name    string
+	Columns []*BoundField
+}
+
+// BoundField is a column, a parameter or a result column. Nullable fields
+// come from columns which aren't notnull or primary.
+type BoundField struct {
+	Name     string
+	Type     *DataType
+	Nullable bool
+	Column   *BoundTable // the table of a result column taken from one
+	// Guessed tells that an untyped parameter isn't used in a way revealing
+	// its type, so Type is the text fallback.
+	Guessed bool
+}
+
+// BoundAction is an action or a procedure. Rows tells whether it returns any
+// number of rows rather than a single one.
+type BoundAction struct {
+	Name      string
+	Procedure bool
+	Public    bool
+	View      bool
+	Owner     bool
+	Params    []*BoundField
+	Results   []*BoundField
+	Rows      bool
+}
+
+// ResultTable returns the table an action returns whole rows of, if any.
+func (a *BoundAction) ResultTable() *BoundTable {
+	if len(a.Results) == 0 || !a.Rows {
+		return nil
+	}
+	t := a.Results[0].Column
+	if t == nil || len(a.Results) != len(t.Columns) {
+		return nil
+	}
+	for i, f := range a.Results {
+		if f.Column != t || f.Name != t.Columns[i].Name {
+			return nil
+		}
+	}
+	return t
+}
+
+// NewBindings returns the bindings of a file. Untyped action parameters get
+// the type of the columns they're used with, and actions return the rows of
+// their last SELECT. Types which can't be inferred are text.
+func NewBindings(fr *FileRoot) (*Bindings, []Diagnostic) {
+	if diags := Check(fr); len(diags) > 0 {
+		return nil, diags
+	}
+	b := binder{compiler: compiler{}, g: &sqlGen{fr: fr}, tables: map[*TableDecl]*BoundTable{}}
+	res := &Bindings{Tables: []*BoundTable{}, Actions: []*BoundAction{}}
+	if dd := fr.DbDirective(); dd != nil {
+		res.Name = dd.Name()
+	}
+	for _, td := range fr.TableDecls() {
+		bt := &BoundTable{Name: td.Name(), Columns: []*BoundField{}}
+		for _, cd := range td.Columns() {
+			bt.Columns = append(bt.Columns, b.column(cd))
+		}
+		b.tables[td] = bt
+		res.Tables = append(res.Tables, bt)
+	}
+	for _, ad := range fr.ActionDecls() {
+		res.Actions = append(res.Actions, b.action(ad))
+	}
+
+	if len(b.diags) > 0 {
+		sortDiagnostics(b.diags)
+		return nil, b.diags
+	}
+	return res, nil
+}
+
+type binder struct {
+	compiler
+	g      *sqlGen
+	tables map[*TableDecl]*BoundTable
+}
+
+func (b *binder) column(cd *ColumnDecl) *BoundField {
+	return &BoundField{
+		Name:     cd.Name(),
+		Type:     b.dataType(cd.Type()),
+		Nullable: !cd.HasAttr("notnull") && !cd.HasAttr("primary") && !cd.HasAttr("pk"),
+	}
+}
+
+func (b *binder) action(ad *ActionDecl) *BoundAction {
+	res := &BoundAction{
+		Name:      ad.Name(),
+		Procedure: ad.IsProcedure(),
+		Public:    ad.HasModifier(T_PUBLIC),
+		View:      ad.HasModifier(T_VIEW),
+		Owner:     ad.HasModifier(T_OWNER),
+		Params:    []*BoundField{},
+		Results:   []*BoundField{},
+	}
+	f := fnGen{g: b.g, c: &checker{fr: b.g.fr}, ad: ad, vars: map[string]string{}, records: map[string]*SelectStmt{}}
+	for _, pd := range ad.Params() {
+		typ := ""
+		if pd.Type() != nil {
+			typ = pgTypeName(pd.Type())
+			res.Params = append(res.Params, &BoundField{Name: pd.Name(), Type: b.dataType(pd.Type())})
+		} else {
+			inferred := false
+			typ, inferred = f.inferParamType(pd.Name())
+			if !inferred {
+				typ = "TEXT"
+			}
+			res.Params = append(res.Params, &BoundField{Name: pd.Name(), Type: pgDataType(typ), Guessed: !inferred})
+		}
+		f.vars[strings.ToLower(pd.Name())] = typ
+	}
+	f.declare(ad.Stmts())
+
+	if rc := ad.Returns(); rc != nil {
+		res.Rows = rc.IsTable()
+		for i, c := range rc.Columns() {
+			name := c.Name()
+			if name == "" {
+				name = fmt.Sprintf("column%d", i+1)
+			}
+			res.Results = append(res.Results, &BoundField{Name: name, Type: b.dataType(c.Type())})
+		}
+		return res
+	}
+	if ad.IsProcedure() {
+		return res
+	}
+	var last *SelectStmt
+	for _, st := range ad.Stmts() {
+		if ss, ok := st.(*SelectStmt); ok {
+			last = ss
+		}
+	}
+	if last != nil {
+		res.Rows = true
+		res.Results = b.resultFields(&f, last)
+	}
+	return res
+}
+
+// resultFields returns the columns of a query, which keep the nullability of
+// the table columns they come from.
+func (b *binder) resultFields(f *fnGen, ss *SelectStmt) []*BoundField {
+	scope := f.scope(ss)
+	res := []*BoundField{}
+	for i, rc := range ss.ResultColumns() {
+		if rc.IsStar() {
+			for _, e := range scope {
+				if e.table == nil {
+					continue
+				}
+				for _, cd := range e.table.Columns() {
+					field := b.column(cd)
+					field.Column = b.tables[e.table]
+					res = append(res, field)
+				}
+			}
+			continue
+		}
+		if rc.Expr() == nil {
+			continue
+		}
+		e := *rc.Expr()
+		field := &BoundField{Name: rc.Alias()}
+		switch e := e.(type) {
+		case *ColumnExpr:
+			if field.Name == "" {
+				field.Name = e.Column()
+			}
+			if cd := f.c.lookupColumn(e, scope); cd != nil {
+				col := b.column(cd)
+				field.Type, field.Nullable = col.Type, col.Nullable
+			}
+		case *CallExpr:
+			if field.Name == "" {
+				field.Name = e.Name()
+			}
+		}
+		if field.Name == "" {
+			field.Name = fmt.Sprintf("column%d", i+1)
+		}
+		if field.Type == nil {
+			field.Type = pgDataType(f.exprType(e, scope))
+		}
+		res = append(res, field)
+	}
+	return res
+}
+
+// pgDataType converts an inferred PostgreSQL type back to a Kuneiform one.
+func pgDataType(typ string) *DataType {
+	res := &DataType{Name: "text"}
+	typ, res.IsArray = strings.CutSuffix(typ, "[]")
+	switch {
+	case typ == "INT8":
+		res.Name = "int"
+	case typ == "BOOLEAN":
+		res.Name = "bool"
+	case typ == "BYTEA":
+		res.Name = "blob"
+	case typ == "UUID":
+		res.Name = "uuid"
+	case typ == "NUMERIC(78, 0)":
+		res.Name = "uint256"
+	case strings.HasPrefix(typ, "NUMERIC"):
+		res.Name = "decimal"
+		args := strings.Split(strings.Trim(strings.TrimPrefix(typ, "NUMERIC"), "()"), ",")
+		for i, a := range args {
+			if n, err := strconv.ParseUint(strings.TrimSpace(a), 10, 16); err == nil && i < len(res.Metadata) {
+				res.Metadata[i] = uint16(n)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,575 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	CodeUnknownTable     = "unknown-table"
+	CodeUnknownColumn    = "unknown-column"
+	CodeAmbiguousColumn  = "ambiguous-column"
+	CodeDuplicateTable   = "duplicate-table"
+	CodeDuplicateColumn  = "duplicate-column"
+	CodeInsertCount      = "insert-count"
+	CodeMissingNotNull   = "missing-notnull"
+	CodeConflictTarget   = "conflict-target"
+	CodeJoinTypeMismatch = "join-type-mismatch"
+	CodeUndefinedVar     = "undefined-variable"
+)
+
+type checker struct {
+	fr    *FileRoot
+	diags []Diagnostic
+}
+
+// scopeEntry is a table visible in a SQL statement under its alias or name.
+// The table is nil when the name doesn't resolve to a declaration.
+type scopeEntry struct {
+	name  string
+	table *TableDecl
+}
+
+// Check returns syntax errors of the file together with the problems found by
+// validating SQL statements against the declared tables.
+func Check(fr *FileRoot) []Diagnostic {
+	c := checker{
+		fr:    fr,
+		diags: slices.Clone(fr.SyntaxErrors()),
+	}
+
+	c.checkTables()
+	for _, ad := range fr.ActionDecls() {
+		for _, st := range ad.AllStmts() {
+			c.checkStmt(st)
+		}
+		c.checkVars(ad)
+	}
+
+	sortDiagnostics(c.diags)
+	return c.diags
+}
+
+// report adds an error and returns it so that fixes can be attached.
+func (c *checker) report(n AstNode, code string, format string, args ...any) {
+	c.reportFixes(n, code, nil, format, args...)
+}
+
+// reportFixes reports a problem with its quick fixes. They are passed in
+// rather than set afterwards, as a later report can move the diagnostic.
+func (c *checker) reportFixes(n AstNode, code string, fixes []Fix, format string, args ...any) {
+	c.diags = append(c.diags, Diagnostic{
+		Start:    n.Start(),
+		End:      n.End(),
+		Severity: SevError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Fixes:    fixes,
+	})
+}
+
+func nameNode(n AstNode) AstNode {
+	id := findTok(n.Children(), T_ID)
+	if id == nil {
+		return n
+	}
+	return id
+}
+
+func (c *checker) checkTables() {
+	seen := map[string]bool{}
+	for _, td := range c.fr.TableDecls() {
+		name := strings.ToLower(td.Name())
+		if seen[name] {
+			c.report(nameNode(td), CodeDuplicateTable, "table '%s' is already declared", td.Name())
+		}
+		seen[name] = true
+
+		cols := map[string]bool{}
+		for _, cd := range td.Columns() {
+			cn := strings.ToLower(cd.Name())
+			if cols[cn] {
+				c.report(nameNode(cd), CodeDuplicateColumn, "column '%s' is already declared in table '%s'", cd.Name(), td.Name())
+			}
+			cols[cn] = true
+		}
+
+		for _, id := range td.Indexes() {
+			c.checkColumnList(firstTyped[*ColumnList](id.Children()), td)
+		}
+
+		for _, fk := range td.ForeignKeys() {
+			cls := typedChildren[*ColumnList](fk.Children())
+			if len(cls) > 0 {
+				c.checkColumnList(cls[0], td)
+			}
+			ref := c.fr.TableDecl(fk.RefTable())
+			if ref == nil {
+				if fk.RefTable() != "" {
+					n := refTableNode(fk)
+					fixes := append(c.renameFixes(n, fk.RefTable(), c.tableNames()), c.createTableFix(fk.RefTable()))
+					c.reportFixes(n, CodeUnknownTable, fixes, "unknown table '%s'", fk.RefTable())
+				}
+			} else if len(cls) > 1 {
+				c.checkColumnList(cls[1], ref)
+			}
+		}
+	}
+}
+
+func refTableNode(fk *ForeignKeyDecl) AstNode {
+	ns := fk.Children()
+	for i, n := range ns {
+		t, ok := n.(*TokNode)
+		if ok && (t.tok.kind == T_REFERENCES || strings.EqualFold(t.tok.text, "ref")) {
+			id := findTok(ns[i+1:], T_ID)
+			if id != nil {
+				return id
+			}
+		}
+	}
+	return fk
+}
+
+func (c *checker) checkColumnList(cl *ColumnList, td *TableDecl) {
+	if cl == nil || td == nil {
+		return
+	}
+	for _, id := range cl.Ids() {
+		if td.Column(id.Text()) == nil {
+			c.reportUnknownColumn(id, id.Text(), td)
+		}
+	}
+}
+
+func (c *checker) resolveTable(tr *TableRef) *TableDecl {
+	if tr == nil || tr.Name() == "" {
+		return nil
+	}
+	td := c.fr.TableDecl(tr.Name())
+	if td == nil {
+		n := nameNode(tr)
+		fixes := append(c.renameFixes(n, tr.Name(), c.tableNames()), c.createTableFix(tr.Name()))
+		c.reportFixes(n, CodeUnknownTable, fixes, "unknown table '%s'", tr.Name())
+	}
+	return td
+}
+
+func (c *checker) scopeEntry(tr *TableRef) scopeEntry {
+	name := tr.Alias()
+	if name == "" {
+		name = tr.Name()
+	}
+	return scopeEntry{
+		name:  name,
+		table: c.resolveTable(tr),
+	}
+}
+
+func (c *checker) checkStmt(st Stmt) {
+	switch st := st.(type) {
+	case *SelectStmt:
+		c.checkSelect(st)
+	case *InsertStmt:
+		c.checkInsert(st)
+	case *UpdateStmt:
+		c.checkUpdate(st)
+	case *DeleteStmt:
+		c.checkDelete(st)
+	}
+}
+
+func (c *checker) checkSelect(ss *SelectStmt) {
+	scope := []scopeEntry{}
+	for _, tr := range ss.TableRefs() {
+		scope = append(scope, c.scopeEntry(tr))
+	}
+
+	aliases := []string{}
+	for _, rc := range ss.ResultColumns() {
+		c.checkExprs(rc, scope, nil)
+		if rc.Alias() != "" {
+			aliases = append(aliases, rc.Alias())
+		}
+	}
+	for _, j := range ss.Joins() {
+		if j.On() != nil {
+			c.checkExprs(*j.On(), scope, nil)
+			c.checkJoinTypes(*j.On(), scope)
+		}
+	}
+	if ss.Where() != nil {
+		c.checkExprs(ss.Where(), scope, nil)
+	}
+	if ss.Order() != nil {
+		c.checkExprs(ss.Order(), scope, aliases)
+	}
+	if ss.Limit() != nil {
+		c.checkExprs(ss.Limit(), scope, nil)
+	}
+}
+
+func (c *checker) checkInsert(is *InsertStmt) {
+	if is.Table() == nil {
+		return
+	}
+	td := c.resolveTable(is.Table())
+	if td == nil {
+		return
+	}
+
+	cols := []string{}
+	if is.Columns() != nil {
+		c.checkColumnList(is.Columns(), td)
+		cols = is.Columns().Names()
+	} else {
+		for _, cd := range td.Columns() {
+			cols = append(cols, cd.Name())
+		}
+	}
+
+	for _, row := range is.Rows() {
+		if len(row.Exprs()) != len(cols) {
+			c.report(row, CodeInsertCount, "INSERT has %d value(s) for %d column(s)", len(row.Exprs()), len(cols))
+		}
+	}
+
+	if is.Columns() != nil {
+		for _, cd := range td.Columns() {
+			if !isRequiredColumn(cd) || slices.ContainsFunc(cols, func(n string) bool { return strings.EqualFold(n, cd.Name()) }) {
+				continue
+			}
+			c.report(nameNode(is.Table()), CodeMissingNotNull, "column '%s' is notnull and has no default, but isn't inserted", cd.Name())
+		}
+	}
+
+	oc := is.OnConflict()
+	if oc == nil {
+		return
+	}
+	if oc.Columns() == nil {
+		if !oc.DoNothing() {
+			c.report(oc, CodeConflictTarget, "ON CONFLICT DO UPDATE requires a conflict target")
+		}
+	} else {
+		c.checkColumnList(oc.Columns(), td)
+		if !isUniqueKey(td, oc.Columns().Names()) {
+			c.report(oc.Columns(), CodeConflictTarget, "conflict target (%s) isn't a primary key or unique constraint of table '%s'", strings.Join(oc.Columns().Names(), ", "), td.Name())
+		}
+	}
+
+	scope := []scopeEntry{{name: is.Table().Name(), table: td}, {name: "excluded", table: td}}
+	if is.Table().Alias() != "" {
+		scope[0].name = is.Table().Alias()
+	}
+	c.checkSetItems(oc.Sets(), td, scope)
+	if oc.Where() != nil {
+		c.checkExprs(oc.Where(), scope, nil)
+	}
+}
+
+func (c *checker) checkUpdate(us *UpdateStmt) {
+	if us.Table() == nil {
+		return
+	}
+	scope := []scopeEntry{c.scopeEntry(us.Table())}
+	if scope[0].table == nil {
+		return
+	}
+	c.checkSetItems(us.Sets(), scope[0].table, scope)
+	if us.Where() != nil {
+		c.checkExprs(us.Where(), scope, nil)
+	}
+}
+
+func (c *checker) checkDelete(ds *DeleteStmt) {
+	if ds.Table() == nil {
+		return
+	}
+	scope := []scopeEntry{c.scopeEntry(ds.Table())}
+	if scope[0].table == nil {
+		return
+	}
+	if ds.Where() != nil {
+		c.checkExprs(ds.Where(), scope, nil)
+	}
+}
+
+func (c *checker) checkSetItems(sis []*SetItem, td *TableDecl, scope []scopeEntry) {
+	for _, si := range sis {
+		if si.Column() != "" && td.Column(si.Column()) == nil {
+			c.reportUnknownColumn(nameNode(si), si.Column(), td)
+		}
+		if si.Expr() != nil {
+			c.checkExprs(*si.Expr(), scope, nil)
+		}
+	}
+}
+
+// checkExprs resolves every column reference under n. Unqualified names from
+// aliases are accepted as well, which is how ORDER BY can refer to result columns.
+func (c *checker) checkExprs(n AstNode, scope []scopeEntry, aliases []string) {
+	Walk(n, func(n AstNode) bool {
+		ce, ok := n.(*ColumnExpr)
+		if !ok {
+			return true
+		}
+		if ce.Table() == "" && slices.ContainsFunc(aliases, func(a string) bool { return strings.EqualFold(a, ce.Column()) }) {
+			return false
+		}
+		c.resolveColumn(ce, scope)
+		return false
+	})
+}
+
+func (c *checker) resolveColumn(ce *ColumnExpr, scope []scopeEntry) *ColumnDecl {
+	if ce.Column() == "" {
+		return nil
+	}
+
+	if ce.Table() != "" {
+		idx := slices.IndexFunc(scope, func(e scopeEntry) bool { return strings.EqualFold(e.name, ce.Table()) })
+		if idx < 0 {
+			n := findTok(ce.Children(), T_ID)
+			names := []string{}
+			for _, e := range scope {
+				names = append(names, e.name)
+			}
+			c.reportFixes(ce, CodeUnknownTable, c.renameFixes(n, ce.Table(), names), "unknown table or alias '%s'", ce.Table())
+			return nil
+		}
+		td := scope[idx].table
+		if td == nil {
+			return nil
+		}
+		cd := td.Column(ce.Column())
+		if cd == nil {
+			fixes := c.columnFixes(columnNameNode(ce), ce.Column(), []*TableDecl{td})
+			c.reportFixes(ce, CodeUnknownColumn, fixes, "unknown column '%s' in table '%s'", ce.Column(), td.Name())
+		}
+		return cd
+	}
+
+	found := []*ColumnDecl{}
+	unresolved := false
+	for _, e := range scope {
+		if e.table == nil {
+			unresolved = true
+			continue
+		}
+		cd := e.table.Column(ce.Column())
+		if cd != nil {
+			found = append(found, cd)
+		}
+	}
+
+	switch {
+	case len(found) == 1:
+		return found[0]
+	case len(found) > 1:
+		c.report(ce, CodeAmbiguousColumn, "column reference '%s' is ambiguous", ce.Column())
+	case !unresolved:
+		tables := []*TableDecl{}
+		for _, e := range scope {
+			if e.name != "excluded" {
+				tables = append(tables, e.table)
+			}
+		}
+		c.reportFixes(ce, CodeUnknownColumn, c.columnFixes(columnNameNode(ce), ce.Column(), tables), "unknown column '%s'", ce.Column())
+	}
+	return nil
+}
+
+func (c *checker) reportUnknownColumn(n AstNode, name string, td *TableDecl) {
+	c.reportFixes(n, CodeUnknownColumn, c.columnFixes(n, name, []*TableDecl{td}), "unknown column '%s' in table '%s'", name, td.Name())
+}
+
+// columnNameNode returns the token of the column name in a possibly qualified reference.
+func columnNameNode(ce *ColumnExpr) AstNode {
+	ids := typedChildren[*TokNode](ce.Children())
+	for i := len(ids) - 1; i >= 0; i-- {
+		if ids[i].tok.kind == T_ID {
+			return ids[i]
+		}
+	}
+	return ce
+}
+
+// checkVars reports variables read before any assignment.
+func (c *checker) checkVars(ad *ActionDecl) {
+	defined := map[string]bool{}
+	for _, pd := range ad.Params() {
+		defined[strings.ToLower(pd.Name())] = true
+	}
+
+	names := func() []string {
+		res := []string{}
+		for n := range defined {
+			res = append(res, n)
+		}
+		slices.Sort(res)
+		return res
+	}
+
+	checkReads := func(n AstNode) {
+		Walk(n, func(n AstNode) bool {
+			ve, ok := n.(*VarExpr)
+			if !ok || ve.VarName() == "$" {
+				return true
+			}
+			name := strings.ToLower(ve.VarName())
+			if !defined[name] {
+				fixes := append(c.renameFixes(ve, ve.VarName(), names()), c.declareParamFix(ad, ve.VarName())...)
+				c.reportFixes(ve, CodeUndefinedVar, fixes, "undefined variable '%s'", ve.VarName())
+				defined[name] = true
+			}
+			return false
+		})
+	}
+
+	var checkStmts func(sts []Stmt)
+	checkStmts = func(sts []Stmt) {
+		for _, st := range sts {
+			switch st := st.(type) {
+			case *AssignStmt:
+				if st.Expr() != nil {
+					checkReads(*st.Expr())
+				}
+				if st.VarName() != "$" {
+					defined[strings.ToLower(st.VarName())] = true
+				}
+			case *IfStmt:
+				for _, n := range st.Children() {
+					if b, ok := n.(*Block); ok {
+						checkStmts(b.Stmts())
+					} else {
+						checkReads(n)
+					}
+				}
+			case *ForStmt:
+				for _, n := range st.Children() {
+					if _, ok := n.(*Block); !ok {
+						checkReads(n)
+					}
+				}
+				if st.VarName() != "$" {
+					defined[strings.ToLower(st.VarName())] = true
+				}
+				if st.Body() != nil {
+					checkStmts(st.Body().Stmts())
+				}
+			default:
+				checkReads(st)
+			}
+		}
+	}
+	checkStmts(ad.Stmts())
+}
+
+func (c *checker) checkJoinTypes(e Expr, scope []scopeEntry) {
+	Walk(e, func(n AstNode) bool {
+		be, ok := n.(*BinExpr)
+		if !ok || (be.Op() != T_ASSIGN && be.Op() != T_EQ) {
+			return true
+		}
+		l, lok := (*be.Left()).(*ColumnExpr)
+		r, rok := (*be.Right()).(*ColumnExpr)
+		if !lok || !rok {
+			return true
+		}
+		lc := c.lookupColumn(l, scope)
+		rc := c.lookupColumn(r, scope)
+		if lc == nil || rc == nil || lc.Type() == nil || rc.Type() == nil {
+			return false
+		}
+		if !compatibleTypes(lc.Type(), rc.Type()) {
+			c.report(be, CodeJoinTypeMismatch, "join compares '%s' of type %s with '%s' of type %s", l.Text(), lc.Type().Text(), r.Text(), rc.Type().Text())
+		}
+		return false
+	})
+}
+
+// lookupColumn is resolveColumn without reporting, for use after the references were already checked.
+func (c *checker) lookupColumn(ce *ColumnExpr, scope []scopeEntry) *ColumnDecl {
+	diags := c.diags
+	defer func() { c.diags = diags }()
+	return c.resolveColumn(ce, scope)
+}
+
+func isRequiredColumn(cd *ColumnDecl) bool {
+	return (cd.HasAttr("notnull") || cd.HasAttr("primary") || cd.HasAttr("pk")) && !cd.HasAttr("default")
+}
+
+// uniqueKeys returns the column sets of the primary key and unique constraints of a table.
+func uniqueKeys(td *TableDecl) [][]string {
+	res := [][]string{}
+	pk := []string{}
+	for _, cd := range td.Columns() {
+		if cd.HasAttr("primary") || cd.HasAttr("pk") {
+			pk = append(pk, cd.Name())
+		}
+		if cd.HasAttr("unique") {
+			res = append(res, []string{cd.Name()})
+		}
+	}
+	if len(pk) > 0 {
+		res = append(res, pk)
+	}
+	for _, id := range td.Indexes() {
+		if id.Kind() == "primary" || id.Kind() == "unique" {
+			res = append(res, id.Columns())
+		}
+	}
+	return res
+}
+
+func isUniqueKey(td *TableDecl, cols []string) bool {
+	norm := func(cs []string) []string {
+		res := []string{}
+		for _, c := range cs {
+			res = append(res, strings.ToLower(c))
+		}
+		slices.Sort(res)
+		return slices.Compact(res)
+	}
+	target := norm(cols)
+	for _, k := range uniqueKeys(td) {
+		if slices.Equal(norm(k), target) {
+			return true
+		}
+	}
+	return false
+}
+
+func baseTypeName(tr *TypeRef) string {
+	switch tr.Name() {
+	case "int8", "integer", "bigint":
+		return "int"
+	case "numeric":
+		return "decimal"
+	case "boolean":
+		return "bool"
+	case "bytea":
+		return "blob"
+	default:
+		return tr.Name()
+	}
+}
+
+func compatibleTypes(a *TypeRef, b *TypeRef) bool {
+	return baseTypeName(a) == baseTypeName(b) && a.IsArray() == b.IsArray()
+}
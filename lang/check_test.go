@@ -0,0 +1,133 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const checkSchema = `database shop;
+
+table users {
+	id uuid primary,
+	name text notnull,
+	age int default(0),
+	wallet text notnull unique
+}
+
+table orders {
+	id int primary,
+	user_id uuid notnull,
+	name text,
+	#pair unique(user_id, name),
+	foreign_key (user_id) references users(id) on_delete cascade
+}
+`
+
+func checkAction(body string) []Diagnostic {
+	return Check(ParseFile(checkSchema + "action a($id, $name) public {" + body + "}"))
+}
+
+func diagCodes(ds []Diagnostic) []string {
+	res := []string{}
+	for _, d := range ds {
+		res = append(res, d.Code)
+	}
+	return res
+}
+
+func TestCheckValidSchema(t *testing.T) {
+	ds := checkAction(`
+		SELECT u.name, o.name AS order_name FROM users u JOIN orders o ON o.user_id = u.id WHERE u.age > 10 ORDER BY order_name;
+		INSERT INTO users (id, name, wallet) VALUES ($id, $name, 'w') ON CONFLICT (id) DO UPDATE SET name = excluded.name;
+		UPDATE users SET age = age + 1 WHERE id = $id;
+		DELETE FROM orders WHERE user_id = $id AND name IS NOT NULL;`)
+	assert.Empty(t, ds)
+}
+
+func TestCheckUnknownTableAndColumn(t *testing.T) {
+	ds := checkAction(`SELECT * FROM customers; SELECT nme FROM users; SELECT x.id FROM users;`)
+	assert.Equal(t, []string{CodeUnknownTable, CodeUnknownColumn, CodeUnknownTable}, diagCodes(ds))
+	assert.Equal(t, "unknown table 'customers'", ds[0].Message)
+	assert.Equal(t, "unknown column 'nme'", ds[1].Message)
+}
+
+func TestCheckAmbiguousColumn(t *testing.T) {
+	ds := checkAction(`SELECT name FROM users JOIN orders ON orders.user_id = users.id;`)
+	assert.Equal(t, []string{CodeAmbiguousColumn}, diagCodes(ds))
+}
+
+func TestCheckInsert(t *testing.T) {
+	ds := checkAction(`INSERT INTO users (id, name, wallet) VALUES ($id, $name);`)
+	assert.Equal(t, []string{CodeInsertCount}, diagCodes(ds))
+
+	ds = checkAction(`INSERT INTO users VALUES ($id, $name, 1, 'w');`)
+	assert.Empty(t, ds)
+
+	ds = checkAction(`INSERT INTO users (id, name) VALUES ($id, $name);`)
+	assert.Equal(t, []string{CodeMissingNotNull}, diagCodes(ds))
+	assert.Equal(t, "column 'wallet' is notnull and has no default, but isn't inserted", ds[0].Message)
+}
+
+func TestCheckOnConflictTarget(t *testing.T) {
+	ds := checkAction(`INSERT INTO orders (id, user_id) VALUES (1, $id) ON CONFLICT (name, user_id) DO NOTHING;`)
+	assert.Empty(t, ds)
+
+	ds = checkAction(`INSERT INTO orders (id, user_id) VALUES (1, $id) ON CONFLICT (name) DO NOTHING;`)
+	assert.Equal(t, []string{CodeConflictTarget}, diagCodes(ds))
+
+	ds = checkAction(`INSERT INTO orders (id, user_id) VALUES (1, $id) ON CONFLICT DO UPDATE SET name = 'x';`)
+	assert.Equal(t, []string{CodeConflictTarget}, diagCodes(ds))
+}
+
+func TestCheckJoinTypes(t *testing.T) {
+	ds := checkAction(`SELECT u.name FROM users u JOIN orders o ON o.id = u.id;`)
+	assert.Equal(t, []string{CodeJoinTypeMismatch}, diagCodes(ds))
+	assert.Equal(t, "join compares 'o.id' of type int with 'u.id' of type uuid", ds[0].Message)
+}
+
+func TestCheckTableDecls(t *testing.T) {
+	ds := Check(ParseFile(`database d;
+		table a { id int, id text, #i index(nope) }
+		table a { b int, foreign_key (b) references c(id) }`))
+	assert.Equal(t, []string{CodeDuplicateColumn, CodeUnknownColumn, CodeDuplicateTable, CodeUnknownTable}, diagCodes(ds))
+}
+
+func TestCheckReportsSyntaxErrors(t *testing.T) {
+	text := "database d;\naction a() { SELECT * FROM }"
+	ds := Check(ParseFile(text))
+	assert.Equal(t, 1, len(ds))
+	assert.Equal(t, "expected table name", ds[0].Message)
+	assert.Equal(t, len(text)-2, ds[0].Start)
+}
+
+func TestCheckVars(t *testing.T) {
+	ds := Check(ParseFile(`database d;
+action a($x) { $z = $x + $w; $v = $z; SELECT $v, $w; }`))
+	assert.Equal(t, []string{CodeUndefinedVar}, diagCodes(ds))
+	assert.Equal(t, "undefined variable '$w'", ds[0].Message)
+
+	ds = Check(ParseFile(`database d;
+table t { id int primary }
+procedure p($n int) public {
+	for $i in 1..$n { if $i > $m { $s int := $i; } }
+	for $r in SELECT id FROM t WHERE id = $s { error($r.id || $i); }
+	DELETE FROM t WHERE id = $q;
+}`))
+	assert.Equal(t, []string{CodeUndefinedVar, CodeUndefinedVar}, diagCodes(ds))
+	assert.Equal(t, "undefined variable '$m'", ds[0].Message)
+	assert.Equal(t, "undefined variable '$q'", ds[1].Message)
+}
@@ -0,0 +1,379 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SchemaChange is a difference between two versions of a schema. Old and New
+// are the changed declarations, Old is nil for additions and New for removals.
+type SchemaChange struct {
+	Breaking bool
+	Message  string
+	Old      AstNode
+	New      AstNode
+}
+
+// CompareSchemas reports the differences between two versions of a schema
+// that matter for migrating a deployed database or its clients. Changes
+// existing data or callers may not survive are breaking.
+func CompareSchemas(old *FileRoot, new *FileRoot) []SchemaChange {
+	c := comparer{}
+	c.directives(old, new)
+	for _, ot := range old.TableDecls() {
+		nt := new.TableDecl(ot.Name())
+		if nt == nil {
+			c.change(true, ot, nil, "table '%s' removed", ot.Name())
+			continue
+		}
+		c.table(ot, nt)
+	}
+	for _, nt := range new.TableDecls() {
+		if old.TableDecl(nt.Name()) == nil {
+			c.change(false, nil, nt, "table '%s' added", nt.Name())
+		}
+	}
+	for _, oa := range old.ActionDecls() {
+		na := new.ActionDecl(oa.Name())
+		if na == nil {
+			c.change(true, oa, nil, "%s '%s' removed", oa.Kind(), oa.Name())
+			continue
+		}
+		c.action(oa, na)
+	}
+	for _, na := range new.ActionDecls() {
+		if old.ActionDecl(na.Name()) == nil {
+			c.change(false, nil, na, "%s '%s' added", na.Kind(), na.Name())
+		}
+	}
+	return c.changes
+}
+
+type comparer struct {
+	changes []SchemaChange
+}
+
+func (c *comparer) change(breaking bool, old AstNode, new AstNode, format string, args ...any) {
+	c.changes = append(c.changes, SchemaChange{
+		Breaking: breaking,
+		Message:  fmt.Sprintf(format, args...),
+		Old:      old,
+		New:      new,
+	})
+}
+
+func (c *comparer) directives(old *FileRoot, new *FileRoot) {
+	od, nd := old.DbDirective(), new.DbDirective()
+	if od != nil && nd != nil && !strings.EqualFold(od.Name(), nd.Name()) {
+		c.change(true, od, nd, "database renamed from '%s' to '%s'", od.Name(), nd.Name())
+	}
+
+	extKey := func(ed *ExtDirective) string {
+		if ed.Alias() != "" {
+			return strings.ToLower(ed.Alias())
+		}
+		return strings.ToLower(ed.Name())
+	}
+	newExts := map[string]*ExtDirective{}
+	for _, ed := range new.ExtDirectives() {
+		newExts[extKey(ed)] = ed
+	}
+	for _, oe := range old.ExtDirectives() {
+		ne := newExts[extKey(oe)]
+		delete(newExts, extKey(oe))
+		switch {
+		case ne == nil:
+			c.change(true, oe, nil, "extension '%s' removed", extKey(oe))
+		case !strings.EqualFold(oe.Name(), ne.Name()) || extParams(oe) != extParams(ne):
+			c.change(true, oe, ne, "extension '%s' changed", extKey(oe))
+		}
+	}
+	for _, ne := range new.ExtDirectives() {
+		if newExts[extKey(ne)] != nil {
+			c.change(false, nil, ne, "extension '%s' added", extKey(ne))
+		}
+	}
+}
+
+func extParams(ed *ExtDirective) string {
+	res := []string{}
+	for _, p := range ed.Params() {
+		value := ""
+		if p.Value() != nil {
+			value = inlineText(*p.Value())
+		}
+		res = append(res, p.Name()+": "+value)
+	}
+	return strings.Join(res, ", ")
+}
+
+func (c *comparer) table(ot *TableDecl, nt *TableDecl) {
+	for _, oc := range ot.Columns() {
+		nc := nt.Column(oc.Name())
+		if nc == nil {
+			c.change(true, oc, nil, "column '%s.%s' removed", ot.Name(), oc.Name())
+			continue
+		}
+		c.column(ot.Name(), oc, nc)
+	}
+	for _, nc := range nt.Columns() {
+		if ot.Column(nc.Name()) != nil {
+			continue
+		}
+		// existing rows get nulls in the new column
+		required := (nc.HasAttr("notnull") || columnAttr(nc, "PRIMARY_KEY") != nil) && columnAttr(nc, "DEFAULT") == nil
+		if required {
+			c.change(true, nil, nc, "required column '%s.%s' added without a default", nt.Name(), nc.Name())
+		} else {
+			c.change(false, nil, nc, "column '%s.%s' added", nt.Name(), nc.Name())
+		}
+	}
+
+	newIndexes := map[string]*IndexDecl{}
+	for _, ni := range nt.Indexes() {
+		newIndexes[strings.ToLower(ni.Name())] = ni
+	}
+	for _, oi := range ot.Indexes() {
+		ni := newIndexes[strings.ToLower(oi.Name())]
+		delete(newIndexes, strings.ToLower(oi.Name()))
+		switch {
+		case ni == nil:
+			c.change(false, oi, nil, "index '%s' on '%s' removed", oi.Name(), ot.Name())
+		case oi.Kind() != ni.Kind() || !slices.Equal(lowerAll(oi.Columns()), lowerAll(ni.Columns())):
+			c.change(ni.Kind() != "index", oi, ni, "index '%s' on '%s' changed", oi.Name(), ot.Name())
+		}
+	}
+	for _, ni := range nt.Indexes() {
+		if newIndexes[strings.ToLower(ni.Name())] != nil {
+			// existing rows may have duplicates
+			kind := strings.TrimPrefix(ni.Kind()+" index", "index ")
+			c.change(ni.Kind() != "index", nil, ni, "%s '%s' on '%s' added", kind, ni.Name(), nt.Name())
+		}
+	}
+
+	fkKey := func(fk *ForeignKeyDecl) string {
+		return strings.Join(lowerAll(fk.Columns()), ", ")
+	}
+	newFks := map[string]*ForeignKeyDecl{}
+	for _, fk := range nt.ForeignKeys() {
+		newFks[fkKey(fk)] = fk
+	}
+	for _, ofk := range ot.ForeignKeys() {
+		nfk := newFks[fkKey(ofk)]
+		delete(newFks, fkKey(ofk))
+		switch {
+		case nfk == nil:
+			c.change(false, ofk, nil, "foreign key (%s) on '%s' removed", fkKey(ofk), ot.Name())
+		case !strings.EqualFold(ofk.RefTable(), nfk.RefTable()) || !slices.Equal(lowerAll(ofk.RefColumns()), lowerAll(nfk.RefColumns())):
+			c.change(true, ofk, nfk, "foreign key (%s) on '%s' references %s(%s) instead of %s(%s)", fkKey(ofk), ot.Name(),
+				nfk.RefTable(), strings.Join(nfk.RefColumns(), ", "), ofk.RefTable(), strings.Join(ofk.RefColumns(), ", "))
+		case fkActionText(ofk) != fkActionText(nfk):
+			c.change(false, ofk, nfk, "foreign key (%s) on '%s' actions changed", fkKey(ofk), ot.Name())
+		}
+	}
+	for _, nfk := range nt.ForeignKeys() {
+		if newFks[fkKey(nfk)] != nil {
+			// existing rows may reference missing ones
+			c.change(true, nil, nfk, "foreign key (%s) on '%s' added", fkKey(nfk), nt.Name())
+		}
+	}
+}
+
+func lowerAll(names []string) []string {
+	res := []string{}
+	for _, n := range names {
+		res = append(res, strings.ToLower(n))
+	}
+	return res
+}
+
+func fkActionText(fk *ForeignKeyDecl) string {
+	res := []string{}
+	for _, a := range fk.Actions() {
+		res = append(res, a.Event()+" "+a.Action())
+	}
+	return strings.Join(res, " ")
+}
+
+// columnAttr finds an attribute by its schema type, so that aliases like pk
+// and primary are the same attribute.
+func columnAttr(cd *ColumnDecl, typ string) *ColumnAttr {
+	for _, a := range cd.Attrs() {
+		if schemaAttributes[a.Name()] == typ {
+			return a
+		}
+	}
+	return nil
+}
+
+func attrArg(a *ColumnAttr) string {
+	if a == nil || a.Arg() == nil {
+		return ""
+	}
+	return inlineText(*a.Arg())
+}
+
+func (c *comparer) column(table string, oc *ColumnDecl, nc *ColumnDecl) {
+	name := table + "." + oc.Name()
+	if ot, nt := typeText(oc.Type()), typeText(nc.Type()); ot != nt {
+		c.change(!widensType(oc.Type(), nc.Type()), oc, nc, "column '%s' type changed from %s to %s", name, ot, nt)
+	}
+
+	for _, typ := range []string{"PRIMARY_KEY", "UNIQUE", "NOT_NULL"} {
+		oa, na := columnAttr(oc, typ), columnAttr(nc, typ)
+		switch {
+		case oa == nil && na != nil:
+			c.change(true, oc, nc, "column '%s' is now %s", name, na.Name())
+		case oa != nil && na == nil:
+			c.change(false, oc, nc, "column '%s' is no longer %s", name, oa.Name())
+		}
+	}
+
+	od, nd := attrArg(columnAttr(oc, "DEFAULT")), attrArg(columnAttr(nc, "DEFAULT"))
+	switch {
+	case od == nd:
+	case nd == "":
+		// inserts relying on the default now get nulls
+		c.change(true, oc, nc, "column '%s' no longer has a default", name)
+	default:
+		c.change(false, oc, nc, "column '%s' default changed to %s", name, nd)
+	}
+
+	// a bound is tighter when a lower bound grows or an upper one shrinks
+	for _, b := range []struct {
+		typ   string
+		lower bool
+	}{{"MIN", true}, {"MAX", false}, {"MIN_LENGTH", true}, {"MAX_LENGTH", false}} {
+		oa, na := columnAttr(oc, b.typ), columnAttr(nc, b.typ)
+		ov, nv := attrArg(oa), attrArg(na)
+		switch {
+		case oa == nil && na == nil || ov == nv:
+		case na == nil:
+			c.change(false, oc, nc, "column '%s' no longer has %s", name, oa.Name())
+		case oa == nil:
+			c.change(true, oc, nc, "column '%s' now has %s(%s)", name, na.Name(), nv)
+		default:
+			o, oErr := strconv.ParseInt(ov, 10, 64)
+			n, nErr := strconv.ParseInt(nv, 10, 64)
+			tighter := oErr != nil || nErr != nil || (b.lower && n > o) || (!b.lower && n < o)
+			c.change(tighter, oc, nc, "column '%s' %s changed from %s to %s", name, na.Name(), ov, nv)
+		}
+	}
+}
+
+func typeText(tr *TypeRef) string {
+	if tr == nil {
+		return ""
+	}
+	return inlineText(tr)
+}
+
+// widensType reports whether all values of the old type fit into the new one.
+func widensType(old *TypeRef, new *TypeRef) bool {
+	if old == nil || new == nil || old.IsArray() != new.IsArray() {
+		return false
+	}
+	on, nn := baseTypeName(old), baseTypeName(new)
+	if on != "decimal" || nn != "decimal" {
+		return on == nn
+	}
+	oa, na := old.Args(), new.Args()
+	if len(oa) != 2 || len(na) != 2 {
+		return len(na) == 0
+	}
+	op, _ := strconv.Atoi(oa[0])
+	os, _ := strconv.Atoi(oa[1])
+	np, _ := strconv.Atoi(na[0])
+	ns, _ := strconv.Atoi(na[1])
+	return ns >= os && np-ns >= op-os
+}
+
+func (c *comparer) action(oa *ActionDecl, na *ActionDecl) {
+	name := oa.Name()
+	if oa.Kind() != na.Kind() {
+		article := map[bool]string{true: "a", false: "an"}[na.IsProcedure()]
+		c.change(true, oa, na, "%s '%s' is now %s %s", oa.Kind(), name, article, na.Kind())
+		return
+	}
+	kind := oa.Kind()
+
+	if paramsText(oa) != paramsText(na) {
+		c.change(true, oa, na, "%s '%s' parameters changed from (%s) to (%s)", kind, name, paramsText(oa), paramsText(na))
+	}
+	if returnsText(oa) != returnsText(na) {
+		c.change(true, oa, na, "%s '%s' returns changed from %s to %s", kind, name, returnsText(oa), returnsText(na))
+	}
+
+	switch {
+	case oa.HasModifier(T_PUBLIC) && !na.HasModifier(T_PUBLIC):
+		c.change(true, oa, na, "%s '%s' is now private", kind, name)
+	case !oa.HasModifier(T_PUBLIC) && na.HasModifier(T_PUBLIC):
+		c.change(false, oa, na, "%s '%s' is now public", kind, name)
+	}
+	switch {
+	case oa.HasModifier(T_VIEW) && !na.HasModifier(T_VIEW):
+		// calls to it now need a transaction
+		c.change(true, oa, na, "%s '%s' is no longer a view", kind, name)
+	case !oa.HasModifier(T_VIEW) && na.HasModifier(T_VIEW):
+		c.change(false, oa, na, "%s '%s' is now a view", kind, name)
+	}
+	switch {
+	case !oa.HasModifier(T_OWNER) && na.HasModifier(T_OWNER):
+		c.change(true, oa, na, "%s '%s' is now restricted to the owner", kind, name)
+	case oa.HasModifier(T_OWNER) && !na.HasModifier(T_OWNER):
+		c.change(false, oa, na, "%s '%s' is no longer restricted to the owner", kind, name)
+	}
+
+	if annotationsText(oa) != annotationsText(na) {
+		c.change(false, oa, na, "%s '%s' annotations changed", kind, name)
+	}
+	if formatBody(oa) != formatBody(na) {
+		c.change(false, oa, na, "%s '%s' body changed", kind, name)
+	}
+}
+
+func paramsText(ad *ActionDecl) string {
+	res := []string{}
+	for _, p := range ad.Params() {
+		res = append(res, strings.TrimSpace(strings.ToLower(p.Name())+" "+typeText(p.Type())))
+	}
+	return strings.Join(res, ", ")
+}
+
+func returnsText(ad *ActionDecl) string {
+	rc := ad.Returns()
+	if rc == nil {
+		return "()"
+	}
+	res := []string{}
+	for _, c := range rc.Columns() {
+		res = append(res, strings.TrimSpace(strings.ToLower(c.Name())+" "+typeText(c.Type())))
+	}
+	if rc.IsTable() {
+		return "table(" + strings.Join(res, ", ") + ")"
+	}
+	return "(" + strings.Join(res, ", ") + ")"
+}
+
+func annotationsText(ad *ActionDecl) string {
+	res := []string{}
+	for _, an := range ad.Annotations() {
+		res = append(res, an.String())
+	}
+	return strings.Join(res, " ")
+}
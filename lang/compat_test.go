@@ -0,0 +1,137 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func compareSchemas(old string, new string) []string {
+	res := []string{}
+	for _, c := range CompareSchemas(ParseFile(old), ParseFile(new)) {
+		res = append(res, fmt.Sprintf("%v %s", c.Breaking, c.Message))
+	}
+	return res
+}
+
+func TestCompareSchemasSame(t *testing.T) {
+	text := `database d;
+table t { id int primary, #i index(id) }
+action a($x) public view { SELECT * FROM t WHERE id = $x; }`
+	assert.Empty(t, compareSchemas(text, "database d;\n\ntable t {\n  ID int PK,\n  #i index(id)\n}\n\naction a($x) public view {\n    select * from t where id = $x;\n}"))
+	assert.Empty(t, compareSchemas(text, text))
+}
+
+func TestCompareSchemasTables(t *testing.T) {
+	old := `database d;
+use erc20 { address: '0x1' } as token;
+table users {
+	id int primary,
+	name text maxlen(50),
+	age int min(0),
+	score decimal(5, 2),
+	email text unique,
+	tags text[],
+	#name_idx index(name),
+	#email_idx index(email)
+}
+table posts {
+	id int primary,
+	author int,
+	foreign_key (author) references users(id) on_delete cascade
+}
+table logs { id int }`
+	new := `database d;
+use erc20 { address: '0x2' } as token;
+table users {
+	id int primary,
+	name text notnull maxlen(40) default(''),
+	age int min(18),
+	score decimal(10, 3),
+	email text,
+	tags text,
+	created int notnull,
+	bio text,
+	#name_idx unique(name),
+	#age_idx index(age),
+	#score_idx unique(score)
+}
+table posts {
+	id int primary,
+	author int,
+	foreign_key (author) references users(id) on_delete set_null
+}
+table comments { id int primary }`
+	assert.Equal(t, []string{
+		"true extension 'token' changed",
+		"true column 'users.name' is now notnull",
+		"false column 'users.name' default changed to ''",
+		"true column 'users.name' maxlen changed from 50 to 40",
+		"true column 'users.age' min changed from 0 to 18",
+		"false column 'users.score' type changed from decimal(5, 2) to decimal(10, 3)",
+		"false column 'users.email' is no longer unique",
+		"true column 'users.tags' type changed from text[] to text",
+		"true required column 'users.created' added without a default",
+		"false column 'users.bio' added",
+		"true index 'name_idx' on 'users' changed",
+		"false index 'email_idx' on 'users' removed",
+		"false index 'age_idx' on 'users' added",
+		"true unique index 'score_idx' on 'users' added",
+		"false foreign key (author) on 'posts' actions changed",
+		"true table 'logs' removed",
+		"false table 'comments' added",
+	}, compareSchemas(old, new))
+}
+
+func TestCompareSchemasActions(t *testing.T) {
+	old := `database d;
+table t { id int }
+action get($id) public view { SELECT * FROM t WHERE id = $id; }
+action add($id) public { INSERT INTO t VALUES ($id); }
+action admin() private owner { DELETE FROM t; }
+procedure count() public view returns (n int) { return 1; }
+action old() public {}
+procedure kind() public {}
+action back() public {}`
+	new := `database d;
+table t { id int }
+action get($id, $max) private { SELECT * FROM t WHERE id = $id; }
+@kgw(authn='true')
+action add($id) public view { INSERT INTO t VALUES ($id + 1); }
+action admin() public {}
+procedure count() public view returns table(n int) { return next 1; }
+action kind() public {}
+procedure back() public {}
+action fresh() public {}`
+	assert.Equal(t, []string{
+		"true action 'get' parameters changed from ($id) to ($id, $max)",
+		"true action 'get' is now private",
+		"true action 'get' is no longer a view",
+		"false action 'add' is now a view",
+		"false action 'add' annotations changed",
+		"false action 'add' body changed",
+		"false action 'admin' is now public",
+		"false action 'admin' is no longer restricted to the owner",
+		"false action 'admin' body changed",
+		"true procedure 'count' returns changed from (n int) to table(n int)",
+		"false procedure 'count' body changed",
+		"true action 'old' removed",
+		"true procedure 'kind' is now an action",
+		"true action 'back' is now a procedure",
+		"false action 'fresh' added",
+	}, compareSchemas(old, new))
+}
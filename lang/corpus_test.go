@@ -0,0 +1,95 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "regenerate golden files of the testdata corpus")
+
+// TestCorpus runs every testdata/**/*.kf file through the lexer, parser and
+// checker, and compares the tree and the diagnostics with the .tree and .diag
+// files next to it. Run `go test ./lang -run TestCorpus -update` to regenerate them.
+func TestCorpus(t *testing.T) {
+	files, err := filepath.Glob("testdata/*/*.kf")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	for _, f := range files {
+		if strings.HasPrefix(f, filepath.Join("testdata", "plpgsql")) || strings.HasPrefix(f, filepath.Join("testdata", "gen")) {
+			// translation goldens, see TestGeneratePlpgsql and TestGenerateGo
+			continue
+		}
+		t.Run(strings.TrimSuffix(f, ".kf"), func(t *testing.T) {
+			b, err := os.ReadFile(f)
+			assert.NoError(t, err)
+			text := string(b)
+
+			checkTokens(t, text)
+
+			fr := ParseFile(text)
+			assert.Equal(t, text, fr.Text())
+
+			base := strings.TrimSuffix(f, ".kf")
+			golden(t, base+".tree", Dump(fr))
+			golden(t, base+".diag", dumpDiagnostics(text, Check(fr)))
+
+			if strings.HasPrefix(f, filepath.Join("testdata", "valid")) {
+				assert.Empty(t, Check(fr))
+			}
+		})
+	}
+}
+
+// checkTokens verifies that tokens cover the text without gaps or overlaps.
+func checkTokens(t *testing.T, text string) {
+	pos := 0
+	for _, tok := range tokenize(text) {
+		assert.Equal(t, pos, tok.start)
+		assert.Equal(t, text[tok.start:tok.end], tok.text)
+		pos = tok.end
+	}
+	assert.Equal(t, len(text), pos)
+}
+
+func dumpDiagnostics(text string, ds []Diagnostic) string {
+	li := NewLineIndex(text)
+	sb := strings.Builder{}
+	for _, d := range ds {
+		s := li.Position(d.Start)
+		e := li.Position(d.End)
+		fmt.Fprintf(&sb, "%d:%d-%d:%d: %s: %s [%s]\n", s.Line+1, s.Character+1, e.Line+1, e.Character+1, d.Severity, d.Message, d.Code)
+	}
+	return sb.String()
+}
+
+func golden(t *testing.T, path string, actual string) {
+	if *update {
+		assert.NoError(t, os.WriteFile(path, []byte(actual), 0644))
+		return
+	}
+	b, err := os.ReadFile(path)
+	if !assert.NoError(t, err, "missing golden file, run with -update to create it") {
+		return
+	}
+	assert.Equal(t, string(b), actual)
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var decompiledAttributes = map[string]string{
+	"PRIMARY_KEY": "primary", "UNIQUE": "unique", "NOT_NULL": "notnull", "DEFAULT": "default",
+	"MIN": "min", "MAX": "max", "MIN_LENGTH": "minlen", "MAX_LENGTH": "maxlen",
+}
+
+var decompiledIndexTypes = map[string]string{
+	"BTREE": "index", "UNIQUE_BTREE": "unique", "PRIMARY": "primary",
+}
+
+var decompiledFkEvents = map[string]string{
+	"DELETE": "on_delete", "UPDATE": "on_update",
+}
+
+var decompiledFkActions = map[string]string{
+	"CASCADE": "cascade", "RESTRICT": "restrict", "SET NULL": "set_null", "SET DEFAULT": "set_default", "NO ACTION": "no_action",
+}
+
+var decompiledModifiers = map[string]string{
+	"VIEW": "view", "OWNER": "owner",
+}
+
+// Decompile converts a schema back to Kuneiform source, formatted as by
+// Format. The owner isn't part of the source, so it's lost.
+func Decompile(s *Schema) (string, error) {
+	d := decompiler{}
+	d.schema(s)
+	if len(d.errs) > 0 {
+		return "", errors.Join(d.errs...)
+	}
+
+	fr := ParseFile(d.sb.String())
+	if errs := fr.SyntaxErrors(); len(errs) > 0 {
+		li := NewLineIndex(d.sb.String())
+		pos := li.Position(errs[0].Start)
+		return "", fmt.Errorf("the schema has invalid code, %d:%d: %s", pos.Line+1, pos.Character+1, errs[0].Message)
+	}
+	return Format(fr, DefaultFormatOptions)
+}
+
+type decompiler struct {
+	sb   strings.Builder
+	errs []error
+}
+
+func (d *decompiler) write(format string, args ...any) {
+	fmt.Fprintf(&d.sb, format, args...)
+}
+
+func (d *decompiler) lookup(m map[string]string, key string, what string, owner string) string {
+	res, ok := m[key]
+	if !ok {
+		d.errs = append(d.errs, fmt.Errorf("%s: unknown %s %q", owner, what, key))
+	}
+	return res
+}
+
+func (d *decompiler) schema(s *Schema) {
+	d.write("database %s;\n", s.Name)
+	for _, e := range s.Extensions {
+		params := []string{}
+		for _, c := range e.Initialization {
+			params = append(params, c.Key+": "+c.Value)
+		}
+		d.write("use %s", e.Name)
+		if len(params) > 0 {
+			d.write(" { %s }", strings.Join(params, ", "))
+		}
+		if e.Alias != "" {
+			d.write(" as %s", e.Alias)
+		}
+		d.write(";\n")
+	}
+	for _, t := range s.Tables {
+		d.table(t)
+	}
+	for _, a := range s.Actions {
+		d.annotations(a.Annotations)
+		d.write("action %s(%s) %s {\n%s\n}\n", a.Name, strings.Join(a.Parameters, ", "), d.modifiers(a.Public, a.Modifiers, a.Name), a.Body)
+	}
+	for _, p := range s.Procedures {
+		d.annotations(p.Annotations)
+		params := []string{}
+		for _, pp := range p.Parameters {
+			params = append(params, pp.Name+" "+d.dataType(pp.Type, p.Name))
+		}
+		d.write("procedure %s(%s) %s", p.Name, strings.Join(params, ", "), d.modifiers(p.Public, p.Modifiers, p.Name))
+		if p.Returns != nil {
+			d.write(" returns ")
+			if p.Returns.IsTable {
+				d.write("table")
+			}
+			fields := []string{}
+			for _, f := range p.Returns.Fields {
+				fields = append(fields, strings.TrimSpace(f.Name+" "+d.dataType(f.Type, p.Name)))
+			}
+			d.write("(%s)", strings.Join(fields, ", "))
+		}
+		d.write(" {\n%s\n}\n", p.Body)
+	}
+}
+
+func (d *decompiler) table(t *Table) {
+	items := []string{}
+	for _, c := range t.Columns {
+		item := c.Name + " " + d.dataType(c.Type, t.Name)
+		for _, a := range c.Attributes {
+			item += " " + d.lookup(decompiledAttributes, a.Type, "attribute", t.Name+"."+c.Name)
+			if a.Value != "" {
+				item += "(" + a.Value + ")"
+			}
+		}
+		items = append(items, item)
+	}
+	for _, i := range t.Indexes {
+		items = append(items, fmt.Sprintf("#%s %s(%s)", i.Name, d.lookup(decompiledIndexTypes, i.Type, "index type", t.Name), strings.Join(i.Columns, ", ")))
+	}
+	for _, fk := range t.ForeignKeys {
+		item := fmt.Sprintf("foreign_key (%s) references %s(%s)", strings.Join(fk.ChildKeys, ", "), fk.ParentTable, strings.Join(fk.ParentKeys, ", "))
+		for _, a := range fk.Actions {
+			item += " " + d.lookup(decompiledFkEvents, a.On, "foreign key event", t.Name) + " " + d.lookup(decompiledFkActions, a.Do, "foreign key action", t.Name)
+		}
+		items = append(items, item)
+	}
+	d.write("table %s {\n%s\n}\n", t.Name, strings.Join(items, ",\n"))
+}
+
+func (d *decompiler) dataType(dt *DataType, owner string) string {
+	if dt == nil {
+		d.errs = append(d.errs, fmt.Errorf("%s: missing type", owner))
+		return ""
+	}
+	if !schemaTypes[dt.Name] {
+		d.errs = append(d.errs, fmt.Errorf("%s: unknown type %q", owner, dt.Name))
+	}
+	res := dt.Name
+	if dt.Metadata != [2]uint16{} {
+		res += fmt.Sprintf("(%d, %d)", dt.Metadata[0], dt.Metadata[1])
+	}
+	if dt.IsArray {
+		res += "[]"
+	}
+	return res
+}
+
+func (d *decompiler) annotations(as []string) {
+	for _, a := range as {
+		d.write("@%s\n", a)
+	}
+}
+
+func (d *decompiler) modifiers(public bool, mods []string, owner string) string {
+	res := []string{"private"}
+	if public {
+		res[0] = "public"
+	}
+	for _, m := range mods {
+		res = append(res, d.lookup(decompiledModifiers, m, "modifier", owner))
+	}
+	return strings.Join(res, " ")
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompileRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("testdata/*/*.kf")
+	require.NoError(t, err)
+	compiled := 0
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			text, err := os.ReadFile(file)
+			require.NoError(t, err)
+			s, diags := Compile(ParseFile(string(text)))
+			if len(diags) > 0 {
+				t.Skip("doesn't compile")
+			}
+			compiled++
+
+			src, err := Decompile(s)
+			require.NoError(t, err)
+			s2, diags := Compile(ParseFile(src))
+			require.Empty(t, diags, src)
+			j1, err := json.Marshal(s)
+			require.NoError(t, err)
+			j2, err := json.Marshal(s2)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(j1), string(j2))
+
+			src2, err := Decompile(s2)
+			require.NoError(t, err)
+			assert.Equal(t, src, src2)
+		})
+	}
+	assert.NotZero(t, compiled)
+}
+
+func TestDecompile(t *testing.T) {
+	s := &Schema{
+		Name:       "shop",
+		Extensions: []*Extension{{Name: "erc20", Alias: "token", Initialization: []*ExtensionConfig{{Key: "address", Value: "'0x1'"}}}},
+		Tables: []*Table{{
+			Name: "users",
+			Columns: []*Column{
+				{Name: "id", Type: &DataType{Name: "uuid"}, Attributes: []*Attribute{{Type: "PRIMARY_KEY"}}},
+				{Name: "price", Type: &DataType{Name: "decimal", IsArray: true, Metadata: [2]uint16{10, 2}}, Attributes: []*Attribute{{Type: "DEFAULT", Value: "'x'"}}},
+			},
+			Indexes:     []*Index{{Name: "price_idx", Columns: []string{"price"}, Type: "BTREE"}},
+			ForeignKeys: []*ForeignKey{{ChildKeys: []string{"id"}, ParentKeys: []string{"id"}, ParentTable: "users", Actions: []*ForeignKeyAction{{On: "DELETE", Do: "SET NULL"}}}},
+		}},
+		Actions: []*Action{{
+			Name: "get", Annotations: []string{"kgw(authn='true')"}, Parameters: []string{"$id"}, Public: true, Modifiers: []string{"VIEW"},
+			Body: "SELECT * FROM users WHERE id = $id;",
+		}},
+		Procedures: []*Procedure{{
+			Name: "one", Parameters: []*ProcedureParameter{{Name: "$x", Type: &DataType{Name: "int"}}},
+			Returns: &ProcedureReturn{IsTable: true, Fields: []*NamedType{{Name: "y", Type: &DataType{Name: "int"}}}},
+			Body:    "return next $x;",
+		}},
+	}
+	src, err := Decompile(s)
+	require.NoError(t, err)
+	assert.Equal(t, `database shop;
+
+use erc20 { address: '0x1' } as token;
+
+table users {
+    id    uuid             primary,
+    price decimal(10, 2)[] default('x'),
+    #price_idx index(price),
+    foreign_key (id) references users(id) on_delete set_null
+}
+
+@kgw(authn='true')
+action get($id) public view {
+    SELECT * FROM users WHERE id = $id;
+}
+
+procedure one($x int) private returns table(y int) {
+    return next $x;
+}
+`, src)
+}
+
+func TestDecompileErrors(t *testing.T) {
+	_, err := Decompile(&Schema{Name: "db", Tables: []*Table{{Name: "t", Columns: []*Column{{Name: "c", Type: &DataType{Name: "float"}}}}}})
+	assert.ErrorContains(t, err, `t: unknown type "float"`)
+
+	_, err = Decompile(&Schema{Name: "db", Actions: []*Action{{Name: "a", Body: "select from;"}}})
+	assert.ErrorContains(t, err, "the schema has invalid code")
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import "sort"
+
+type Severity int
+
+// Severity values match the ones used by the language server protocol.
+const (
+	SevError   Severity = 1
+	SevWarning Severity = 2
+	SevInfo    Severity = 3
+	SevHint    Severity = 4
+)
+
+type Diagnostic struct {
+	Start    int
+	End      int
+	Severity Severity
+	Code     string
+	Message  string
+	Fixes    []Fix
+}
+
+// Fix is a quick fix for a diagnostic.
+type Fix struct {
+	Title string
+	Edits []TextEdit
+}
+
+func replaceFix(title string, start int, end int, text string) Fix {
+	return Fix{
+		Title: title,
+		Edits: []TextEdit{{Start: start, End: end, NewText: text}},
+	}
+}
+
+func (s Severity) String() string {
+	switch s {
+	case SevError:
+		return "error"
+	case SevWarning:
+		return "warning"
+	case SevInfo:
+		return "info"
+	default:
+		return "hint"
+	}
+}
+
+func sortDiagnostics(ds []Diagnostic) {
+	sort.SliceStable(ds, func(i, j int) bool {
+		if ds[i].Start != ds[j].Start {
+			return ds[i].Start < ds[j].Start
+		}
+		return ds[i].End < ds[j].End
+	})
+}
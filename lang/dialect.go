@@ -0,0 +1,59 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import "fmt"
+
+const CodeDialect = "dialect"
+
+// Dialect is the version of Kwil a schema targets.
+type Dialect string
+
+const (
+	// DialectKwil07 has actions, but not the procedures that came with 0.8.
+	DialectKwil07 Dialect = "kwil-0.7"
+	DialectKwil08 Dialect = "kwil-0.8"
+)
+
+// DefaultDialect is the latest version of Kwil.
+const DefaultDialect = DialectKwil08
+
+// Dialects are the versions of Kwil schemas can target, oldest first.
+var Dialects = []Dialect{DialectKwil07, DialectKwil08}
+
+// CheckDialect reports what a file uses that its target version of Kwil
+// lacks. An empty dialect is the default one.
+func CheckDialect(fr *FileRoot, d Dialect) []Diagnostic {
+	res := []Diagnostic{}
+	if d != DialectKwil07 {
+		return res
+	}
+	for _, ad := range fr.ActionDecls() {
+		if !ad.IsProcedure() {
+			continue
+		}
+		var n AstNode = ad
+		if t := findTok(ad.Children(), T_PROCEDURE); t != nil {
+			n = t
+		}
+		res = append(res, Diagnostic{
+			Start:    n.Start(),
+			End:      n.End(),
+			Severity: SevError,
+			Code:     CodeDialect,
+			Message:  fmt.Sprintf("procedures need Kwil 0.8 or later, the target is %s", d),
+		})
+	}
+	return res
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDialect(t *testing.T) {
+	text := "database d;\naction a() public {}\nprocedure p() public {}\n"
+	fr := ParseFile(text)
+	assert.Empty(t, CheckDialect(fr, ""))
+	assert.Empty(t, CheckDialect(fr, DialectKwil08))
+
+	diags := CheckDialect(fr, DialectKwil07)
+	if assert.Len(t, diags, 1) {
+		assert.Equal(t, CodeDialect, diags[0].Code)
+		assert.Equal(t, "procedure", text[diags[0].Start:diags[0].End])
+		assert.Equal(t, "procedures need Kwil 0.8 or later, the target is kwil-0.7", diags[0].Message)
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+type DocFormat int
+
+const (
+	DocMarkdown DocFormat = iota
+	DocHTML
+)
+
+// GenerateDocs returns the reference documentation of a file: its tables with
+// their columns and relationships, an entity-relationship diagram in Mermaid
+// format, and the signatures of its actions and procedures, described by their
+// doc comments. Files with errors produce diagnostics instead.
+func GenerateDocs(fr *FileRoot, format DocFormat) (string, []Diagnostic) {
+	if diags := Check(fr); len(diags) > 0 {
+		return "", diags
+	}
+	var w docWriter = &mdWriter{}
+	if format == DocHTML {
+		w = &htmlWriter{}
+	}
+
+	title := "Schema"
+	if dd := fr.DbDirective(); dd != nil && dd.Name() != "" {
+		title = "Database " + dd.Name()
+	}
+	w.heading(1, "", title)
+
+	if eds := fr.ExtDirectives(); len(eds) > 0 {
+		w.heading(2, "extensions", "Extensions")
+		items := []string{}
+		for _, ed := range eds {
+			item := ed.Name()
+			if ed.Alias() != "" {
+				item += " as " + ed.Alias()
+			}
+			if params := extParams(ed); params != "" {
+				item += " (" + params + ")"
+			}
+			items = append(items, item)
+		}
+		w.list(items)
+	}
+
+	if tds := fr.TableDecls(); len(tds) > 0 {
+		w.heading(2, "tables", "Tables")
+		for _, td := range tds {
+			docTable(w, fr, td)
+		}
+		w.heading(2, "relationships", "Relationships")
+		w.code("mermaid", erDiagram(fr))
+	}
+
+	for _, procedures := range []bool{false, true} {
+		ads := []*ActionDecl{}
+		for _, ad := range fr.ActionDecls() {
+			if ad.IsProcedure() == procedures {
+				ads = append(ads, ad)
+			}
+		}
+		if len(ads) == 0 {
+			continue
+		}
+		if procedures {
+			w.heading(2, "procedures", "Procedures")
+		} else {
+			w.heading(2, "actions", "Actions")
+		}
+		for _, ad := range ads {
+			docAction(w, ad)
+		}
+	}
+	return w.finish(title), nil
+}
+
+func docTable(w docWriter, fr *FileRoot, td *TableDecl) {
+	w.heading(3, "table-"+strings.ToLower(td.Name()), td.Name())
+	if doc := td.DocComment(); doc != "" {
+		w.paragraph(doc)
+	}
+
+	rows := [][]string{}
+	for _, cd := range td.Columns() {
+		attrs := []string{}
+		for _, a := range cd.Attrs() {
+			attrs = append(attrs, inlineText(a))
+		}
+		rows = append(rows, []string{cd.Name(), typeText(cd.Type()), strings.Join(attrs, " "), cd.DocComment()})
+	}
+	w.table([]string{"Column", "Type", "Constraints", "Description"}, rows)
+
+	if ids := td.Indexes(); len(ids) > 0 {
+		items := []string{}
+		for _, id := range ids {
+			items = append(items, fmt.Sprintf("%s: %s (%s)", id.Name(), id.Kind(), strings.Join(id.Columns(), ", ")))
+		}
+		w.paragraph("Indexes:")
+		w.list(items)
+	}
+
+	if fks := td.ForeignKeys(); len(fks) > 0 {
+		items := []string{}
+		for _, fk := range fks {
+			item := fmt.Sprintf("(%s) → %s(%s)", strings.Join(fk.Columns(), ", "), fk.RefTable(), strings.Join(fk.RefColumns(), ", "))
+			for _, a := range fk.Actions() {
+				item += ", " + strings.ReplaceAll(a.Event()+" "+a.Action(), "_", " ")
+			}
+			items = append(items, item)
+		}
+		w.paragraph("References:")
+		w.list(items)
+	}
+
+	refs := []string{}
+	for _, other := range fr.TableDecls() {
+		for _, fk := range other.ForeignKeys() {
+			if strings.EqualFold(fk.RefTable(), td.Name()) {
+				refs = append(refs, fmt.Sprintf("%s(%s)", other.Name(), strings.Join(fk.Columns(), ", ")))
+			}
+		}
+	}
+	if len(refs) > 0 {
+		w.paragraph("Referenced by:")
+		w.list(refs)
+	}
+}
+
+// erDiagram returns a Mermaid diagram of the tables with a relationship for
+// every foreign key.
+func erDiagram(fr *FileRoot) string {
+	sb := strings.Builder{}
+	sb.WriteString("erDiagram\n")
+	for _, td := range fr.TableDecls() {
+		fkCols := map[string]bool{}
+		for _, fk := range td.ForeignKeys() {
+			for _, c := range fk.Columns() {
+				fkCols[strings.ToLower(c)] = true
+			}
+		}
+		fmt.Fprintf(&sb, "    %s {\n", td.Name())
+		for _, cd := range td.Columns() {
+			keys := []string{}
+			if cd.HasAttr("primary") || cd.HasAttr("pk") {
+				keys = append(keys, "PK")
+			}
+			if cd.HasAttr("unique") {
+				keys = append(keys, "UK")
+			}
+			if fkCols[strings.ToLower(cd.Name())] {
+				keys = append(keys, "FK")
+			}
+			line := mermaidType(cd.Type()) + " " + cd.Name()
+			if len(keys) > 0 {
+				line += " " + strings.Join(keys, ", ")
+			}
+			if doc, _, _ := strings.Cut(cd.DocComment(), "\n"); doc != "" {
+				line += ` "` + strings.ReplaceAll(doc, `"`, "'") + `"`
+			}
+			fmt.Fprintf(&sb, "        %s\n", line)
+		}
+		sb.WriteString("    }\n")
+	}
+	for _, td := range fr.TableDecls() {
+		for _, fk := range td.ForeignKeys() {
+			// the parent is optional when the key may be null, and the child
+			// is single when the key is unique
+			parent := "||"
+			for _, c := range fk.Columns() {
+				if cd := td.Column(c); cd != nil && !cd.HasAttr("notnull") && !cd.HasAttr("primary") && !cd.HasAttr("pk") {
+					parent = "|o"
+				}
+			}
+			child := "o{"
+			if isUniqueKey(td, fk.Columns()) {
+				child = "o|"
+			}
+			fmt.Fprintf(&sb, "    %s %s--%s %s : \"%s\"\n", fk.RefTable(), parent, child, td.Name(), strings.Join(fk.Columns(), ", "))
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// mermaidType returns a type the way Mermaid accepts it in an entity, which
+// doesn't allow spaces or commas, e.g. decimal_10_2 for decimal(10, 2).
+func mermaidType(tr *TypeRef) string {
+	return strings.NewReplacer(" ", "", "(", "_", ",", "_", ")", "").Replace(typeText(tr))
+}
+
+func docAction(w docWriter, ad *ActionDecl) {
+	w.heading(3, ad.Kind()+"-"+strings.ToLower(ad.Name()), ad.Name())
+	if doc := ad.DocComment(); doc != "" {
+		w.paragraph(doc)
+	}
+	w.code("kuneiform", signature(ad))
+
+	access := "private"
+	if ad.HasModifier(T_PUBLIC) {
+		access = "public"
+	}
+	if ad.HasModifier(T_OWNER) {
+		access += ", owner only"
+	}
+	readOnly := "no"
+	if ad.HasModifier(T_VIEW) {
+		readOnly = "yes"
+	}
+	w.list([]string{"Access: " + access, "Read-only: " + readOnly})
+}
+
+// signature returns the declaration of an action without its body.
+func signature(ad *ActionDecl) string {
+	sb := strings.Builder{}
+	for _, an := range ad.Annotations() {
+		sb.WriteString("@" + an.String() + "\n")
+	}
+	params := []string{}
+	for _, pd := range ad.Params() {
+		params = append(params, inlineText(pd))
+	}
+	fmt.Fprintf(&sb, "%s %s(%s)", ad.Kind(), ad.Name(), strings.Join(params, ", "))
+	for _, m := range ad.Modifiers() {
+		sb.WriteString(" " + string(m))
+	}
+	if rc := ad.Returns(); rc != nil {
+		sb.WriteString(" " + inlineText(rc))
+	}
+	return sb.String()
+}
+
+type docWriter interface {
+	heading(level int, id string, text string)
+	paragraph(text string)
+	list(items []string)
+	table(header []string, rows [][]string)
+	code(lang string, text string)
+	finish(title string) string
+}
+
+type mdWriter struct {
+	sb strings.Builder
+}
+
+func (w *mdWriter) heading(level int, id string, text string) {
+	fmt.Fprintf(&w.sb, "%s %s\n\n", strings.Repeat("#", level), text)
+}
+
+func (w *mdWriter) paragraph(text string) {
+	w.sb.WriteString(text + "\n\n")
+}
+
+func (w *mdWriter) list(items []string) {
+	for _, i := range items {
+		w.sb.WriteString("- " + i + "\n")
+	}
+	w.sb.WriteString("\n")
+}
+
+func (w *mdWriter) table(header []string, rows [][]string) {
+	row := func(cells []string) {
+		for _, c := range cells {
+			c = strings.ReplaceAll(strings.ReplaceAll(c, "|", `\|`), "\n", " ")
+			w.sb.WriteString("| " + c + " ")
+		}
+		w.sb.WriteString("|\n")
+	}
+	row(header)
+	w.sb.WriteString(strings.Repeat("| --- ", len(header)) + "|\n")
+	for _, r := range rows {
+		row(r)
+	}
+	w.sb.WriteString("\n")
+}
+
+func (w *mdWriter) code(lang string, text string) {
+	fmt.Fprintf(&w.sb, "```%s\n%s\n```\n\n", lang, text)
+}
+
+func (w *mdWriter) finish(title string) string {
+	return strings.TrimSuffix(w.sb.String(), "\n")
+}
+
+type htmlWriter struct {
+	sb      strings.Builder
+	mermaid bool
+}
+
+func (w *htmlWriter) heading(level int, id string, text string) {
+	if id != "" {
+		id = fmt.Sprintf(` id="%s"`, html.EscapeString(id))
+	}
+	fmt.Fprintf(&w.sb, "<h%d%s>%s</h%d>\n", level, id, html.EscapeString(text), level)
+}
+
+func (w *htmlWriter) paragraph(text string) {
+	for _, p := range strings.Split(text, "\n\n") {
+		w.sb.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(p), "\n", "<br>") + "</p>\n")
+	}
+}
+
+func (w *htmlWriter) list(items []string) {
+	w.sb.WriteString("<ul>\n")
+	for _, i := range items {
+		w.sb.WriteString("<li>" + html.EscapeString(i) + "</li>\n")
+	}
+	w.sb.WriteString("</ul>\n")
+}
+
+func (w *htmlWriter) table(header []string, rows [][]string) {
+	row := func(tag string, cells []string) {
+		w.sb.WriteString("<tr>")
+		for _, c := range cells {
+			fmt.Fprintf(&w.sb, "<%s>%s</%s>", tag, strings.ReplaceAll(html.EscapeString(c), "\n", "<br>"), tag)
+		}
+		w.sb.WriteString("</tr>\n")
+	}
+	w.sb.WriteString("<table>\n<thead>\n")
+	row("th", header)
+	w.sb.WriteString("</thead>\n<tbody>\n")
+	for _, r := range rows {
+		row("td", r)
+	}
+	w.sb.WriteString("</tbody>\n</table>\n")
+}
+
+func (w *htmlWriter) code(lang string, text string) {
+	if lang == "mermaid" {
+		w.mermaid = true
+		w.sb.WriteString(`<pre class="mermaid">` + "\n" + html.EscapeString(text) + "\n</pre>\n")
+		return
+	}
+	w.sb.WriteString("<pre><code>" + html.EscapeString(text) + "</code></pre>\n")
+}
+
+func (w *htmlWriter) finish(title string) string {
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	if w.mermaid {
+		// renders the diagrams, which stay readable as text without it
+		sb.WriteString("<script type=\"module\">\nimport mermaid from \"https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.esm.min.mjs\";\nmermaid.initialize({ startOnLoad: true });\n</script>\n")
+	}
+	sb.WriteString("</head>\n<body>\n")
+	sb.WriteString(w.sb.String())
+	sb.WriteString("</body>\n</html>\n")
+	return sb.String()
+}
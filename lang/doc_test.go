@@ -0,0 +1,44 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGenerateDocs compares the documentation generated from
+// testdata/gen/shop.kf with shop.md and shop.html.
+func TestGenerateDocs(t *testing.T) {
+	text, err := os.ReadFile("testdata/gen/shop.kf")
+	require.NoError(t, err)
+	md, diags := GenerateDocs(ParseFile(string(text)), DocMarkdown)
+	require.Empty(t, diags)
+	golden(t, "testdata/gen/shop.md", md)
+	html, diags := GenerateDocs(ParseFile(string(text)), DocHTML)
+	require.Empty(t, diags)
+	golden(t, "testdata/gen/shop.html", html)
+}
+
+func TestMermaidType(t *testing.T) {
+	fr := ParseFile("database d; table t { a decimal(10, 2), b text[], c int }")
+	types := []string{}
+	for _, cd := range fr.TableDecls()[0].Columns() {
+		types = append(types, mermaidType(cd.Type()))
+	}
+	assert.Equal(t, []string{"decimal_10_2", "text[]", "int"}, types)
+}
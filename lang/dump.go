@@ -0,0 +1,103 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var punctNames = map[TokKind]string{
+	T_COLON: "COLON", T_SEMICOLON: "SEMICOLON", T_LPAREN: "LPAREN", T_RPAREN: "RPAREN",
+	T_LBRACE: "LBRACE", T_RBRACE: "RBRACE", T_COMMA: "COMMA", T_DOLLAR: "DOLLAR", T_HASH: "HASH",
+	T_AT: "AT", T_DOT: "DOT", T_ASSIGN: "ASSIGN", T_PLUS: "PLUS", T_MINUS: "MINUS", T_STAR: "STAR",
+	T_DIV: "DIV", T_MOD: "MOD", T_TILDE: "TILDE", T_LOGIC_OR: "LOGIC_OR", T_LSHIFT: "LSHIFT",
+	T_RSHIFT: "RSHIFT", T_AND: "AMP", T_OR: "PIPE", T_EQ: "EQ", T_LESS: "LESS", T_LESS_EQ: "LESS_EQ",
+	T_GT: "GT", T_GT_EQ: "GT_EQ", T_NOT_EQ: "NOT_EQ", T_NEQ: "NEQ", T_LBRACKET: "LBRACKET",
+	T_RBRACKET: "RBRACKET", T_DECLARE: "DECLARE", T_RANGE: "RANGE",
+}
+
+// Name returns the name of the kind as used in tree dumps, e.g. SEMICOLON or SELECT.
+func (k TokKind) Name() string {
+	n, ok := punctNames[k]
+	if ok {
+		return n
+	}
+	return strings.ToUpper(string(k))
+}
+
+// NodeKind returns the name of the syntax node type, or of the token kind for tokens.
+func NodeKind(n AstNode) string {
+	t, ok := n.(*TokNode)
+	if ok {
+		return t.Kind().Name()
+	}
+	return reflect.TypeOf(n).Elem().Name()
+}
+
+// Dump returns an indented representation of the tree with a line per node
+// in the form Kind@start..end, followed by the text for tokens:
+//
+//	DbDirective@0..11
+//	  DATABASE@0..8 "database"
+//	  WS@8..9 " "
+func Dump(n AstNode) string {
+	sb := strings.Builder{}
+	dump(&sb, n, 0)
+	return sb.String()
+}
+
+func dump(sb *strings.Builder, n AstNode, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(sb, "%s@%d..%d", NodeKind(n), n.Start(), n.End())
+	if _, ok := n.(*TokNode); ok {
+		fmt.Fprintf(sb, " %q", n.Text())
+	}
+	sb.WriteString("\n")
+	for _, c := range n.Children() {
+		dump(sb, c, depth+1)
+	}
+}
+
+type jsonNode struct {
+	Kind     string      `json:"kind"`
+	Start    int         `json:"start"`
+	End      int         `json:"end"`
+	Text     *string     `json:"text,omitempty"`
+	Children []*jsonNode `json:"children,omitempty"`
+}
+
+func toJsonNode(n AstNode) *jsonNode {
+	res := &jsonNode{
+		Kind:  NodeKind(n),
+		Start: n.Start(),
+		End:   n.End(),
+	}
+	if _, ok := n.(*TokNode); ok {
+		text := n.Text()
+		res.Text = &text
+	}
+	for _, c := range n.Children() {
+		res.Children = append(res.Children, toJsonNode(c))
+	}
+	return res
+}
+
+// DumpJSON encodes the tree as JSON. Every node is an object with kind, start
+// and end fields, tokens have a text field and composite nodes a children field.
+func DumpJSON(n AstNode) ([]byte, error) {
+	return json.MarshalIndent(toJsonNode(n), "", "  ")
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (c *checker) tableNames() []string {
+	res := []string{}
+	for _, td := range c.fr.TableDecls() {
+		res = append(res, td.Name())
+	}
+	return res
+}
+
+// renameFixes suggests replacing a misspelled name under n with the closest candidate.
+func (c *checker) renameFixes(n AstNode, name string, candidates []string) []Fix {
+	s := closest(name, candidates)
+	if n == nil || s == "" {
+		return nil
+	}
+	return []Fix{replaceFix(fmt.Sprintf("Change to '%s'", s), n.Start(), n.End(), s)}
+}
+
+// indent returns the indentation used for table columns, the default one if there are none.
+func (c *checker) indent() string {
+	for _, td := range c.fr.TableDecls() {
+		for _, cd := range td.Columns() {
+			if ws := indentBefore(td.Children(), cd); ws != "" {
+				return ws
+			}
+		}
+	}
+	return strings.Repeat(" ", DefaultFormatOptions.TabSize)
+}
+
+// indentBefore returns the whitespace between the start of line and n if n starts a line.
+func indentBefore(ns []AstNode, n AstNode) string {
+	for i, x := range ns {
+		if x != n || i == 0 {
+			continue
+		}
+		t, ok := ns[i-1].(*TokNode)
+		if !ok || t.tok.kind != T_WS || !strings.Contains(t.Text(), "\n") {
+			return ""
+		}
+		return t.Text()[strings.LastIndex(t.Text(), "\n")+1:]
+	}
+	return ""
+}
+
+func (c *checker) createTableFix(name string) Fix {
+	decl := fmt.Sprintf("table %s {\n%sid int primary\n}", name, c.indent())
+	title := fmt.Sprintf("Create table '%s'", name)
+
+	tds := c.fr.TableDecls()
+	if len(tds) > 0 {
+		pos := tds[len(tds)-1].End()
+		return replaceFix(title, pos, pos, "\n\n"+decl)
+	}
+	if ads := c.fr.ActionDecls(); len(ads) > 0 {
+		pos := ads[0].Start()
+		return replaceFix(title, pos, pos, decl+"\n\n")
+	}
+	pos := 0
+	for _, n := range c.fr.Children() {
+		if _, ok := n.(*TokNode); !ok {
+			pos = n.End()
+		}
+	}
+	if pos == 0 {
+		return replaceFix(title, 0, 0, decl+"\n")
+	}
+	return replaceFix(title, pos, pos, "\n\n"+decl)
+}
+
+// columnFixes suggests correcting a misspelled column name or adding the column
+// to one of the tables it may belong to.
+func (c *checker) columnFixes(n AstNode, name string, tables []*TableDecl) []Fix {
+	candidates := []string{}
+	for _, td := range tables {
+		for _, cd := range td.Columns() {
+			candidates = append(candidates, cd.Name())
+		}
+	}
+	fixes := c.renameFixes(n, name, candidates)
+	for _, td := range tables {
+		if f, ok := c.addColumnFix(td, name); ok {
+			fixes = append(fixes, f)
+		}
+	}
+	return fixes
+}
+
+func (c *checker) addColumnFix(td *TableDecl, name string) (Fix, bool) {
+	title := fmt.Sprintf("Add column '%s' to table '%s'", name, td.Name())
+	col := name + " text"
+
+	cols := td.Columns()
+	if len(cols) > 0 {
+		last := cols[len(cols)-1]
+		ws := indentBefore(td.Children(), last)
+		if ws == "" {
+			return replaceFix(title, last.End(), last.End(), ", "+col), true
+		}
+		return replaceFix(title, last.End(), last.End(), ",\n"+ws+col), true
+	}
+
+	lbrace := findTok(td.Children(), T_LBRACE)
+	if lbrace == nil {
+		return Fix{}, false
+	}
+	text := "\n" + c.indent() + col
+	if len(td.Indexes()) > 0 || len(td.ForeignKeys()) > 0 {
+		text += ","
+	} else {
+		text += "\n"
+	}
+	return replaceFix(title, lbrace.End(), lbrace.End(), text), true
+}
+
+func (c *checker) declareParamFix(ad *ActionDecl, name string) []Fix {
+	title := fmt.Sprintf("Declare '%s' as a parameter of '%s'", name, ad.Name())
+	if ps := ad.Params(); len(ps) > 0 {
+		pos := ps[len(ps)-1].End()
+		return []Fix{replaceFix(title, pos, pos, ", "+name)}
+	}
+	lparen := findTok(ad.Children(), T_LPAREN)
+	if lparen == nil {
+		return nil
+	}
+	return []Fix{replaceFix(title, lparen.End(), lparen.End(), name)}
+}
+
+// removeParamFix deletes a parameter together with the comma separating it from its neighbour.
+func removeParamFix(ad *ActionDecl, pd *ParamDecl) Fix {
+	title := fmt.Sprintf("Remove unused parameter '%s'", pd.Name())
+	ps := ad.Params()
+	for i, p := range ps {
+		if p != pd {
+			continue
+		}
+		switch {
+		case i+1 < len(ps):
+			return replaceFix(title, pd.Start(), ps[i+1].Start(), "")
+		case i > 0:
+			return replaceFix(title, ps[i-1].End(), pd.End(), "")
+		}
+	}
+	return replaceFix(title, pd.Start(), pd.End(), "")
+}
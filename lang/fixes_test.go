@@ -0,0 +1,117 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func applyFix(text string, f Fix) string {
+	edits := slices.Clone(f.Edits)
+	slices.SortFunc(edits, func(a, b TextEdit) int { return b.Start - a.Start })
+	for _, e := range edits {
+		text = text[:e.Start] + e.NewText + text[e.End:]
+	}
+	return text
+}
+
+// fixes returns the titles of the fixes for the first diagnostic with the
+// code and the text produced by each of them.
+func fixes(t *testing.T, text string, code string) ([]string, []string) {
+	fr := ParseFile(text)
+	ds := append(Check(fr), Lint(fr, LintConfig{})...)
+	idx := slices.IndexFunc(ds, func(d Diagnostic) bool { return d.Code == code })
+	require.GreaterOrEqual(t, idx, 0, "no %s diagnostic", code)
+	titles, texts := []string{}, []string{}
+	for _, f := range ds[idx].Fixes {
+		titles = append(titles, f.Title)
+		texts = append(texts, applyFix(text, f))
+	}
+	return titles, texts
+}
+
+func TestFixMissingTokens(t *testing.T) {
+	titles, texts := fixes(t, "database d;\naction a() { SELECT 1 SELECT 2 }", "syntax")
+	assert.Equal(t, []string{"Insert ';'"}, titles)
+	assert.Equal(t, []string{"database d;\naction a() { SELECT 1; SELECT 2 }"}, texts)
+
+	titles, texts = fixes(t, "database d;\naction a() { SELECT 1;", "syntax")
+	assert.Equal(t, []string{"Insert '}'"}, titles)
+	assert.Equal(t, []string{"database d;\naction a() { SELECT 1;}"}, texts)
+}
+
+func TestFixMisspelledKeyword(t *testing.T) {
+	titles, texts := fixes(t, "database d;\ntabel t {}", "syntax")
+	assert.Equal(t, []string{"Change to 'table'"}, titles)
+	assert.Equal(t, []string{"database d;\ntable t {}"}, texts)
+
+	titles, _ = fixes(t, "database d;\naction a() { selct 1; }", "syntax")
+	assert.Equal(t, []string{"Change to 'select'"}, titles)
+}
+
+func TestFixUndefinedVariable(t *testing.T) {
+	titles, texts := fixes(t, "database d;\naction a($name) { SELECT $nam, $name; }", CodeUndefinedVar)
+	assert.Equal(t, []string{"Change to '$name'", "Declare '$nam' as a parameter of 'a'"}, titles)
+	assert.Equal(t, []string{
+		"database d;\naction a($name) { SELECT $name, $name; }",
+		"database d;\naction a($name, $nam) { SELECT $nam, $name; }",
+	}, texts)
+
+	_, texts = fixes(t, "database d;\naction a() { SELECT $x; }", CodeUndefinedVar)
+	assert.Equal(t, []string{"database d;\naction a($x) { SELECT $x; }"}, texts)
+}
+
+func TestFixUnknownTable(t *testing.T) {
+	text := "database d;\n\ntable users {\n  id int primary\n}\n\naction a() { SELECT * FROM user; }"
+	titles, texts := fixes(t, text, CodeUnknownTable)
+	assert.Equal(t, []string{"Change to 'users'", "Create table 'user'"}, titles)
+	assert.Equal(t, "database d;\n\ntable users {\n  id int primary\n}\n\naction a() { SELECT * FROM users; }", texts[0])
+	assert.Equal(t, "database d;\n\ntable users {\n  id int primary\n}\n\ntable user {\n  id int primary\n}\n\naction a() { SELECT * FROM user; }", texts[1])
+
+	_, texts = fixes(t, "database d;\naction a() { DELETE FROM t; }", CodeUnknownTable)
+	assert.Equal(t, []string{"database d;\ntable t {\n    id int primary\n}\n\naction a() { DELETE FROM t; }"}, texts)
+}
+
+func TestFixUnknownColumn(t *testing.T) {
+	text := "database d;\ntable t {\n    id int primary,\n    name text,\n    #idx index(name)\n}\naction a() { SELECT t.nme FROM t; }"
+	titles, texts := fixes(t, text, CodeUnknownColumn)
+	assert.Equal(t, []string{"Change to 'name'", "Add column 'nme' to table 't'"}, titles)
+	assert.Equal(t, "database d;\ntable t {\n    id int primary,\n    name text,\n    #idx index(name)\n}\naction a() { SELECT t.name FROM t; }", texts[0])
+	assert.Equal(t, "database d;\ntable t {\n    id int primary,\n    name text,\n    nme text,\n    #idx index(name)\n}\naction a() { SELECT t.nme FROM t; }", texts[1])
+
+	_, texts = fixes(t, "database d;\ntable t {}\naction a() { UPDATE t SET x = 1; }", CodeUnknownColumn)
+	assert.Equal(t, []string{"database d;\ntable t {\n    x text\n}\naction a() { UPDATE t SET x = 1; }"}, texts)
+}
+
+func TestFixUnusedParam(t *testing.T) {
+	check := func(text string, expected string) {
+		_, texts := fixes(t, text, "unused-parameter")
+		assert.Equal(t, []string{expected}, texts)
+	}
+	check("database d;\naction a($x, $y) { SELECT $y; }", "database d;\naction a($y) { SELECT $y; }")
+	check("database d;\naction a($x, $y) { SELECT $x; }", "database d;\naction a($x) { SELECT $x; }")
+	check("database d;\naction a($x) { SELECT 1; }", "database d;\naction a() { SELECT 1; }")
+}
+
+func TestClosest(t *testing.T) {
+	assert.Equal(t, "users", closest("user", []string{"orders", "users"}))
+	assert.Equal(t, "", closest("x", []string{"y"}))
+	assert.Equal(t, "", closest("abc", []string{"xyz"}))
+	assert.Equal(t, 3, editDistance("kitten", "sitting"))
+	assert.Equal(t, 1, editDistance("tabel", "TABLE"))
+}
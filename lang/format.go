@@ -0,0 +1,492 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+type FormatOptions struct {
+	TabSize      int
+	InsertSpaces bool
+	KeywordCase  KeywordCase
+}
+
+// KeywordCase is how the formatter prints SQL keywords. The other keywords are
+// always printed in lower case.
+type KeywordCase int
+
+const (
+	KeywordUpper KeywordCase = iota
+	KeywordLower
+	KeywordPreserve
+)
+
+type TextEdit struct {
+	Start   int
+	End     int
+	NewText string
+}
+
+var ErrSyntax = errors.New("file has syntax errors")
+
+var DefaultFormatOptions = FormatOptions{
+	TabSize:      4,
+	InsertSpaces: true,
+}
+
+// sqlKeywords are printed in the configured case, the other keywords in lower case.
+var sqlKeywords = map[TokKind]bool{
+	T_SELECT: true, T_INSERT: true, T_INTO: true, T_VALUES: true, T_UPDATE: true, T_SET: true,
+	T_DELETE: true, T_FROM: true, T_WHERE: true, T_JOIN: true, T_INNER: true, T_LEFT: true,
+	T_ON: true, T_AS: true, T_CONFLICT: true, T_DO: true, T_NOTHING: true, T_ORDER: true,
+	T_BY: true, T_ASC: true, T_DESC: true, T_LIMIT: true, T_KW_AND: true, T_KW_OR: true, T_NOT: true,
+	T_IS: true, T_NULL: true,
+}
+
+type printer struct {
+	opts   FormatOptions
+	sb     strings.Builder
+	indent int
+}
+
+// Format pretty prints a file. In files with syntax errors only the intact
+// declarations are formatted, as FormatRange does, since there is no reliable
+// way to tell how the broken parts should look.
+func Format(fr *FileRoot, opts FormatOptions) (string, error) {
+	if len(fr.SyntaxErrors()) > 0 {
+		text := fr.Text()
+		edits := FormatRange(fr, 0, len(text), opts)
+		for i := len(edits) - 1; i >= 0; i-- {
+			e := edits[i]
+			text = text[:e.Start] + e.NewText + text[e.End:]
+		}
+		return text, nil
+	}
+	p := printer{opts: opts}
+	p.items(fr.Children(), nil)
+	res := strings.TrimLeft(p.sb.String(), "\n")
+	if res == "" {
+		return "", nil
+	}
+	return res + "\n", nil
+}
+
+// FormatRange returns edits which format the top level declarations intersecting
+// the [start, end] range. Declarations containing syntax errors are left as is.
+func FormatRange(fr *FileRoot, start int, end int, opts FormatOptions) []TextEdit {
+	start, end = max(start, 0), max(end, 0)
+	res := []TextEdit{}
+	for _, c := range fr.Children() {
+		if _, ok := c.(*TokNode); ok || c.End() < start || c.Start() > end {
+			continue
+		}
+		if hasErrorsIn(fr, c.Start(), c.End()) {
+			continue
+		}
+		p := printer{opts: opts}
+		p.item(c)
+		text := p.sb.String()
+		if text != c.Text() {
+			res = append(res, TextEdit{Start: c.Start(), End: c.End(), NewText: text})
+		}
+	}
+	return res
+}
+
+func hasErrorsIn(fr *FileRoot, start int, end int) bool {
+	for _, d := range fr.SyntaxErrors() {
+		if d.Start >= start && d.Start <= end {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) write(s string) {
+	p.sb.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.write("\n")
+	if p.opts.InsertSpaces {
+		p.write(strings.Repeat(" ", p.indent*p.opts.TabSize))
+	} else {
+		p.write(strings.Repeat("\t", p.indent))
+	}
+}
+
+func (p *printer) blankLine() {
+	p.write("\n")
+}
+
+// items prints the children of a file, table or block one per line, with their
+// comments. Separator tokens of the source are dropped and sep is printed after
+// every item but the last one, or after all of them when sep is ";".
+func (p *printer) items(ns []AstNode, sep *TokKind) {
+	total := 0
+	for _, n := range ns {
+		if _, ok := n.(*TokNode); !ok {
+			total++
+		}
+	}
+
+	colWidths := columnWidths(ns)
+	seen := 0
+	newlines := 0
+	afterItem := false
+	var prev AstNode
+
+	for _, n := range ns {
+		t, ok := n.(*TokNode)
+		if ok {
+			switch t.tok.kind {
+			case T_WS:
+				newlines += strings.Count(t.tok.text, "\n")
+			case T_COMMENT:
+				if afterItem && newlines == 0 {
+					p.write(" " + t.tok.text)
+				} else {
+					if newlines > 1 && (prev != nil || seen > 0) || topLevelGap(prev, nil) {
+						p.blankLine()
+					}
+					p.newline()
+					p.write(t.tok.text)
+					prev = nil
+				}
+				afterItem = false
+				newlines = 0
+			}
+			continue
+		}
+
+		if newlines > 1 && (prev != nil || seen > 0) || topLevelGap(prev, n) {
+			p.blankLine()
+		}
+		p.newline()
+		if cd, ok := n.(*ColumnDecl); ok {
+			p.column(cd, colWidths)
+		} else {
+			p.item(n)
+		}
+		seen++
+		if sep != nil && (*sep == T_SEMICOLON && !isCompound(n) || *sep != T_SEMICOLON && seen < total) {
+			p.write(string(*sep))
+		}
+		prev = n
+		afterItem = true
+		newlines = 0
+	}
+}
+
+// topLevelGap tells whether declarations should be separated by an empty line
+// even if there is none in the source.
+func topLevelGap(prev AstNode, next AstNode) bool {
+	if prev == nil {
+		return false
+	}
+	switch prev.(type) {
+	case *TableDecl, *ActionDecl, *DbDirective:
+		return true
+	case *ExtDirective:
+		_, ok := next.(*ExtDirective)
+		return next != nil && !ok
+	}
+	return false
+}
+
+func (p *printer) item(n AstNode) {
+	switch n := n.(type) {
+	case *TableDecl:
+		p.block(n, T_COMMA)
+	case *ActionDecl:
+		p.block(n, T_SEMICOLON)
+	case *IfStmt, *ForStmt:
+		p.compound(n)
+	default:
+		p.inline(n, n.Children())
+	}
+}
+
+// block prints a declaration with a header followed by a body in braces.
+func (p *printer) block(parent AstNode, sep TokKind) {
+	ns := parent.Children()
+	lb := -1
+	rb := -1
+	for i, n := range ns {
+		t, ok := n.(*TokNode)
+		if ok && t.tok.kind == T_LBRACE && lb < 0 {
+			lb = i
+		}
+		if ok && t.tok.kind == T_RBRACE {
+			rb = i
+		}
+	}
+
+	header := ns[:lb]
+	for len(header) > 0 {
+		if an, ok := header[0].(*Annotation); ok {
+			p.inline(an, an.Children())
+			p.newline()
+		} else if t, ok := header[0].(*TokNode); !ok || t.tok.kind != T_WS {
+			break
+		}
+		header = header[1:]
+	}
+	p.inline(parent, header)
+	p.write(" {")
+
+	body := ns[lb+1 : rb]
+	if len(significant(body)) == 0 {
+		p.write("}")
+		return
+	}
+
+	p.indent++
+	p.items(body, &sep)
+	p.indent--
+	p.newline()
+	p.write("}")
+}
+
+// formatBody returns the statements of an action or a procedure formatted as
+// in a file, but without the indentation of the block.
+func formatBody(ad *ActionDecl) string {
+	ns := ad.Children()
+	lb := slices.IndexFunc(ns, func(n AstNode) bool {
+		t, ok := n.(*TokNode)
+		return ok && t.tok.kind == T_LBRACE
+	})
+	if lb < 0 {
+		return ""
+	}
+	rb := len(ns)
+	if t, ok := ns[rb-1].(*TokNode); ok && t.tok.kind == T_RBRACE {
+		rb--
+	}
+	sep := T_SEMICOLON
+	p := printer{opts: DefaultFormatOptions}
+	p.items(ns[lb+1:rb], &sep)
+	return strings.TrimLeft(p.sb.String(), "\n")
+}
+
+// isCompound tells whether n is a statement ending with a block, which isn't
+// followed by a semicolon.
+func isCompound(n AstNode) bool {
+	switch n.(type) {
+	case *IfStmt, *ForStmt:
+		return true
+	}
+	return false
+}
+
+// compound prints a statement made of headers each followed by a block, like
+// if with its elseif and else parts.
+func (p *printer) compound(n AstNode) {
+	ns := n.Children()
+	start := 0
+	for i, c := range ns {
+		b, ok := c.(*Block)
+		if !ok {
+			continue
+		}
+		if start > 0 {
+			p.write(" ")
+		}
+		p.inline(n, ns[start:i])
+		p.block(b, T_SEMICOLON)
+		start = i + 1
+	}
+}
+
+func significant(ns []AstNode) []AstNode {
+	res := []AstNode{}
+	for _, n := range ns {
+		t, ok := n.(*TokNode)
+		if !ok || !isTrivia(t.tok.kind) {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func columnWidths(ns []AstNode) [2]int {
+	res := [2]int{}
+	for _, n := range ns {
+		cd, ok := n.(*ColumnDecl)
+		if !ok || hasComments(cd) {
+			continue
+		}
+		res[0] = max(res[0], len(cd.Name()))
+		if cd.Type() != nil && len(cd.Attrs()) > 0 {
+			res[1] = max(res[1], len(inlineText(cd.Type())))
+		}
+	}
+	return res
+}
+
+func hasComments(n AstNode) bool {
+	found := false
+	Walk(n, func(n AstNode) bool {
+		t, ok := n.(*TokNode)
+		if ok && t.tok.kind == T_COMMENT {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func (p *printer) column(cd *ColumnDecl, widths [2]int) {
+	if hasComments(cd) || cd.Type() == nil {
+		p.inline(cd, cd.Children())
+		return
+	}
+	name := cd.Name()
+	p.write(name)
+	p.write(strings.Repeat(" ", widths[0]-len(name)+1))
+
+	typ := inlineText(cd.Type())
+	p.write(typ)
+	for i, a := range cd.Attrs() {
+		if i == 0 {
+			p.write(strings.Repeat(" ", widths[1]-len(typ)+1))
+		} else {
+			p.write(" ")
+		}
+		p.write(inlineText(a))
+	}
+}
+
+func inlineText(n AstNode) string {
+	p := printer{opts: DefaultFormatOptions}
+	p.inline(n, n.Children())
+	return p.sb.String()
+}
+
+type leaf struct {
+	tok    *TokNode
+	parent AstNode
+}
+
+func leaves(parent AstNode, ns []AstNode, res []leaf) []leaf {
+	for _, n := range ns {
+		t, ok := n.(*TokNode)
+		if ok {
+			if t.tok.kind != T_WS {
+				res = append(res, leaf{tok: t, parent: parent})
+			}
+		} else {
+			res = leaves(n, n.Children(), res)
+		}
+	}
+	return res
+}
+
+// inline prints nodes on a single line, except that a line comment forces a
+// line break with a continuation indent.
+func (p *printer) inline(parent AstNode, ns []AstNode) {
+	ls := leaves(parent, ns, []leaf{})
+	for i, l := range ls {
+		if i > 0 {
+			if ls[i-1].tok.tok.kind == T_COMMENT && strings.HasPrefix(ls[i-1].tok.tok.text, "//") {
+				p.indent++
+				p.newline()
+				p.indent--
+			} else if needSpace(ls[i-1], l) {
+				p.write(" ")
+			}
+		}
+		p.write(leafText(l, p.opts.KeywordCase))
+	}
+}
+
+// leafText normalizes the case of keywords and of the names which are
+// keywords in all but the lexer, such as column types and attributes.
+func leafText(l leaf, kc KeywordCase) string {
+	t := l.tok.tok
+	if sqlKeywords[t.kind] {
+		if _, ok := l.parent.(*ExtDirective); !ok {
+			switch kc {
+			case KeywordUpper:
+				return strings.ToUpper(t.text)
+			case KeywordLower:
+				return strings.ToLower(t.text)
+			}
+			return t.text
+		}
+	}
+	if isKeyword(t.kind) {
+		return strings.ToLower(t.text)
+	}
+	if t.kind != T_ID {
+		return t.text
+	}
+	switch parent := l.parent.(type) {
+	case *TypeRef, *ColumnAttr, *FkAction:
+		return strings.ToLower(t.text)
+	case *IndexDecl:
+		if idText(parent.Children()) != t.text {
+			return strings.ToLower(t.text)
+		}
+	case *ForeignKeyDecl:
+		lower := strings.ToLower(t.text)
+		if lower == "foreign_key" || lower == "fk" || lower == "ref" {
+			return lower
+		}
+	}
+	return t.text
+}
+
+func needSpace(prev leaf, cur leaf) bool {
+	pk := prev.tok.tok.kind
+	ck := cur.tok.tok.kind
+
+	switch ck {
+	case T_COMMA, T_SEMICOLON, T_RPAREN, T_RBRACKET, T_LBRACKET, T_DOT, T_COLON, T_RANGE:
+		return false
+	}
+
+	switch pk {
+	case T_LPAREN, T_LBRACKET, T_DOT, T_DOLLAR, T_AT, T_HASH, T_RANGE:
+		return false
+	case T_MINUS, T_PLUS:
+		if _, ok := prev.parent.(*UnaryExpr); ok {
+			return false
+		}
+	}
+
+	_, curAnnotation := cur.parent.(*Annotation)
+	_, prevAnnotation := prev.parent.(*Annotation)
+	if curAnnotation && ck == T_ASSIGN || prevAnnotation && pk == T_ASSIGN {
+		return false
+	}
+
+	if ck == T_LPAREN && pk == T_TABLE {
+		_, ok := cur.parent.(*ReturnsClause)
+		return !ok
+	}
+
+	if ck == T_LPAREN && pk == T_ID {
+		if _, ok := cur.parent.(*ColumnList); ok {
+			_, afterTable := prev.parent.(*TableRef)
+			return afterTable || strings.EqualFold(prev.tok.Text(), "foreign_key") || strings.EqualFold(prev.tok.Text(), "fk")
+		}
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func format(t *testing.T, text string) string {
+	res, err := Format(ParseFile(text), DefaultFormatOptions)
+	assert.NoError(t, err)
+	return res
+}
+
+func TestFormatFile(t *testing.T) {
+	text := `database   shop ;
+use erc20 {address: '0x1'} AS token;
+table users{ID UUID PRIMARY,name text notnull maxlen(10),
+  #name_idx UNIQUE( name ),foreign_key(id) REFERENCES users( id ) ON_DELETE cascade};
+action   create_user ( $id,$name ) PUBLIC{
+insert into users(id,name) values($id,-1)
+; select count(*) , u.name as n from users u where u.id is not null
+}`
+
+	assert.Equal(t, `database shop;
+
+use erc20 { address: '0x1' } as token;
+
+table users {
+    ID   uuid primary,
+    name text notnull maxlen(10),
+    #name_idx unique(name),
+    foreign_key (id) references users(id) on_delete cascade
+}
+
+action create_user($id, $name) public {
+    INSERT INTO users (id, name) VALUES ($id, -1);
+    SELECT count(*), u.name AS n FROM users u WHERE u.id IS NOT NULL;
+}
+`, format(t, text))
+}
+
+func TestFormatProcedure(t *testing.T) {
+	text := `database d;
+procedure p( $n INT,$xs text[] )public view RETURNS TABLE ( i int,x text ){
+$c int:=0;for $i in 1 .. $n{if $i%2==0{return next $i,$xs;}elseif $i>10{ERROR('too many')}else{$c:=$c+1;}}
+return select 1, 'x'
+}`
+
+	assert.Equal(t, `database d;
+
+procedure p($n int, $xs text[]) public view returns table(i int, x text) {
+    $c int := 0;
+    for $i in 1..$n {
+        if $i % 2 == 0 {
+            return next $i, $xs;
+        } elseif $i > 10 {
+            ERROR('too many');
+        } else {
+            $c := $c + 1;
+        }
+    }
+    return SELECT 1, 'x';
+}
+`, format(t, text))
+}
+
+func TestFormatComments(t *testing.T) {
+	text := `// header
+database d;
+// users
+table users {
+	// key
+	id int, // trailing
+
+
+	name text
+}
+action a() {
+	$x = 1 + // why
+	2;
+	/* done */
+}`
+
+	assert.Equal(t, `// header
+database d;
+
+// users
+table users {
+    // key
+    id   int, // trailing
+
+    name text
+}
+
+action a() {
+    $x = 1 + // why
+        2;
+    /* done */
+}
+`, format(t, text))
+}
+
+func TestFormatIsIdempotent(t *testing.T) {
+	texts := []string{
+		checkSchema,
+		"database d; table t {} action a() public view {}",
+		"database d;\n\n\n// c\n\nuse a;\nuse b;\ntable t { a int, b text[], c decimal(10,2) default(0) }",
+		"database d; action a($a) { SELECT * FROM t LEFT JOIN u ON t.a = u.b ORDER BY a ASC LIMIT 2 + 3; }",
+	}
+	for _, text := range texts {
+		once := format(t, text)
+		assert.Equal(t, once, format(t, once))
+	}
+}
+
+func TestFormatWithSyntaxErrors(t *testing.T) {
+	res, err := Format(ParseFile("database d;\ntable t {a int}\naction b() {"), DefaultFormatOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, "database d;\ntable t {\n    a int\n}\naction b() {", res)
+}
+
+func TestFormatRange(t *testing.T) {
+	text := "database d;\ntable t {a int}\naction a() { $x=1; }\naction b() {"
+	fr := ParseFile(text)
+
+	edits := FormatRange(fr, 14, 14, DefaultFormatOptions)
+	assert.Equal(t, []TextEdit{{Start: 12, End: 27, NewText: "table t {\n    a int\n}"}}, edits)
+
+	assert.Empty(t, FormatRange(fr, 50, len(text), DefaultFormatOptions))
+	assert.Empty(t, FormatRange(fr, -1, -1, DefaultFormatOptions))
+}
+
+func TestFormatTabs(t *testing.T) {
+	res, err := Format(ParseFile("database d; table t {a int}"), FormatOptions{TabSize: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, "database d;\n\ntable t {\n\ta int\n}\n", res)
+}
+
+func TestFormatKeywordCase(t *testing.T) {
+	text := "database d; action a() public { Select * from t wHere id is null; }"
+	expected := map[KeywordCase]string{
+		KeywordUpper:    "SELECT * FROM t WHERE id IS NULL;",
+		KeywordLower:    "select * from t where id is null;",
+		KeywordPreserve: "Select * from t wHere id is null;",
+	}
+	for kc, stmt := range expected {
+		opts := DefaultFormatOptions
+		opts.KeywordCase = kc
+		res, err := Format(ParseFile(text), opts)
+		assert.NoError(t, err)
+		assert.Equal(t, "database d;\n\naction a() public {\n    "+stmt+"\n}\n", res)
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	goformat "go/format"
+	"go/token"
+	"strings"
+)
+
+// goInitialisms are the name parts Go spells in capitals.
+var goInitialisms = map[string]bool{
+	"id": true, "uuid": true, "url": true, "uri": true, "api": true, "http": true, "json": true,
+	"sql": true, "ip": true, "tx": true, "txid": true,
+}
+
+// goName converts a Kuneiform name to an exported Go one, e.g. user_id to
+// UserID.
+func goName(name string) string {
+	return pascalName(name, goInitialisms)
+}
+
+// pascalName converts a snake case name to a pascal case one, spelling the
+// given initialisms in capitals.
+func pascalName(name string, initialisms map[string]bool) string {
+	sb := strings.Builder{}
+	for _, p := range strings.FieldsFunc(strings.TrimLeft(name, "$@"), func(r rune) bool { return r == '_' }) {
+		p = strings.ToLower(p)
+		if initialisms[p] {
+			sb.WriteString(strings.ToUpper(p))
+		} else {
+			sb.WriteString(strings.ToUpper(p[:1]) + p[1:])
+		}
+	}
+	res := sb.String()
+	if res == "" || res[0] >= '0' && res[0] <= '9' {
+		res = "X" + res
+	}
+	return res
+}
+
+// goParamName converts a parameter name to an unexported Go one.
+func goParamName(name string) string {
+	res := goName(name)
+	upper := 0
+	for upper < len(res) && res[upper] >= 'A' && res[upper] <= 'Z' {
+		upper++
+	}
+	// lower the whole of a leading initialism, but not the first letter of the next part
+	if upper > 1 && upper < len(res) {
+		upper--
+	}
+	res = strings.ToLower(res[:upper]) + res[upper:]
+	if token.IsKeyword(res) {
+		res += "_"
+	}
+	return res
+}
+
+func goType(f *BoundField) string {
+	res := ""
+	switch f.Type.Name {
+	case "int":
+		res = "int64"
+	case "bool":
+		res = "bool"
+	case "blob":
+		res = "[]byte"
+	case "uuid":
+		res = "UUID"
+	case "uint256":
+		res = "*big.Int"
+	case "decimal":
+		res = "Decimal"
+	default:
+		res = "string"
+	}
+	if f.Type.IsArray {
+		return "[]" + res
+	}
+	if f.Nullable && !strings.HasPrefix(res, "[]") && !strings.HasPrefix(res, "*") {
+		return "*" + res
+	}
+	return res
+}
+
+const goPrelude = `// Caller runs actions and procedures, e.g. through a Kwil client.
+type Caller interface {
+	// Call runs a view and returns its rows.
+	Call(ctx context.Context, action string, args []any) (Rows, error)
+	// Execute runs an action or a procedure in a transaction.
+	Execute(ctx context.Context, action string, args []any) error
+}
+
+// Rows iterates over the result of a call like sql.Rows does.
+type Rows interface {
+	Next() bool
+	// Scan copies the columns of the current row into the values pointed at,
+	// which have the types of this package.
+	Scan(dest ...any) error
+	Err() error
+	Close() error
+}
+
+// ErrNoRows is returned by calls expecting a row when there is none.
+var ErrNoRows = errors.New("no rows in result")
+
+// UUID is a uuid value.
+type UUID [16]byte
+
+// Decimal is a decimal value in its text form, which keeps its precision.
+type Decimal string
+
+// Client calls the actions and procedures of the database.
+type Client struct {
+	caller Caller
+}
+
+func NewClient(caller Caller) *Client {
+	return &Client{caller: caller}
+}
+`
+
+// GenerateGo returns a Go package with a struct for the rows of every table
+// and a Client with a typed method for every public action and procedure.
+// The methods call through a Caller, so the package depends on no client
+// library.
+func GenerateGo(b *Bindings, pkg string) (string, error) {
+	g := goGen{names: map[string]bool{}, tables: map[*BoundTable]string{}}
+	for _, name := range goPreludeNames {
+		g.names[name] = true
+	}
+	for _, t := range b.Tables {
+		g.tables[t] = g.structType(goName(t.Name)+"Row", "a row of table "+t.Name, t.Columns)
+	}
+	for _, a := range b.Actions {
+		if a.Public {
+			g.method(a)
+		}
+	}
+
+	imports := []string{"context", "errors"}
+	if strings.Contains(g.sb.String(), "big.Int") {
+		imports = append(imports, "math/big")
+	}
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "// Code generated by kf gen go. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	for _, i := range imports {
+		fmt.Fprintf(&sb, "\t%q\n", i)
+	}
+	sb.WriteString(")\n\n")
+	sb.WriteString(goPrelude)
+	sb.WriteString(g.sb.String())
+
+	res, err := goformat.Source([]byte(sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("generated invalid Go code: %w", err)
+	}
+	return string(res), nil
+}
+
+// goPreludeNames are the names goPrelude declares.
+var goPreludeNames = []string{"Caller", "Rows", "ErrNoRows", "UUID", "Decimal", "Client", "NewClient"}
+
+type goGen struct {
+	sb     strings.Builder
+	names  map[string]bool        // the declared names
+	tables map[*BoundTable]string // the row structs of the tables
+}
+
+// uniqueName returns the name, or the name with the first number from 2 which
+// makes it unique, and declares it.
+func (g *goGen) uniqueName(name string) string {
+	res := name
+	for i := 2; g.names[res]; i++ {
+		res = fmt.Sprintf("%s%d", name, i)
+	}
+	g.names[res] = true
+	return res
+}
+
+// structType declares a struct with a name unique in the package and returns
+// the name.
+func (g *goGen) structType(name string, doc string, fields []*BoundField) string {
+	name = g.uniqueName(name)
+	fmt.Fprintf(&g.sb, "\n// %s is %s.\ntype %s struct {\n", name, doc, name)
+	for _, f := range fields {
+		fmt.Fprintf(&g.sb, "\t%s %s `json:\"%s\"`\n", goName(f.Name), goType(f), strings.ToLower(f.Name))
+	}
+	g.sb.WriteString("}\n")
+	return name
+}
+
+// resultType returns the struct for the rows of an action, which is the one
+// of a table when the action returns whole rows of it. Otherwise the action
+// gets its own, numbered if its name is taken, e.g. by the row struct of a
+// table with the action's name.
+func (g *goGen) resultType(a *BoundAction) string {
+	if t := a.ResultTable(); t != nil {
+		return g.tables[t]
+	}
+	name := goName(a.Name) + "Row"
+	if !a.Rows {
+		name = goName(a.Name) + "Result"
+	}
+	return g.structType(name, "the result of "+a.Name, a.Results)
+}
+
+func (g *goGen) method(a *BoundAction) {
+	params := []string{"ctx context.Context"}
+	args := []string{}
+	for _, p := range a.Params {
+		params = append(params, goParamName(p.Name)+" "+goType(p))
+		args = append(args, goParamName(p.Name))
+	}
+	name := goName(a.Name)
+	kind := "action"
+	if a.Procedure {
+		kind = "procedure"
+	}
+	call := fmt.Sprintf("%q, []any{%s}", a.Name, strings.Join(args, ", "))
+
+	if !a.View || len(a.Results) == 0 {
+		method := "Call"
+		if !a.View {
+			method = "Execute"
+		}
+		fmt.Fprintf(&g.sb, "\n// %s runs %s %s.\nfunc (c *Client) %s(%s) error {\n", name, kind, a.Name, name, strings.Join(params, ", "))
+		if a.View {
+			fmt.Fprintf(&g.sb, "\trows, err := c.caller.Call(ctx, %s)\n\tif err != nil {\n\t\treturn err\n\t}\n\treturn rows.Close()\n}\n", call)
+		} else {
+			fmt.Fprintf(&g.sb, "\treturn c.caller.%s(ctx, %s)\n}\n", method, call)
+		}
+		return
+	}
+
+	// a single value is returned as it is rather than in a struct
+	res, scan := "", []string{}
+	if len(a.Results) == 1 && !a.Rows {
+		res = goType(a.Results[0])
+		scan = append(scan, "&r")
+	} else {
+		res = g.resultType(a)
+		for _, f := range a.Results {
+			scan = append(scan, "&r."+goName(f.Name))
+		}
+	}
+	scanLine := fmt.Sprintf("if err := rows.Scan(%s); err != nil {", strings.Join(scan, ", "))
+
+	fmt.Fprintf(&g.sb, "\n// %s calls %s %s.\n", name, kind, a.Name)
+	if a.Rows {
+		fmt.Fprintf(&g.sb, `func (c *Client) %s(%s) ([]%s, error) {
+	rows, err := c.caller.Call(ctx, %s)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []%s{}
+	for rows.Next() {
+		r := %s{}
+		%s
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+`, name, strings.Join(params, ", "), res, call, res, res, scanLine)
+		return
+	}
+	fmt.Fprintf(&g.sb, `func (c *Client) %s(%s) (%s, error) {
+	var r %s
+	rows, err := c.caller.Call(ctx, %s)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return r, err
+		}
+		return r, ErrNoRows
+	}
+	%s
+		return r, err
+	}
+	return r, nil
+}
+`, name, strings.Join(params, ", "), res, res, call, scanLine)
+}
+
+// GoPackageName returns a package name for a database name.
+func GoPackageName(name string) string {
+	res := strings.ToLower(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, name))
+	if res == "" || res[0] >= '0' && res[0] <= '9' || token.IsKeyword(res) || res == "main" {
+		res = "db" + res
+	}
+	return res
+}
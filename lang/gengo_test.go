@@ -0,0 +1,77 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bindings(t *testing.T, text string) *Bindings {
+	b, diags := NewBindings(ParseFile(text))
+	require.Empty(t, diags)
+	return b
+}
+
+// TestGenerateGo compares the packages generated from testdata/gen/*.kf with
+// the .go files beside them. Run `go test ./lang -run TestGenerateGo -update`
+// to regenerate them.
+func TestGenerateGo(t *testing.T) {
+	for _, name := range []string{"shop", "clash"} {
+		t.Run(name, func(t *testing.T) {
+			text, err := os.ReadFile("testdata/gen/" + name + ".kf")
+			require.NoError(t, err)
+			res, err := GenerateGo(bindings(t, string(text)), name)
+			require.NoError(t, err)
+			golden(t, "testdata/gen/"+name+".go", res)
+		})
+	}
+}
+
+func TestBindings(t *testing.T) {
+	b := bindings(t, `database d;
+table t { id int primary, name text }
+action get($id) public view { SELECT name, $id AS id FROM t WHERE id = $id; }
+procedure p($x decimal(5, 1)) private returns (y bool) { return true; }
+action q($a, $b) public view { SELECT $a / $b AS r; }`)
+	get := b.Actions[0]
+	assert.True(t, get.Rows)
+	assert.Equal(t, &BoundField{Name: "$id", Type: &DataType{Name: "int"}}, get.Params[0])
+	assert.Equal(t, &BoundField{Name: "name", Type: &DataType{Name: "text"}, Nullable: true}, get.Results[0])
+	assert.Equal(t, &BoundField{Name: "id", Type: &DataType{Name: "int"}}, get.Results[1])
+
+	p := b.Actions[1]
+	assert.False(t, p.Rows)
+	assert.Equal(t, &DataType{Name: "decimal", Metadata: [2]uint16{5, 1}}, p.Params[0].Type)
+	assert.Equal(t, &BoundField{Name: "y", Type: &DataType{Name: "bool"}}, p.Results[0])
+
+	assert.Equal(t, &BoundField{Name: "$a", Type: &DataType{Name: "text"}, Guessed: true}, b.Actions[2].Params[0])
+
+	_, diags := NewBindings(ParseFile("database d; table t { id float }"))
+	assert.Equal(t, []string{CodeUnsupportedType}, diagCodes(diags))
+}
+
+func TestGoNames(t *testing.T) {
+	assert.Equal(t, "UserID", goName("user_id"))
+	assert.Equal(t, "GetUser", goName("get_user"))
+	assert.Equal(t, "X2fa", goName("2fa"))
+	assert.Equal(t, "userID", goParamName("$user_id"))
+	assert.Equal(t, "id", goParamName("$id"))
+	assert.Equal(t, "type_", goParamName("$type"))
+	assert.Equal(t, "shop", GoPackageName("Shop"))
+	assert.Equal(t, "dbmain", GoPackageName("main"))
+}
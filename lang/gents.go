@@ -0,0 +1,135 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+func tsType(f *BoundField) string {
+	res := ""
+	switch f.Type.Name {
+	case "int":
+		// beyond Number.MAX_SAFE_INTEGER ints lose precision, as they do
+		// when a client decodes them from JSON
+		res = "number"
+	case "bool":
+		res = "boolean"
+	case "blob":
+		res = "Uint8Array"
+	default:
+		// uuids, uint256s and decimals are strings, which keep their precision
+		res = "string"
+	}
+	if f.Type.IsArray {
+		res += "[]"
+	}
+	if f.Nullable {
+		res += " | null"
+	}
+	return res
+}
+
+// GenerateTypeScript returns TypeScript types for the rows of every table and
+// the parameters and results of every public action and procedure, with
+// unions of their names. The file has only types, so it can be used as a .ts
+// or a .d.ts file.
+func GenerateTypeScript(b *Bindings) string {
+	g := tsGen{names: map[string]bool{"ActionName": true, "ProcedureName": true, "Actions": true}}
+	g.sb.WriteString("// Code generated by kf gen ts. DO NOT EDIT.\n")
+	tables := map[*BoundTable]string{}
+	for _, t := range b.Tables {
+		tables[t] = g.iface(pascalName(t.Name, nil)+"Row", "A row of table "+t.Name+".", t.Columns)
+	}
+
+	actions, procedures, entries := []string{}, []string{}, []string{}
+	for _, a := range b.Actions {
+		if !a.Public {
+			continue
+		}
+		name := pascalName(a.Name, nil)
+		kind := "action"
+		if a.Procedure {
+			kind = "procedure"
+			procedures = append(procedures, fmt.Sprintf("%q", a.Name))
+		} else {
+			actions = append(actions, fmt.Sprintf("%q", a.Name))
+		}
+
+		params := g.iface(name+"Params", "The parameters of "+kind+" "+a.Name+".", a.Params)
+		result := "void"
+		if len(a.Results) > 0 {
+			if t := a.ResultTable(); t != nil {
+				result = g.uniqueName(name + "Row")
+				fmt.Fprintf(&g.sb, "\n/** A row of the result of %s %s. */\nexport type %s = %s;\n", kind, a.Name, result, tables[t])
+			} else {
+				result = g.iface(name+"Row", "A row of the result of "+kind+" "+a.Name+".", a.Results)
+			}
+			if a.Rows {
+				result += "[]"
+			}
+		}
+		entries = append(entries, fmt.Sprintf("  %s: { params: %s; result: %s };\n", a.Name, params, result))
+	}
+
+	g.union("ActionName", "The names of the public actions.", actions)
+	g.union("ProcedureName", "The names of the public procedures.", procedures)
+	g.sb.WriteString("\n/** The parameters and results of the public actions and procedures by name. */\nexport interface Actions {\n")
+	for _, e := range entries {
+		g.sb.WriteString(e)
+	}
+	g.sb.WriteString("}\n")
+	return g.sb.String()
+}
+
+type tsGen struct {
+	sb    strings.Builder
+	names map[string]bool // the declared names
+}
+
+// uniqueName returns the name, or the name with the first number from 2 which
+// makes it unique, and declares it. TypeScript would merge the declarations of
+// interfaces with the same name instead of reporting them.
+func (g *tsGen) uniqueName(name string) string {
+	res := name
+	for i := 2; g.names[res]; i++ {
+		res = fmt.Sprintf("%s%d", name, i)
+	}
+	g.names[res] = true
+	return res
+}
+
+// iface declares an interface with a unique name and returns the name.
+func (g *tsGen) iface(name string, doc string, fields []*BoundField) string {
+	name = g.uniqueName(name)
+	fmt.Fprintf(&g.sb, "\n/** %s */\nexport interface %s {", doc, name)
+	if len(fields) == 0 {
+		g.sb.WriteString("}\n")
+		return name
+	}
+	g.sb.WriteString("\n")
+	for _, f := range fields {
+		fmt.Fprintf(&g.sb, "  %s: %s;\n", f.Name, tsType(f))
+	}
+	g.sb.WriteString("}\n")
+	return name
+}
+
+func (g *tsGen) union(name string, doc string, members []string) {
+	if len(members) == 0 {
+		members = []string{"never"}
+	}
+	fmt.Fprintf(&g.sb, "\n/** %s */\nexport type %s = %s;\n", doc, name, strings.Join(members, " | "))
+}
@@ -0,0 +1,33 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGenerateTypeScript compares the types generated from testdata/gen/*.kf
+// with the .ts files beside them.
+func TestGenerateTypeScript(t *testing.T) {
+	for _, name := range []string{"shop", "clash"} {
+		t.Run(name, func(t *testing.T) {
+			text, err := os.ReadFile("testdata/gen/" + name + ".kf")
+			require.NoError(t, err)
+			golden(t, "testdata/gen/"+name+".ts", GenerateTypeScript(bindings(t, string(text))))
+		})
+	}
+}
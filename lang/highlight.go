@@ -0,0 +1,240 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import "strings"
+
+type HighlightKind int
+
+const (
+	HlKeyword HighlightKind = iota
+	HlModifier
+	HlNamespace
+	HlTable
+	HlColumn
+	HlIndex
+	HlParameter
+	HlVariable
+	HlFunction
+	HlType
+	HlOperator
+	HlString
+	HlNumber
+	HlComment
+)
+
+type HighlightMod int
+
+const (
+	ModDeclaration HighlightMod = 1 << iota
+	ModReadonly
+	ModDefaultLibrary
+)
+
+type Highlight struct {
+	Start int
+	End   int
+	Kind  HighlightKind
+	Mods  HighlightMod
+}
+
+// BuiltinFunctions are the functions available in every schema without an extension.
+var BuiltinFunctions = map[string]bool{
+	"abs": true, "coalesce": true, "count": true, "sum": true, "min": true, "max": true,
+	"lower": true, "upper": true, "length": true, "format": true, "now": true, "error": true,
+	"array_append": true, "array_length": true, "nullif": true, "bit_length": true,
+}
+
+var operators = map[TokKind]bool{
+	T_ASSIGN: true, T_PLUS: true, T_MINUS: true, T_STAR: true, T_DIV: true, T_MOD: true,
+	T_TILDE: true, T_LOGIC_OR: true, T_LSHIFT: true, T_RSHIFT: true, T_AND: true, T_OR: true,
+	T_EQ: true, T_LESS: true, T_LESS_EQ: true, T_GT: true, T_GT_EQ: true, T_NOT_EQ: true, T_NEQ: true,
+}
+
+type highlighter struct {
+	res      []Highlight
+	params   map[string]bool
+	assigned map[string]bool
+}
+
+// Highlights classifies the tokens of a file for semantic highlighting. Names
+// are classified by what they refer to, e.g. a $name is either a parameter of the
+// enclosing action or a local variable, which is declared by its first assignment.
+func Highlights(fr *FileRoot) []Highlight {
+	h := highlighter{}
+	h.visit(fr)
+	return h.res
+}
+
+func (h *highlighter) add(t *TokNode, kind HighlightKind, mods HighlightMod) {
+	h.res = append(h.res, Highlight{
+		Start: t.Start(),
+		End:   t.End(),
+		Kind:  kind,
+		Mods:  mods,
+	})
+}
+
+func (h *highlighter) visit(n AstNode) {
+	if ad, ok := n.(*ActionDecl); ok {
+		h.params = map[string]bool{}
+		h.assigned = map[string]bool{}
+		for _, p := range ad.Params() {
+			h.params[strings.ToLower(p.Name())] = true
+		}
+	}
+
+	ids := 0
+	for _, c := range n.Children() {
+		t, ok := c.(*TokNode)
+		if !ok {
+			h.visit(c)
+			continue
+		}
+		switch {
+		case t.tok.kind == T_COMMENT:
+			h.add(t, HlComment, 0)
+		case t.tok.kind == T_STRING:
+			h.add(t, HlString, 0)
+		case t.tok.kind == T_NUM:
+			h.add(t, HlNumber, 0)
+		case operators[t.tok.kind]:
+			h.add(t, HlOperator, 0)
+		case isActionModifier(t.tok.kind):
+			h.add(t, HlModifier, 0)
+		case t.tok.kind == T_DOLLAR || t.tok.kind == T_AT || t.tok.kind == T_HASH:
+			// highlighted together with the following name
+		case t.tok.kind == T_ID:
+			h.id(n, t, ids)
+			ids++
+		default:
+			if isKeyword(t.tok.kind) {
+				h.add(t, HlKeyword, 0)
+			}
+		}
+	}
+}
+
+// id classifies the idx-th identifier of the parent node.
+func (h *highlighter) id(parent AstNode, t *TokNode, idx int) {
+	prefix := func() {
+		ns := parent.Children()
+		for i, c := range ns {
+			if c == AstNode(t) && i > 0 {
+				p, ok := ns[i-1].(*TokNode)
+				if ok && p.End() == t.Start() && (p.tok.kind == T_DOLLAR || p.tok.kind == T_AT || p.tok.kind == T_HASH) {
+					h.res[len(h.res)-1].Start = p.Start()
+				}
+			}
+		}
+	}
+
+	switch parent.(type) {
+	case *DbDirective:
+		h.add(t, HlNamespace, ModDeclaration)
+	case *ExtDirective:
+		if idx == 0 {
+			h.add(t, HlNamespace, 0)
+		} else {
+			h.add(t, HlNamespace, ModDeclaration)
+		}
+	case *ExtParam:
+		h.add(t, HlParameter, 0)
+	case *TableDecl:
+		h.add(t, HlTable, ModDeclaration)
+	case *ColumnDecl:
+		h.add(t, HlColumn, ModDeclaration)
+	case *TypeRef:
+		h.add(t, HlType, ModDefaultLibrary)
+	case *ColumnAttr, *FkAction:
+		h.add(t, HlKeyword, 0)
+	case *IndexDecl:
+		if idx == 0 {
+			h.add(t, HlIndex, ModDeclaration)
+			prefix()
+		} else {
+			h.add(t, HlKeyword, 0)
+		}
+	case *ForeignKeyDecl:
+		lower := strings.ToLower(t.Text())
+		if idx == 0 || lower == "ref" && idx == 1 {
+			h.add(t, HlKeyword, 0)
+		} else {
+			h.add(t, HlTable, 0)
+		}
+	case *ColumnList, *SetItem:
+		h.add(t, HlColumn, 0)
+	case *ActionDecl:
+		h.add(t, HlFunction, ModDeclaration)
+	case *ParamDecl:
+		h.add(t, HlParameter, ModDeclaration)
+		prefix()
+	case *AssignStmt:
+		name := "$" + strings.ToLower(t.Text())
+		if h.params[name] {
+			h.add(t, HlParameter, 0)
+		} else if !h.assigned[name] {
+			h.assigned[name] = true
+			h.add(t, HlVariable, ModDeclaration)
+		} else {
+			h.add(t, HlVariable, 0)
+		}
+		prefix()
+	case *ForStmt:
+		h.assigned["$"+strings.ToLower(t.Text())] = true
+		h.add(t, HlVariable, ModDeclaration)
+		prefix()
+	case *ReturnColumn:
+		h.add(t, HlColumn, ModDeclaration)
+	case *Annotation:
+		if idx == 0 {
+			h.add(t, HlModifier, 0)
+			prefix()
+		} else {
+			h.add(t, HlParameter, 0)
+		}
+	case *VarExpr:
+		if idx > 0 {
+			h.add(t, HlColumn, 0)
+		} else if h.params["$"+strings.ToLower(t.Text())] {
+			h.add(t, HlParameter, 0)
+		} else {
+			h.add(t, HlVariable, 0)
+		}
+		prefix()
+	case *CtxVarExpr:
+		h.add(t, HlVariable, ModReadonly|ModDefaultLibrary)
+		prefix()
+	case *TableRef:
+		if idx == 0 {
+			h.add(t, HlTable, 0)
+		} else {
+			h.add(t, HlTable, ModDeclaration)
+		}
+	case *ColumnExpr:
+		if idx == 0 && findTok(parent.Children(), T_DOT) != nil {
+			h.add(t, HlTable, 0)
+		} else {
+			h.add(t, HlColumn, 0)
+		}
+	case *ResultColumn:
+		h.add(t, HlColumn, ModDeclaration)
+	case *CallExpr:
+		if BuiltinFunctions[strings.ToLower(t.Text())] {
+			h.add(t, HlFunction, ModDefaultLibrary)
+		} else {
+			h.add(t, HlFunction, 0)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hl struct {
+	text string
+	kind HighlightKind
+	mods HighlightMod
+}
+
+func highlights(text string) []hl {
+	res := []hl{}
+	for _, h := range Highlights(ParseFile(text)) {
+		res = append(res, hl{text[h.Start:h.End], h.Kind, h.Mods})
+	}
+	return res
+}
+
+func TestHighlightTable(t *testing.T) {
+	assert.Equal(t, []hl{
+		{"database", HlKeyword, 0},
+		{"d", HlNamespace, ModDeclaration},
+		{"/* users */", HlComment, 0},
+		{"table", HlKeyword, 0},
+		{"users", HlTable, ModDeclaration},
+		{"id", HlColumn, ModDeclaration},
+		{"int", HlType, ModDefaultLibrary},
+		{"default", HlKeyword, 0},
+		{"0", HlNumber, 0},
+		{"#idx", HlIndex, ModDeclaration},
+		{"index", HlKeyword, 0},
+		{"id", HlColumn, 0},
+	}, highlights("database d;\n/* users */\ntable users { id int default(0), #idx index(id) }"))
+}
+
+func TestHighlightAction(t *testing.T) {
+	assert.Equal(t, []hl{
+		{"action", HlKeyword, 0},
+		{"a", HlFunction, ModDeclaration},
+		{"$p", HlParameter, ModDeclaration},
+		{"public", HlModifier, 0},
+		{"$x", HlVariable, ModDeclaration},
+		{"=", HlOperator, 0},
+		{"$p", HlParameter, 0},
+		{"+", HlOperator, 0},
+		{"'s'", HlString, 0},
+		{"$x", HlVariable, 0},
+		{"=", HlOperator, 0},
+		{"abs", HlFunction, ModDefaultLibrary},
+		{"$x", HlVariable, 0},
+		{"SELECT", HlKeyword, 0},
+		{"u", HlTable, 0},
+		{"name", HlColumn, 0},
+		{"n", HlColumn, ModDeclaration},
+		{"FROM", HlKeyword, 0},
+		{"users", HlTable, 0},
+		{"u", HlTable, ModDeclaration},
+		{"WHERE", HlKeyword, 0},
+		{"id", HlColumn, 0},
+		{"=", HlOperator, 0},
+		{"@caller", HlVariable, ModReadonly | ModDefaultLibrary},
+	}, highlights("action a($p) public { $x = $p + 's'; $x = abs($x); SELECT u.name n FROM users u WHERE id = @caller; }"))
+}
@@ -0,0 +1,1082 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Interpreter runs the actions and procedures of a schema against tables kept
+// in memory, so that they can be tried without a node.
+//
+// Statements other than SQL are evaluated by the interpreter, while SQL runs on
+// an in-memory SQLite database. Results can differ from a node's running
+// PostgreSQL, e.g. decimals are kept as floats by SQLite, and integer overflow
+// and division by zero in SQL aren't errors.
+type Interpreter struct {
+	fr *FileRoot
+	db *memDB
+	// tx is the transaction of the running call
+	tx *sql.Tx
+	// Owner is the caller allowed to call the actions with the owner modifier.
+	Owner string
+}
+
+// CallContext has the values of the context variables of a call.
+type CallContext struct {
+	Caller         string
+	Signer         []byte
+	TxID           string
+	Height         int64
+	BlockTimestamp int64
+	Authenticator  string
+}
+
+// Result has the rows of the last SELECT of an action or the rows a procedure
+// returns.
+type Result struct {
+	Columns []string
+	Rows    [][]any
+}
+
+// MarshalJSON writes the rows with decimals as numbers that keep their
+// precision.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	rows := [][]any{}
+	for _, row := range r.Rows {
+		values := []any{}
+		for _, v := range row {
+			values = append(values, jsonValue(v))
+		}
+		rows = append(rows, values)
+	}
+	return json.Marshal(map[string]any{"columns": r.Columns, "rows": rows})
+}
+
+// RunError is an error raised by a statement, such as a call of error() or a
+// violated constraint.
+type RunError struct {
+	Start   int
+	End     int
+	Message string
+}
+
+func (e *RunError) Error() string {
+	return e.Message
+}
+
+func runError(n AstNode, format string, args ...any) *RunError {
+	return &RunError{Start: n.Start(), End: n.End(), Message: fmt.Sprintf(format, args...)}
+}
+
+// maxCallDepth limits the nesting of calls, which would recurse forever in an
+// action calling itself.
+const maxCallDepth = 64
+
+// NewInterpreter returns an interpreter with empty tables, or the errors of
+// the schema if it has some.
+func NewInterpreter(fr *FileRoot) (*Interpreter, []Diagnostic) {
+	if diags := Check(fr); len(diags) > 0 {
+		return nil, diags
+	}
+	db, err := openMemDB(fr)
+	if err != nil {
+		d := Diagnostic{Severity: SevError, Message: err.Error()}
+		var re *RunError
+		if errors.As(err, &re) {
+			d.Start, d.End = re.Start, re.End
+		}
+		return nil, []Diagnostic{d}
+	}
+	return &Interpreter{fr: fr, db: db}, nil
+}
+
+// Close releases the database of the interpreter.
+func (in *Interpreter) Close() error {
+	return in.db.db.Close()
+}
+
+// State returns the rows of each table in a form encoding/json writes and
+// SetState reads back.
+func (in *Interpreter) State() (map[string][][]any, error) {
+	res := map[string][][]any{}
+	for _, td := range in.fr.TableDecls() {
+		types := []string{}
+		for _, cd := range td.Columns() {
+			types = append(types, pgTypeName(cd.Type()))
+		}
+		rows, err := in.db.db.Query(fmt.Sprintf("SELECT * FROM %s ORDER BY rowid", sqliteIdent(td.Name())))
+		if err != nil {
+			return nil, err
+		}
+		values, err := scanRows(rows, types)
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("table '%s': %v", td.Name(), err)
+		}
+		for _, row := range values {
+			for i, v := range row {
+				row[i] = jsonValue(v)
+			}
+		}
+		res[td.Name()] = values
+	}
+	return res, nil
+}
+
+// SetState replaces the rows of the tables in the state. Foreign keys aren't
+// checked, as the state is expected to come from State.
+func (in *Interpreter) SetState(state map[string][][]any) error {
+	// foreign keys can only be turned off outside of a transaction
+	if _, err := in.db.db.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer in.db.db.Exec("PRAGMA foreign_keys = ON")
+	tx, err := in.db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for name, rows := range state {
+		td := in.fr.TableDecl(name)
+		if td == nil {
+			return fmt.Errorf("unknown table '%s'", name)
+		}
+		if _, err := tx.Exec("DELETE FROM " + sqliteIdent(td.Name())); err != nil {
+			return err
+		}
+		cols := td.Columns()
+		params := strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")
+		insert := fmt.Sprintf("INSERT INTO %s VALUES (%s)", sqliteIdent(td.Name()), params)
+		for i, row := range rows {
+			if len(row) != len(cols) {
+				return fmt.Errorf("row %d of '%s' has %d values for %d columns", i+1, name, len(row), len(cols))
+			}
+			values := []any{}
+			for j, v := range row {
+				v, err := coerce(v, cols[j].Type())
+				if err == nil {
+					v, err = toSQLite(v)
+				}
+				if err != nil {
+					return fmt.Errorf("row %d of '%s', column '%s': %v", i+1, name, cols[j].Name(), err)
+				}
+				values = append(values, v)
+			}
+			if _, err := tx.Exec(insert, values...); err != nil {
+				return fmt.Errorf("row %d of '%s': %s", i+1, name, in.db.message(err))
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Call runs a public action or procedure. Either all of its changes are made
+// or, if it fails, none of them.
+func (in *Interpreter) Call(cc CallContext, name string, args ...any) (*Result, error) {
+	ad := in.fr.ActionDecl(name)
+	if ad == nil {
+		return nil, fmt.Errorf("unknown action or procedure '%s'", name)
+	}
+	if !ad.HasModifier(T_PUBLIC) {
+		return nil, fmt.Errorf("%s '%s' is private", ad.Kind(), ad.Name())
+	}
+	if ad.HasModifier(T_OWNER) && cc.Caller != in.Owner {
+		return nil, fmt.Errorf("%s '%s' can only be called by the owner", ad.Kind(), ad.Name())
+	}
+	values := []any{}
+	for _, a := range args {
+		v, err := normalizeValue(a)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	tx, err := in.db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	in.tx = tx
+	defer func() { in.tx = nil }()
+	res, err := in.call(&cc, ad, values, ad.HasModifier(T_VIEW), 0)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// normalizeValue converts a Go value to a value of the interpreter.
+func normalizeValue(v any) (any, error) {
+	switch v := v.(type) {
+	case nil, int64, string, bool, []byte:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case float64:
+		return new(big.Rat).SetFloat64(v), nil
+	case *big.Rat:
+		return v, nil
+	case *big.Int:
+		return new(big.Rat).SetInt(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if r, ok := new(big.Rat).SetString(v.String()); ok {
+			return r, nil
+		}
+	case []any:
+		res := []any{}
+		for _, e := range v {
+			e, err := normalizeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, e)
+		}
+		return res, nil
+	case []string:
+		res := []any{}
+		for _, e := range v {
+			res = append(res, e)
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v of type %T", v, v)
+}
+
+// frame is a running call of an action or procedure.
+type frame struct {
+	in   *Interpreter
+	cc   *CallContext
+	ad   *ActionDecl
+	vars map[string]any
+	// records has the queries of the loops over rows by loop variable, to
+	// tell the types of the fields of the rows
+	records  map[string]*SelectStmt
+	view     bool
+	depth    int
+	result   *Result
+	returned bool
+}
+
+func (in *Interpreter) call(cc *CallContext, ad *ActionDecl, args []any, view bool, depth int) (*Result, error) {
+	if depth > maxCallDepth {
+		return nil, fmt.Errorf("calls are nested more than %d deep", maxCallDepth)
+	}
+	params := ad.Params()
+	if len(args) != len(params) {
+		return nil, fmt.Errorf("%s '%s' takes %d argument(s), got %d", ad.Kind(), ad.Name(), len(params), len(args))
+	}
+	f := &frame{in: in, cc: cc, ad: ad, vars: map[string]any{}, records: map[string]*SelectStmt{}, view: view, depth: depth}
+	for i, pd := range params {
+		v, err := coerce(args[i], pd.Type())
+		if err != nil {
+			return nil, fmt.Errorf("argument %s of %s '%s': %v", pd.Name(), ad.Kind(), ad.Name(), err)
+		}
+		f.vars[strings.ToLower(pd.Name())] = v
+	}
+	rc := ad.Returns()
+	if rc != nil {
+		cols := []string{}
+		for _, c := range rc.Columns() {
+			cols = append(cols, strings.ToLower(c.Name()))
+		}
+		f.result = &Result{Columns: cols, Rows: [][]any{}}
+	}
+
+	if err := f.stmts(ad.Stmts(), true); err != nil {
+		return nil, err
+	}
+	if rc != nil && !rc.IsTable() && !f.returned {
+		return nil, fmt.Errorf("%s '%s' ended without returning a value", ad.Kind(), ad.Name())
+	}
+	if f.result == nil {
+		f.result = &Result{Columns: []string{}, Rows: [][]any{}}
+	}
+	return f.result, nil
+}
+
+func (f *frame) stmts(sts []Stmt, top bool) error {
+	for _, st := range sts {
+		if err := f.stmt(st, top); err != nil {
+			return err
+		}
+		if f.returned {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *frame) stmt(st Stmt, top bool) error {
+	switch st := st.(type) {
+	case *AssignStmt:
+		var v any
+		if e := st.Expr(); e != nil {
+			var err error
+			if v, err = f.eval(*e); err != nil {
+				return err
+			}
+		}
+		v, err := coerce(v, st.Type())
+		if err != nil {
+			return runError(st, "%s: %v", st.VarName(), err)
+		}
+		f.vars[strings.ToLower(st.VarName())] = v
+	case *SelectStmt:
+		res, err := f.query(st)
+		if err != nil {
+			return err
+		}
+		if top && !f.ad.IsProcedure() {
+			f.result = res
+		}
+	case *InsertStmt, *UpdateStmt, *DeleteStmt:
+		if f.view {
+			return runError(st, "view %s '%s' can't modify data", f.ad.Kind(), f.ad.Name())
+		}
+		return f.exec(st)
+	case *ReturnStmt:
+		return f.ret(st)
+	case *IfStmt:
+		blocks := st.Blocks()
+		for i, c := range st.Conds() {
+			ok, err := f.cond(c)
+			if err != nil {
+				return err
+			}
+			if ok {
+				if i < len(blocks) {
+					return f.stmts(blocks[i].Stmts(), false)
+				}
+				return nil
+			}
+		}
+		if b := st.Else(); b != nil {
+			return f.stmts(b.Stmts(), false)
+		}
+	case *ForStmt:
+		return f.loop(st)
+	case *CallStmt:
+		if ce := st.Call(); ce != nil {
+			_, err := f.callExpr(ce)
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *frame) ret(rs *ReturnStmt) error {
+	rc := f.ad.Returns()
+	if q := rs.Query(); q != nil {
+		res, err := f.query(q)
+		if err != nil {
+			return err
+		}
+		if f.result == nil {
+			f.result = res
+		} else if len(res.Columns) != len(f.result.Columns) {
+			return runError(rs, "the query returns %d column(s) but %s '%s' returns %d", len(res.Columns), f.ad.Kind(), f.ad.Name(), len(f.result.Columns))
+		} else {
+			for _, row := range res.Rows {
+				if err := f.addRow(rs, row); err != nil {
+					return err
+				}
+			}
+		}
+		f.returned = true
+		return nil
+	}
+
+	exprs := rs.Exprs()
+	if len(exprs) == 0 {
+		f.returned = true
+		return nil
+	}
+	if rc == nil {
+		return runError(rs, "%s '%s' doesn't return anything", f.ad.Kind(), f.ad.Name())
+	}
+	if len(exprs) != len(f.result.Columns) {
+		return runError(rs, "%d value(s) returned but %s '%s' returns %d", len(exprs), f.ad.Kind(), f.ad.Name(), len(f.result.Columns))
+	}
+	row := []any{}
+	for _, e := range exprs {
+		v, err := f.eval(e)
+		if err != nil {
+			return err
+		}
+		row = append(row, v)
+	}
+	if err := f.addRow(rs, row); err != nil {
+		return err
+	}
+	f.returned = !rs.IsNext()
+	return nil
+}
+
+// addRow adds a row to the result of a procedure, converted to the types it
+// declares.
+func (f *frame) addRow(n AstNode, row []any) error {
+	cols := f.ad.Returns().Columns()
+	res := []any{}
+	for i, v := range row {
+		v, err := coerce(v, cols[i].Type())
+		if err != nil {
+			return runError(n, "result column '%s': %v", cols[i].Name(), err)
+		}
+		res = append(res, v)
+	}
+	f.result.Rows = append(f.result.Rows, res)
+	return nil
+}
+
+func (f *frame) loop(fs *ForStmt) error {
+	name := strings.ToLower(fs.VarName())
+	body := func(v any) error {
+		f.vars[name] = v
+		if b := fs.Body(); b != nil {
+			return f.stmts(b.Stmts(), false)
+		}
+		return nil
+	}
+
+	switch {
+	case fs.Query() != nil:
+		res, err := f.query(fs.Query())
+		if err != nil {
+			return err
+		}
+		f.records[name] = fs.Query()
+		for _, row := range res.Rows {
+			rec := map[string]any{}
+			for i, c := range res.Columns {
+				rec[c] = row[i]
+			}
+			if err := body(rec); err != nil || f.returned {
+				return err
+			}
+		}
+	case fs.IsRange():
+		exprs := fs.Exprs()
+		if len(exprs) != 2 {
+			return nil
+		}
+		bounds := []int64{}
+		for _, e := range exprs {
+			v, err := f.eval(e)
+			if err != nil {
+				return err
+			}
+			i, ok := v.(int64)
+			if !ok {
+				return runError(e, "the bounds of a range must be ints, got %s", valueType(v))
+			}
+			bounds = append(bounds, i)
+		}
+		for i := bounds[0]; i <= bounds[1]; i++ {
+			if err := body(i); err != nil || f.returned {
+				return err
+			}
+		}
+	default:
+		exprs := fs.Exprs()
+		if len(exprs) == 0 {
+			return nil
+		}
+		v, err := f.eval(exprs[0])
+		if err != nil || v == nil {
+			return err
+		}
+		vs, ok := v.([]any)
+		if !ok {
+			return runError(exprs[0], "expected an array, got %s", valueType(v))
+		}
+		for _, e := range vs {
+			if err := body(e); err != nil || f.returned {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// query runs a SELECT on the database. The values of the rows have the types
+// the SQL generator gives the result columns.
+func (f *frame) query(ss *SelectStmt) (*Result, error) {
+	s := &sqlStmt{f: f}
+	text := s.sql(ss)
+	if s.err != nil {
+		return nil, s.err
+	}
+	rows, err := f.in.tx.Query(text, s.args...)
+	if err != nil {
+		return nil, f.sqlError(ss, err)
+	}
+	defer rows.Close()
+
+	res := &Result{Columns: []string{}, Rows: [][]any{}}
+	types := []string{}
+	for _, c := range f.types().resultColumns(ss) {
+		res.Columns = append(res.Columns, strings.Trim(c.name, `"`))
+		types = append(types, c.typ)
+	}
+	if res.Rows, err = scanRows(rows, types); err != nil {
+		return nil, f.sqlError(ss, err)
+	}
+	return res, nil
+}
+
+// exec runs an INSERT, UPDATE or DELETE on the database.
+func (f *frame) exec(st Stmt) error {
+	s := &sqlStmt{f: f}
+	text := s.sql(st)
+	if s.err != nil {
+		return s.err
+	}
+	if _, err := f.in.tx.Exec(text, s.args...); err != nil {
+		return f.sqlError(st, err)
+	}
+	return nil
+}
+
+// types returns a generator of PL/pgSQL knowing the types of the variables
+// of the frame, to tell the types of the columns of queries.
+func (f *frame) types() *fnGen {
+	vars := map[string]string{}
+	for name, v := range f.vars {
+		vars[name] = pgValueType(v)
+	}
+	return &fnGen{g: &sqlGen{fr: f.in.fr}, c: &checker{fr: f.in.fr}, ad: f.ad, vars: vars, records: f.records}
+}
+
+// sqlError converts an error of SQLite to a RunError, with the messages of
+// violated constraints written with the names of the schema.
+func (f *frame) sqlError(st Stmt, err error) error {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return runError(st, "%v", err)
+	}
+	msg := f.in.db.message(err)
+	_, detail, _ := strings.Cut(msg, ": ")
+	switch se.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+		table, cols := f.constraintColumns(detail)
+		return runError(st, "duplicate key (%s) in '%s'", strings.Join(cols, ", "), table)
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		table, cols := f.constraintColumns(detail)
+		return runError(st, "null value in column '%s' of '%s' violates the not null constraint", strings.Join(cols, ", "), table)
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		verb, tr := "", (*TableRef)(nil)
+		switch st := st.(type) {
+		case *InsertStmt:
+			verb, tr = "insert", st.Table()
+		case *UpdateStmt:
+			verb, tr = "update", st.Table()
+		case *DeleteStmt:
+			verb, tr = "delete", st.Table()
+		}
+		if tr != nil {
+			table := tr.Name()
+			if td := f.in.fr.TableDecl(table); td != nil {
+				table = td.Name()
+			}
+			return runError(st, "%s on '%s' violates a foreign key", verb, table)
+		}
+	}
+	return runError(st, "%s", msg)
+}
+
+// constraintColumns returns the table and the columns of a constraint as
+// SQLite reports them, e.g. "users.id, users.name", with the names they are
+// declared with.
+func (f *frame) constraintColumns(detail string) (string, []string) {
+	table, cols := "", []string{}
+	for _, c := range strings.Split(detail, ", ") {
+		t, col, _ := strings.Cut(c, ".")
+		table = t
+		if td := f.in.fr.TableDecl(t); td != nil {
+			table = td.Name()
+			if cd := td.Column(col); cd != nil {
+				col = cd.Name()
+			}
+		}
+		cols = append(cols, col)
+	}
+	return table, cols
+}
+
+// sqlStmt renders a SQL statement for SQLite. Variables, context variables
+// and calls of actions are evaluated and passed as the arguments of the
+// statement.
+type sqlStmt struct {
+	f    *frame
+	args []any
+	err  error
+}
+
+func (s *sqlStmt) sql(st Stmt) string {
+	ls := s.leaves(nil, []AstNode{st}, []leaf{})
+	sb := strings.Builder{}
+	for i, l := range ls {
+		if i > 0 && needSpace(ls[i-1], l) {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(leafText(l, KeywordUpper))
+	}
+	return sb.String()
+}
+
+func (s *sqlStmt) leaves(parent AstNode, ns []AstNode, res []leaf) []leaf {
+	for _, n := range ns {
+		switch n := n.(type) {
+		case *VarExpr:
+			res = append(res, s.bind(n))
+		case *CtxVarExpr:
+			res = append(res, s.bind(n))
+		case *CallExpr:
+			if s.f.in.fr.ActionDecl(n.Name()) != nil {
+				res = append(res, s.bind(n))
+				continue
+			}
+			res = s.leaves(n, n.Children(), res)
+		case *OrderClause:
+			res = s.order(n, res)
+		case *TokNode:
+			switch n.tok.kind {
+			case T_WS, T_COMMENT:
+			case T_EQ:
+				res = append(res, synthLeaf(parent, T_ASSIGN, "="))
+			case T_ID:
+				text := sqliteIdent(n.Text())
+				if _, ok := parent.(*CallExpr); ok {
+					text = sqliteFunction(n.Text())
+				}
+				res = append(res, synthLeaf(parent, T_ID, text))
+			default:
+				res = append(res, leaf{tok: n, parent: parent})
+			}
+		default:
+			res = s.leaves(n, n.Children(), res)
+		}
+	}
+	return res
+}
+
+// bind evaluates an expression and returns the parameter standing for its
+// value.
+func (s *sqlStmt) bind(e Expr) leaf {
+	if s.err == nil {
+		v, err := s.f.eval(e)
+		if err == nil {
+			v, err = toSQLite(v)
+			if err != nil {
+				err = runError(e, "%v", err)
+			}
+		}
+		s.err = err
+		s.args = append(s.args, v)
+	}
+	return synthLeaf(e, T_ID, fmt.Sprintf("?%d", len(s.args)))
+}
+
+// order renders ORDER BY with nulls sorted like PostgreSQL does, last in
+// ascending order and first in descending order, while SQLite sorts them
+// first.
+func (s *sqlStmt) order(oc *OrderClause, res []leaf) []leaf {
+	desc := oc.Desc()
+	i := 0
+	nulls := func() {
+		if i < len(desc) {
+			text := "NULLS LAST"
+			if desc[i] {
+				text = "NULLS FIRST"
+			}
+			res = append(res, synthLeaf(oc, T_ID, text))
+			i++
+		}
+	}
+	for _, c := range oc.Children() {
+		if t, ok := c.(*TokNode); ok && t.tok.kind == T_COMMA {
+			nulls()
+		}
+		res = s.leaves(oc, []AstNode{c}, res)
+	}
+	nulls()
+	return res
+}
+
+// cond evaluates a condition, where null counts as false.
+func (f *frame) cond(e Expr) (bool, error) {
+	v, err := f.eval(e)
+	if err != nil || v == nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, runError(e, "expected bool, got %s", valueType(v))
+	}
+	return b, nil
+}
+
+// eval evaluates an expression outside of SQL, or a variable used in SQL.
+func (f *frame) eval(e Expr) (any, error) {
+	switch e := e.(type) {
+	case *IntLitExpr:
+		text := ""
+		if t := findTok(e.Children(), T_NUM); t != nil {
+			text = t.Text()
+		}
+		return normalizeValue(json.Number(text))
+	case *StringLitExpr:
+		return e.Value(), nil
+	case *BoolLitExpr:
+		return e.Value(), nil
+	case *NullLitExpr:
+		return nil, nil
+	case *VarExpr:
+		v, ok := f.vars[strings.ToLower(e.VarName())]
+		if !ok {
+			return nil, runError(e, "variable %s isn't set", e.VarName())
+		}
+		if e.Field() == "" {
+			return v, nil
+		}
+		rec, ok := v.(map[string]any)
+		if !ok {
+			return nil, runError(e, "%s isn't a record", e.VarName())
+		}
+		fv, ok := rec[strings.ToLower(e.Field())]
+		if !ok {
+			return nil, runError(e, "record %s has no field '%s'", e.VarName(), e.Field())
+		}
+		return fv, nil
+	case *CtxVarExpr:
+		switch strings.ToLower(e.VarName()) {
+		case "@caller":
+			return f.cc.Caller, nil
+		case "@signer":
+			return f.cc.Signer, nil
+		case "@txid":
+			return f.cc.TxID, nil
+		case "@height":
+			return f.cc.Height, nil
+		case "@block_timestamp":
+			return f.cc.BlockTimestamp, nil
+		case "@authenticator":
+			return f.cc.Authenticator, nil
+		case "@foreign_caller":
+			return "", nil
+		}
+		return nil, runError(e, "unknown context variable %s", e.VarName())
+	case *ColumnExpr:
+		return nil, runError(e, "column '%s' can only be used in SQL", inlineText(e))
+	case *ParenExpr:
+		if e.Expr() != nil {
+			return f.eval(*e.Expr())
+		}
+	case *IsNullExpr:
+		if e.Expr() != nil {
+			v, err := f.eval(*e.Expr())
+			if err != nil {
+				return nil, err
+			}
+			return (v == nil) != e.Negated(), nil
+		}
+	case *UnaryExpr:
+		if e.Operand() != nil {
+			v, err := f.eval(*e.Operand())
+			if err != nil || v == nil {
+				return nil, err
+			}
+			return unaryOp(e, v)
+		}
+	case *BinExpr:
+		if e.Left() != nil && e.Right() != nil {
+			return f.binary(e)
+		}
+	case *CallExpr:
+		return f.callExpr(e)
+	}
+	return nil, runError(e, "can't evaluate '%s'", inlineText(e))
+}
+
+func unaryOp(ue *UnaryExpr, v any) (any, error) {
+	switch ue.Op() {
+	case T_NOT:
+		if b, ok := v.(bool); ok {
+			return !b, nil
+		}
+	case T_PLUS:
+		if _, ok := toRat(v); ok {
+			return v, nil
+		}
+	case T_MINUS:
+		switch v := v.(type) {
+		case int64:
+			if v == math.MinInt64 {
+				return nil, runError(ue, "%v", errBigintRange)
+			}
+			return -v, nil
+		case *big.Rat:
+			return new(big.Rat).Neg(v), nil
+		}
+	}
+	return nil, runError(ue, "operator %s doesn't take %s", ue.Op(), valueType(v))
+}
+
+func (f *frame) binary(be *BinExpr) (any, error) {
+	l, err := f.eval(*be.Left())
+	if err != nil {
+		return nil, err
+	}
+	op := be.Op()
+	if op == T_KW_AND || op == T_KW_OR {
+		return f.logic(be, l)
+	}
+	r, err := f.eval(*be.Right())
+	if err != nil || l == nil || r == nil {
+		return nil, err
+	}
+
+	switch op {
+	case T_LOGIC_OR:
+		return FormatValue(l) + FormatValue(r), nil
+	case T_PLUS, T_MINUS, T_STAR, T_DIV, T_MOD:
+		v, err := arith(op, l, r)
+		if err != nil {
+			return nil, runError(be, "%v", err)
+		}
+		return v, nil
+	}
+	c, err := compareValues(l, r)
+	if err != nil {
+		return nil, runError(be, "%v", err)
+	}
+	switch op {
+	case T_ASSIGN, T_EQ:
+		return c == 0, nil
+	case T_NOT_EQ, T_NEQ:
+		return c != 0, nil
+	case T_LESS:
+		return c < 0, nil
+	case T_LESS_EQ:
+		return c <= 0, nil
+	case T_GT:
+		return c > 0, nil
+	case T_GT_EQ:
+		return c >= 0, nil
+	}
+	return nil, runError(be, "unsupported operator %s", op)
+}
+
+// logic evaluates AND and OR with the three-valued logic of SQL, where null is
+// unknown.
+func (f *frame) logic(be *BinExpr, l any) (any, error) {
+	and := be.Op() == T_KW_AND
+	lb, ok := l.(bool)
+	if l != nil && !ok {
+		return nil, runError(*be.Left(), "expected bool, got %s", valueType(l))
+	}
+	if l != nil && lb != and {
+		return lb, nil
+	}
+	r, err := f.eval(*be.Right())
+	if err != nil {
+		return nil, err
+	}
+	rb, ok := r.(bool)
+	if r != nil && !ok {
+		return nil, runError(*be.Right(), "expected bool, got %s", valueType(r))
+	}
+	switch {
+	case r != nil && rb != and:
+		return rb, nil
+	case l == nil || r == nil:
+		return nil, nil
+	}
+	return and, nil
+}
+
+var errBigintRange = fmt.Errorf("bigint out of range")
+
+func arith(op TokKind, l any, r any) (any, error) {
+	li, lok := l.(int64)
+	ri, rok := r.(int64)
+	if lok && rok {
+		// int8 wraps around in Go, while PostgreSQL reports the overflow
+		switch op {
+		case T_PLUS:
+			if v := li + ri; (li^v)&(ri^v) >= 0 {
+				return v, nil
+			}
+			return nil, errBigintRange
+		case T_MINUS:
+			if v := li - ri; (li^ri)&(li^v) >= 0 {
+				return v, nil
+			}
+			return nil, errBigintRange
+		case T_STAR:
+			if v := li * ri; li == 0 || v/li == ri && !(li == -1 && ri == math.MinInt64) {
+				return v, nil
+			}
+			return nil, errBigintRange
+		}
+		if ri == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if op == T_DIV {
+			if li == math.MinInt64 && ri == -1 {
+				return nil, errBigintRange
+			}
+			return li / ri, nil
+		}
+		return li % ri, nil
+	}
+
+	lr, lok := toRat(l)
+	rr, rok := toRat(r)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s doesn't take %s and %s", op, valueType(l), valueType(r))
+	}
+	switch op {
+	case T_PLUS:
+		return new(big.Rat).Add(lr, rr), nil
+	case T_MINUS:
+		return new(big.Rat).Sub(lr, rr), nil
+	case T_STAR:
+		return new(big.Rat).Mul(lr, rr), nil
+	case T_DIV:
+		if rr.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return new(big.Rat).Quo(lr, rr), nil
+	}
+	return nil, fmt.Errorf("operator %s doesn't take %s and %s", op, valueType(l), valueType(r))
+}
+
+func isAggregate(name string) bool {
+	switch strings.ToLower(name) {
+	case "count", "sum", "min", "max", "avg":
+		return true
+	}
+	return false
+}
+
+func (f *frame) callExpr(ce *CallExpr) (any, error) {
+	name := strings.ToLower(ce.Name())
+	if isAggregate(name) {
+		return nil, runError(ce, "aggregate function %s can only be used in SQL", ce.Name())
+	}
+	args := []any{}
+	for _, a := range ce.Args() {
+		v, err := f.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+
+	if callee := f.in.fr.ActionDecl(ce.Name()); callee != nil {
+		res, err := f.in.call(f.cc, callee, args, f.view, f.depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Rows) > 0 && len(res.Columns) > 0 {
+			return res.Rows[0][0], nil
+		}
+		return nil, nil
+	}
+
+	arg := func(i int) any {
+		if i < len(args) {
+			return args[i]
+		}
+		return nil
+	}
+	switch name {
+	case "error":
+		msg, ok := arg(0).(string)
+		if !ok {
+			msg = FormatValue(arg(0))
+		}
+		return nil, runError(ce, "%s", msg)
+	case "coalesce":
+		for _, a := range args {
+			if a != nil {
+				return a, nil
+			}
+		}
+		return nil, nil
+	case "concat":
+		sb := strings.Builder{}
+		for _, a := range args {
+			if a != nil {
+				sb.WriteString(FormatValue(a))
+			}
+		}
+		return sb.String(), nil
+	}
+	if len(args) != 1 {
+		return nil, runError(ce, "unknown function '%s' with %d argument(s)", ce.Name(), len(args))
+	}
+	switch v := args[0].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		switch name {
+		case "upper":
+			return strings.ToUpper(v), nil
+		case "lower":
+			return strings.ToLower(v), nil
+		case "trim":
+			return strings.TrimSpace(v), nil
+		case "length":
+			return int64(len([]rune(v))), nil
+		}
+	case int64:
+		if name == "abs" {
+			if v < 0 {
+				return -v, nil
+			}
+			return v, nil
+		}
+	case *big.Rat:
+		if name == "abs" {
+			return new(big.Rat).Abs(v), nil
+		}
+	case []any:
+		if name == "array_length" {
+			return int64(len(v)), nil
+		}
+	}
+	return nil, runError(ce, "unknown function '%s' of %s", ce.Name(), valueType(args[0]))
+}
@@ -0,0 +1,273 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func interpreter(t *testing.T, text string) *Interpreter {
+	in, diags := NewInterpreter(ParseFile(text))
+	require.Empty(t, diags)
+	t.Cleanup(func() { in.Close() })
+	return in
+}
+
+// run calls an action and returns its result with a line per row, or the
+// error.
+func run(in *Interpreter, cc CallContext, name string, args ...any) string {
+	res, err := in.Call(cc, name, args...)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	lines := []string{strings.Join(res.Columns, " ")}
+	for _, row := range res.Rows {
+		values := []string{}
+		for _, v := range row {
+			values = append(values, FormatValue(v))
+		}
+		lines = append(lines, strings.Join(values, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+const interpSchema = `database d;
+table users {
+	id int primary,
+	name text notnull maxlen(10),
+	age int min(0) default(18),
+	#name_idx unique(name)
+}
+table posts {
+	id int primary,
+	author int,
+	body text,
+	foreign_key (author) references users(id) on_delete cascade on_update cascade
+}
+table likes {
+	post int,
+	foreign_key (post) references posts(id)
+}
+
+action add_user($id, $name) public {
+	INSERT INTO users (id, name) VALUES ($id, $name);
+}
+action set_age($id, $age) public {
+	UPDATE users SET age = $age WHERE id = $id;
+}
+action rename($id, $name) public {
+	INSERT INTO users (id, name) VALUES ($id, $name)
+	ON CONFLICT (id) DO UPDATE SET name = excluded.name;
+}
+action add_post($id, $author, $body) public {
+	INSERT INTO posts VALUES ($id, $author, $body);
+}
+action like($post) public {
+	INSERT INTO likes VALUES ($post);
+}
+action move_user($id, $new_id) public {
+	UPDATE users SET id = $new_id WHERE id = $id;
+}
+action remove_user($id) public {
+	DELETE FROM users WHERE id = $id;
+}
+action users() public view {
+	SELECT * FROM users ORDER BY name DESC;
+}
+action posts() public view {
+	SELECT p.id, u.name AS author, p.body
+	FROM posts AS p
+	LEFT JOIN users AS u ON p.author = u.id
+	ORDER BY p.id
+	LIMIT 2;
+}
+action stats() public view {
+	SELECT count(*) AS users, sum(age), max(name), avg(age) AS mean FROM users;
+}
+action sneaky() public view {
+	DELETE FROM users;
+}
+`
+
+func TestInterpreterTables(t *testing.T) {
+	in := interpreter(t, interpSchema)
+	cc := CallContext{Caller: "alice"}
+	assert.Equal(t, "", run(in, cc, "add_user", 1, "ann"))
+	assert.Equal(t, "", run(in, cc, "add_user", 2, "bob"))
+	assert.Equal(t, "", run(in, cc, "set_age", 2, 30))
+	assert.Equal(t, "id name age\n2 bob 30\n1 ann 18", run(in, cc, "users"))
+	assert.Equal(t, "users sum max mean\n2 48 bob 24", run(in, cc, "stats"))
+
+	assert.Equal(t, "", run(in, cc, "add_post", 10, 1, "hi"))
+	assert.Equal(t, "", run(in, cc, "add_post", 11, nil, "anon"))
+	assert.Equal(t, "", run(in, cc, "add_post", 12, 2, "yo"))
+	assert.Equal(t, "id author body\n10 ann hi\n11 NULL anon", run(in, cc, "posts"))
+
+	assert.Equal(t, "", run(in, cc, "rename", 1, "amy"))
+	assert.Equal(t, "", run(in, cc, "move_user", 1, 5))
+	assert.Equal(t, "id author body\n10 amy hi\n11 NULL anon", run(in, cc, "posts"))
+	state, err := in.State()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), state["posts"][0][1])
+
+	assert.Equal(t, "", run(in, cc, "remove_user", 5))
+	assert.Equal(t, "id author body\n11 NULL anon\n12 bob yo", run(in, cc, "posts"))
+
+	assert.Equal(t, "", run(in, cc, "like", 12))
+	assert.Equal(t, "error: delete on 'users' violates a foreign key", run(in, cc, "remove_user", 2))
+	assert.Equal(t, "id name age\n2 bob 30", run(in, cc, "users"))
+
+	assert.Equal(t, "error: duplicate key (id) in 'users'", run(in, cc, "add_user", 2, "cat"))
+	assert.Equal(t, "error: duplicate key (name) in 'users'", run(in, cc, "add_user", 3, "bob"))
+	assert.Equal(t, "error: null value in column 'name' of 'users' violates the not null constraint", run(in, cc, "add_user", 3, nil))
+	assert.Equal(t, "error: value of column 'age' of 'users' violates min(0)", run(in, cc, "set_age", 2, -1))
+	assert.Equal(t, "error: value of column 'name' of 'users' violates maxlen(10)", run(in, cc, "add_user", 3, "bartholomew"))
+	assert.Equal(t, "error: column 'id' of 'users': expected int", run(in, cc, "add_user", "x", "cat"))
+	assert.Equal(t, "error: insert on 'posts' violates a foreign key", run(in, cc, "add_post", 13, 7, "?"))
+	assert.Equal(t, "error: view action 'sneaky' can't modify data", run(in, cc, "sneaky"))
+	assert.Equal(t, "error: action 'users' takes 0 argument(s), got 1", run(in, cc, "users", 1))
+	assert.Equal(t, "error: unknown action or procedure 'nope'", run(in, cc, "nope"))
+}
+
+func TestInterpreterProcedures(t *testing.T) {
+	in := interpreter(t, `database d;
+table items { id int primary, price decimal(10, 2) notnull, creator text }
+
+procedure add($id int, $price decimal(10, 2)) public {
+	if $price <= 0 {
+		error('price must be positive');
+	}
+	INSERT INTO items VALUES ($id, $price, @caller);
+}
+procedure add_many($n int) public {
+	for $i in 1..$n {
+		add($i * 10, $i / 2 + 1);
+	}
+}
+procedure total() public view returns (n int, sum decimal(10, 2)) {
+	$sum decimal(10, 2) := 0;
+	$n := 0;
+	for $row in SELECT price FROM items {
+		$n := $n + 1;
+		$sum := $sum + $row.price;
+	}
+	return $n, $sum;
+}
+procedure cheap($max decimal(10, 2)) public view returns table(id int, price decimal(10, 2)) {
+	return SELECT id, price FROM items WHERE price <= $max ORDER BY price DESC, id;
+}
+procedure labels($names text[]) public view returns table(label text) {
+	for $name in $names {
+		if $name IS NULL {
+			return next 'none';
+		} elseif length($name) > 3 {
+			return next upper($name) || '!';
+		} else {
+			return next coalesce($name, '?');
+		}
+	}
+}
+procedure describe() public view returns (info text) {
+	return @caller || ' at ' || @height;
+}
+procedure owned() public owner {
+	DELETE FROM items;
+}
+procedure hidden() private view {
+}
+`)
+	in.Owner = "root"
+	cc := CallContext{Caller: "alice", Height: 7}
+	assert.Equal(t, "", run(in, cc, "add_many", 3))
+	assert.Equal(t, "n sum\n3 5", run(in, cc, "total"))
+	assert.Equal(t, "id price\n20 2\n30 2\n10 1", run(in, cc, "cheap", "2"))
+	assert.Equal(t, "error: price must be positive", run(in, cc, "add", 40, "0"))
+	assert.Equal(t, "error: duplicate key (id) in 'items'", run(in, cc, "add_many", 4))
+	assert.Equal(t, "n sum\n3 5", run(in, cc, "total"))
+	assert.Equal(t, "label\nBOBBY!\nnone\nann", run(in, cc, "labels", []any{"bobby", nil, "ann"}))
+	assert.Equal(t, "error: procedure 'owned' can only be called by the owner", run(in, cc, "owned"))
+	assert.Equal(t, "", run(in, CallContext{Caller: "root"}, "owned"))
+	assert.Equal(t, "error: procedure 'hidden' is private", run(in, cc, "hidden"))
+	assert.Equal(t, "info\nalice at 7", run(in, cc, "describe"))
+}
+
+func TestInterpreterOverflow(t *testing.T) {
+	in := interpreter(t, `database d;
+procedure mul($a int, $b int) public view returns (r int) { return $a * $b; }
+procedure neg($a int) public view returns (r int) { return -$a; }`)
+	cc := CallContext{}
+	assert.Equal(t, "r\n-9223372036854775808", run(in, cc, "mul", math.MinInt64, 1))
+	assert.Equal(t, "error: bigint out of range", run(in, cc, "mul", math.MinInt64, -1))
+	assert.Equal(t, "error: bigint out of range", run(in, cc, "neg", math.MinInt64))
+
+	for _, c := range []struct {
+		op   TokKind
+		l, r int64
+	}{
+		{T_PLUS, math.MaxInt64, 1},
+		{T_PLUS, math.MinInt64, -1},
+		{T_MINUS, math.MinInt64, 1},
+		{T_MINUS, 0, math.MinInt64},
+		{T_STAR, math.MaxInt64, 2},
+		{T_STAR, -1, math.MinInt64},
+		{T_DIV, math.MinInt64, -1},
+	} {
+		_, err := arith(c.op, c.l, c.r)
+		assert.EqualError(t, err, "bigint out of range", "%d %s %d", c.l, c.op, c.r)
+	}
+	v, err := arith(T_MOD, int64(math.MinInt64), int64(-1))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), v)
+	v, err = arith(T_MINUS, int64(-1), int64(math.MaxInt64))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(math.MinInt64), v)
+}
+
+func TestInterpreterState(t *testing.T) {
+	schema := `database d;
+table parents { id int primary, score decimal(5, 2), tags text[] }
+table children { id int primary, parent int, foreign_key (parent) references parents(id) }
+action add($id, $score, $tags text[]) public { INSERT INTO parents VALUES ($id, $score, $tags); }
+action adopt($id, $parent) public { INSERT INTO children VALUES ($id, $parent); }
+action scores() public view { SELECT id, score FROM parents ORDER BY score DESC; }`
+	in := interpreter(t, schema)
+	cc := CallContext{}
+	assert.Equal(t, "", run(in, cc, "add", 1, "1.5", []any{"a", "b"}))
+	assert.Equal(t, "", run(in, cc, "add", 2, nil, nil))
+	assert.Equal(t, "", run(in, cc, "adopt", 10, 1))
+	assert.Equal(t, "id score\n2 NULL\n1 1.5", run(in, cc, "scores"))
+
+	state, err := in.State()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][][]any{
+		"parents":  {{int64(1), json.Number("1.5"), []any{"a", "b"}}, {int64(2), nil, nil}},
+		"children": {{int64(10), int64(1)}},
+	}, state)
+
+	// foreign keys aren't checked, so children can come before their parents
+	other := interpreter(t, schema)
+	require.NoError(t, other.SetState(map[string][][]any{
+		"children": {{json.Number("10"), json.Number("1")}},
+		"parents":  {{json.Number("1"), json.Number("1.5"), []any{"a", "b"}}, {json.Number("2"), nil, nil}},
+	}))
+	assert.Equal(t, "id score\n2 NULL\n1 1.5", run(other, cc, "scores"))
+	assert.Equal(t, "error: duplicate key (id) in 'children'", run(other, cc, "adopt", 10, 2))
+	assert.EqualError(t, other.SetState(map[string][][]any{"nope": {}}), "unknown table 'nope'")
+}
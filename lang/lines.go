@@ -0,0 +1,86 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// Position is a zero based line and character, where characters are counted
+// in UTF-16 code units as the language server protocol does.
+type Position struct {
+	Line      int
+	Character int
+}
+
+type LineIndex struct {
+	text       string
+	lineStarts []int
+}
+
+func NewLineIndex(text string) *LineIndex {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &LineIndex{
+		text:       text,
+		lineStarts: starts,
+	}
+}
+
+func (li *LineIndex) Position(offset int) Position {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(li.text) {
+		offset = len(li.text)
+	}
+	line := sort.Search(len(li.lineStarts), func(i int) bool { return li.lineStarts[i] > offset }) - 1
+	char := 0
+	for _, r := range li.text[li.lineStarts[line]:offset] {
+		char += utf16Len(r)
+	}
+	return Position{Line: line, Character: char}
+}
+
+func (li *LineIndex) Offset(p Position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(li.lineStarts) {
+		return len(li.text)
+	}
+	offset := li.lineStarts[p.Line]
+	char := 0
+	for char < p.Character && offset < len(li.text) {
+		r, l := utf8.DecodeRuneInString(li.text[offset:])
+		if r == '\n' {
+			break
+		}
+		char += utf16Len(r)
+		offset += l
+	}
+	return offset
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
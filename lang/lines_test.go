@@ -0,0 +1,27 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineIndexPosition(t *testing.T) {
+	li := NewLineIndex("ab\n😀c")
+	assert.Equal(t, Position{Line: 0, Character: 0}, li.Position(-1))
+	assert.Equal(t, Position{Line: 1, Character: 0}, li.Position(3))
+	assert.Equal(t, Position{Line: 1, Character: 3}, li.Position(100))
+}
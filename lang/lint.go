@@ -0,0 +1,355 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// LintRule is a check for code which is valid but likely a mistake. The
+// diagnostics it reports are warnings with the rule name as the code.
+type LintRule struct {
+	Name        string
+	Description string
+	check       func(l *linter)
+}
+
+// LintRules is the catalog of all the rules. They are enabled by default.
+var LintRules = []LintRule{
+	{"unused-parameter", "action parameter which is never read", lintUnusedParams},
+	{"unused-variable", "variable which is assigned but never read", lintUnusedVars},
+	{"shadowed-variable", "assignment to a variable which shadows a parameter", lintShadowedVars},
+	{"unreachable-code", "statement after return", lintUnreachable},
+	{"no-primary-key", "table without a primary key", lintNoPrimaryKey},
+	{"duplicate-index", "index over the same columns as another index", lintDuplicateIndexes},
+	{"select-star", "SELECT * instead of an explicit column list", lintSelectStar},
+	{"unchecked-caller", "public action which modifies data without checking @caller", lintUncheckedCaller},
+	{"missing-view", "action which only reads data but isn't declared view", lintMissingView},
+}
+
+// LintConfig enables and disables lint rules by name.
+type LintConfig struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// LintConfigFile is the name of the file the lint configuration is read from.
+const LintConfigFile = "kflint.json"
+
+// ParseLintConfig parses a configuration like {"rules": {"select-star": false}}.
+func ParseLintConfig(data []byte) (LintConfig, error) {
+	cfg := LintConfig{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return LintConfig{}, err
+	}
+	for name := range cfg.Rules {
+		if !slices.ContainsFunc(LintRules, func(r LintRule) bool { return r.Name == name }) {
+			return LintConfig{}, fmt.Errorf("unknown lint rule %q", name)
+		}
+	}
+	return cfg, nil
+}
+
+func (c LintConfig) Enabled(rule string) bool {
+	on, ok := c.Rules[rule]
+	return !ok || on
+}
+
+type linter struct {
+	fr    *FileRoot
+	rule  string
+	diags []Diagnostic
+}
+
+// Lint runs the enabled rules on the file. Warnings on lines marked with a
+// "// kf:ignore rule-name" comment, or following a line consisting of one, are
+// left out. A kf:ignore comment without rule names suppresses all of them.
+func Lint(fr *FileRoot, cfg LintConfig) []Diagnostic {
+	l := linter{fr: fr}
+	for _, r := range LintRules {
+		if cfg.Enabled(r.Name) {
+			l.rule = r.Name
+			r.check(&l)
+		}
+	}
+
+	ignored := ignoredRules(fr)
+	li := NewLineIndex(fr.Text())
+	res := []Diagnostic{}
+	for _, d := range l.diags {
+		rules, ok := ignored[li.Position(d.Start).Line]
+		if ok && (len(rules) == 0 || slices.Contains(rules, d.Code)) {
+			continue
+		}
+		res = append(res, d)
+	}
+
+	sortDiagnostics(res)
+	return res
+}
+
+func (l *linter) report(n AstNode, format string, args ...any) {
+	l.reportFixes(n, nil, format, args...)
+}
+
+// reportFixes reports a warning with its quick fixes. They are passed in
+// rather than set afterwards, as a later report can move the diagnostic.
+func (l *linter) reportFixes(n AstNode, fixes []Fix, format string, args ...any) {
+	l.reportRange(n.Start(), n.End(), format, args...)
+	l.diags[len(l.diags)-1].Fixes = fixes
+}
+
+func (l *linter) reportRange(start int, end int, format string, args ...any) {
+	l.diags = append(l.diags, Diagnostic{
+		Start:    start,
+		End:      end,
+		Severity: SevWarning,
+		Code:     l.rule,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+var ignoreComment = regexp.MustCompile(`^//\s*kf:ignore\b(.*)$`)
+
+// ignoredRules maps lines to the rules ignored on them, an empty list meaning all rules.
+func ignoredRules(fr *FileRoot) map[int][]string {
+	li := NewLineIndex(fr.Text())
+	res := map[int][]string{}
+	Walk(fr, func(n AstNode) bool {
+		t, ok := n.(*TokNode)
+		if !ok || t.tok.kind != T_COMMENT {
+			return true
+		}
+		m := ignoreComment.FindStringSubmatch(t.Text())
+		if m == nil {
+			return false
+		}
+		rules := strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		pos := li.Position(t.Start())
+		line := pos.Line
+		lineText := fr.Text()[li.lineStarts[line]:t.Start()]
+		if strings.TrimSpace(lineText) == "" {
+			line++
+		}
+		res[line] = append(res[line], rules...)
+		if len(rules) == 0 {
+			res[line] = []string{}
+		}
+		return false
+	})
+	return res
+}
+
+// reportTarget reports the "$name" part of an assignment.
+func (l *linter) reportTarget(as *AssignStmt, format string, args ...any) {
+	end := as.End()
+	if id := findTok(as.Children(), T_ID); id != nil {
+		end = id.End()
+	}
+	l.reportRange(as.Start(), end, format, args...)
+}
+
+// readVars returns the lowercased names of the variables read in the action.
+func readVars(ad *ActionDecl) map[string]bool {
+	res := map[string]bool{}
+	Walk(ad, func(n AstNode) bool {
+		if ve, ok := n.(*VarExpr); ok {
+			res[strings.ToLower(ve.VarName())] = true
+		}
+		return true
+	})
+	return res
+}
+
+// assignStmts returns the assignments of the action including the nested ones.
+func assignStmts(ad *ActionDecl) []*AssignStmt {
+	res := []*AssignStmt{}
+	for _, st := range ad.AllStmts() {
+		if as, ok := st.(*AssignStmt); ok {
+			res = append(res, as)
+		}
+	}
+	return res
+}
+
+func lintUnusedParams(l *linter) {
+	for _, ad := range l.fr.ActionDecls() {
+		read := readVars(ad)
+		for _, pd := range ad.Params() {
+			if pd.Name() == "$" || read[strings.ToLower(pd.Name())] {
+				continue
+			}
+			l.reportFixes(pd, []Fix{removeParamFix(ad, pd)}, "parameter '%s' is never used", pd.Name())
+		}
+	}
+}
+
+func lintUnusedVars(l *linter) {
+	for _, ad := range l.fr.ActionDecls() {
+		read := readVars(ad)
+		params := map[string]bool{}
+		for _, pd := range ad.Params() {
+			params[strings.ToLower(pd.Name())] = true
+		}
+		seen := map[string]bool{}
+		for _, as := range assignStmts(ad) {
+			name := strings.ToLower(as.VarName())
+			if name == "$" || read[name] || params[name] || seen[name] {
+				continue
+			}
+			seen[name] = true
+			l.reportTarget(as, "variable '%s' is assigned but never used", as.VarName())
+		}
+	}
+}
+
+func lintShadowedVars(l *linter) {
+	for _, ad := range l.fr.ActionDecls() {
+		params := map[string]bool{}
+		for _, pd := range ad.Params() {
+			params[strings.ToLower(pd.Name())] = true
+		}
+		for _, as := range assignStmts(ad) {
+			if params[strings.ToLower(as.VarName())] {
+				l.reportTarget(as, "variable '%s' shadows a parameter", as.VarName())
+			}
+		}
+	}
+}
+
+func lintUnreachable(l *linter) {
+	for _, ad := range l.fr.ActionDecls() {
+		l.unreachable(ad.Stmts())
+		Walk(ad, func(n AstNode) bool {
+			if b, ok := n.(*Block); ok {
+				l.unreachable(b.Stmts())
+			}
+			return true
+		})
+	}
+}
+
+// unreachable reports the statements of a block following a return. A return
+// next only adds a row to the result, so the statements after it still run.
+func (l *linter) unreachable(stmts []Stmt) {
+	idx := slices.IndexFunc(stmts, func(st Stmt) bool {
+		rs, ok := st.(*ReturnStmt)
+		return ok && !rs.IsNext()
+	})
+	if idx < 0 || idx == len(stmts)-1 {
+		return
+	}
+	first, last := stmts[idx+1], stmts[len(stmts)-1]
+	l.reportRange(first.Start(), last.End(), "unreachable code")
+}
+
+func lintNoPrimaryKey(l *linter) {
+	for _, td := range l.fr.TableDecls() {
+		hasPk := slices.ContainsFunc(td.Columns(), func(cd *ColumnDecl) bool {
+			return cd.HasAttr("primary") || cd.HasAttr("pk")
+		}) || slices.ContainsFunc(td.Indexes(), func(id *IndexDecl) bool {
+			return id.Kind() == "primary"
+		})
+		if !hasPk {
+			l.report(nameNode(td), "table '%s' has no primary key", td.Name())
+		}
+	}
+}
+
+func lintDuplicateIndexes(l *linter) {
+	for _, td := range l.fr.TableDecls() {
+		seen := map[string]*IndexDecl{}
+		for _, id := range td.Indexes() {
+			key := strings.ToLower(strings.Join(id.Columns(), ","))
+			if prev, ok := seen[key]; ok {
+				l.report(id, "index '%s' has the same columns as index '%s'", id.Name(), prev.Name())
+				continue
+			}
+			seen[key] = id
+		}
+	}
+}
+
+func lintSelectStar(l *linter) {
+	Walk(l.fr, func(n AstNode) bool {
+		rc, ok := n.(*ResultColumn)
+		if ok && rc.IsStar() {
+			l.report(rc, "SELECT * depends on the table layout, list the columns explicitly")
+		}
+		return !ok
+	})
+}
+
+// modifiesData reports whether an action has INSERT, UPDATE or DELETE statements.
+func modifiesData(ad *ActionDecl) bool {
+	return slices.ContainsFunc(ad.AllStmts(), func(st Stmt) bool {
+		switch st.(type) {
+		case *InsertStmt, *UpdateStmt, *DeleteStmt:
+			return true
+		}
+		return false
+	})
+}
+
+func lintUncheckedCaller(l *linter) {
+	for _, ad := range l.fr.ActionDecls() {
+		if !ad.HasModifier(T_PUBLIC) || !modifiesData(ad) {
+			continue
+		}
+		checked := false
+		Walk(ad, func(n AstNode) bool {
+			if cv, ok := n.(*CtxVarExpr); ok && strings.EqualFold(cv.VarName(), "@caller") {
+				checked = true
+			}
+			return !checked
+		})
+		if !checked {
+			l.report(nameNode(ad), "public %s '%s' modifies data without checking @caller", ad.Kind(), ad.Name())
+		}
+	}
+}
+
+func lintMissingView(l *linter) {
+	for _, ad := range l.fr.ActionDecls() {
+		reads := slices.ContainsFunc(ad.AllStmts(), func(st Stmt) bool {
+			_, ok := st.(*SelectStmt)
+			return ok
+		})
+		if ad.HasModifier(T_VIEW) || !reads || modifiesData(ad) {
+			continue
+		}
+		var fixes []Fix
+		if f, ok := addViewFix(ad); ok {
+			fixes = append(fixes, f)
+		}
+		l.reportFixes(nameNode(ad), fixes, "%s '%s' only reads data and can be declared view", ad.Kind(), ad.Name())
+	}
+}
+
+func addViewFix(ad *ActionDecl) (Fix, bool) {
+	var last *TokNode
+	for _, n := range ad.Children() {
+		t, ok := n.(*TokNode)
+		if ok && (t.tok.kind == T_RPAREN || isActionModifier(t.tok.kind)) {
+			last = t
+		}
+	}
+	if last == nil {
+		return Fix{}, false
+	}
+	return replaceFix(fmt.Sprintf("Declare '%s' view", ad.Name()), last.End(), last.End(), " view"), true
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lint(text string) []Diagnostic {
+	return Lint(ParseFile(text), LintConfig{})
+}
+
+func TestLintCleanFile(t *testing.T) {
+	ds := lint(`database d;
+table users {
+	id int primary,
+	name text,
+	#name_idx index(name)
+}
+action get($id) public view {
+	SELECT name FROM users WHERE id = $id;
+}
+action rename($id, $name) public {
+	$n = $name;
+	UPDATE users SET name = $n WHERE id = $id AND @caller = 'admin';
+}`)
+	assert.Empty(t, ds)
+}
+
+func TestLintVariables(t *testing.T) {
+	text := `database d;
+action a($x, $y) private view {
+	$z = 1;
+	$x = 2;
+	return $x;
+	SELECT 1;
+	SELECT 2;
+}`
+	ds := lint(text)
+	assert.Equal(t, []string{"unused-parameter", "unused-variable", "shadowed-variable", "unreachable-code"}, diagCodes(ds))
+	assert.Equal(t, "parameter '$y' is never used", ds[0].Message)
+	assert.Equal(t, "variable '$z' is assigned but never used", ds[1].Message)
+	assert.Equal(t, "$z", text[ds[1].Start:ds[1].End])
+	assert.Equal(t, "variable '$x' shadows a parameter", ds[2].Message)
+	assert.Equal(t, SevWarning, ds[3].Severity)
+	assert.Equal(t, "SELECT 1;\n\tSELECT 2", text[ds[3].Start:ds[3].End])
+}
+
+func TestLintTables(t *testing.T) {
+	ds := lint(`database d;
+table t {
+	a int,
+	b int,
+	#i1 index(a, b),
+	#i2 unique(A, B)
+}`)
+	assert.Equal(t, []string{"no-primary-key", "duplicate-index"}, diagCodes(ds))
+	assert.Equal(t, "table 't' has no primary key", ds[0].Message)
+	assert.Equal(t, "index 'i2' has the same columns as index 'i1'", ds[1].Message)
+}
+
+func TestLintProcedures(t *testing.T) {
+	text := `database d;
+table t { id int primary }
+procedure p($x int) public returns table(id int) {
+	for $r in SELECT id FROM t {
+		return next $r.id;
+		$y = 1;
+	}
+	if $x > 0 {
+		$z = 1;
+		DELETE FROM t;
+		return;
+		SELECT 1;
+	}
+}`
+	ds := lint(text)
+	assert.Equal(t, []string{"unchecked-caller", "unused-variable", "unused-variable", "unreachable-code"}, diagCodes(ds))
+	assert.Equal(t, "public procedure 'p' modifies data without checking @caller", ds[0].Message)
+	assert.Equal(t, "SELECT 1", text[ds[3].Start:ds[3].End])
+}
+
+func TestLintActions(t *testing.T) {
+	text := `database d;
+table t { id int primary }
+action get() public { SELECT * FROM t; }
+action del() public { DELETE FROM t; }
+action del_own() public { DELETE FROM t WHERE id = @caller; }
+action del_private() private { DELETE FROM t; }`
+	ds := lint(text)
+	assert.Equal(t, []string{"missing-view", "select-star", "unchecked-caller"}, diagCodes(ds))
+	assert.Equal(t, "action 'get' only reads data and can be declared view", ds[0].Message)
+	assert.Equal(t, "public action 'del' modifies data without checking @caller", ds[2].Message)
+	assert.Equal(t, "action get() public view { SELECT * FROM t; }", strings.Split(applyFix(text, ds[0].Fixes[0]), "\n")[2])
+}
+
+func TestLintConfigAndIgnores(t *testing.T) {
+	text := `database d;
+table a { x int } // kf:ignore no-primary-key
+// kf:ignore
+table b { x int }
+// kf:ignore select-star
+table c { x int }
+table e { x int }`
+	ds := lint(text)
+	assert.Len(t, ds, 2)
+	assert.Equal(t, "table 'c' has no primary key", ds[0].Message)
+	assert.Equal(t, "table 'e' has no primary key", ds[1].Message)
+
+	cfg, err := ParseLintConfig([]byte(`{"rules": {"no-primary-key": false}}`))
+	assert.NoError(t, err)
+	assert.False(t, cfg.Enabled("no-primary-key"))
+	assert.True(t, cfg.Enabled("select-star"))
+	assert.Empty(t, Lint(ParseFile(text), cfg))
+
+	_, err = ParseLintConfig([]byte(`{"rules": {"no-such-rule": false}}`))
+	assert.EqualError(t, err, `unknown lint rule "no-such-rule"`)
+}
@@ -0,0 +1,652 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Values of the interpreter are nil, int64 for ints, *big.Rat for decimals
+// and uint256s, string for texts and uuids, bool, []byte for blobs and []any
+// for arrays.
+
+func valueType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case int64:
+		return "int"
+	case *big.Rat:
+		return "decimal"
+	case string:
+		return "text"
+	case bool:
+		return "bool"
+	case []byte:
+		return "blob"
+	case []any:
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// coerce converts a value to a type, failing if the type can't hold it. Besides
+// the values of the interpreter, it takes the ones JSON decodes to.
+func coerce(v any, tr *TypeRef) (any, error) {
+	if v == nil || tr == nil {
+		return v, nil
+	}
+	if tr.IsArray() {
+		vs, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected %s, got %s", typeText(tr), valueType(v))
+		}
+		res := []any{}
+		for _, e := range vs {
+			c, err := coerceBase(e, tr)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, c)
+		}
+		return res, nil
+	}
+	return coerceBase(v, tr)
+}
+
+func coerceBase(v any, tr *TypeRef) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if n, ok := v.(json.Number); ok {
+		v = n.String()
+		if i, err := n.Int64(); err == nil {
+			v = i
+		}
+	}
+	if f, ok := v.(float64); ok {
+		v = new(big.Rat).SetFloat64(f)
+	}
+	fail := func() (any, error) {
+		return nil, fmt.Errorf("expected %s, got %s", strings.TrimSuffix(typeText(tr), "[]"), valueType(v))
+	}
+
+	switch baseTypeName(tr) {
+	case "int":
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case *big.Rat:
+			if v.IsInt() && v.Num().IsInt64() {
+				return v.Num().Int64(), nil
+			}
+		}
+	case "decimal", "uint256":
+		r, ok := toRat(v)
+		if s, isText := v.(string); isText {
+			r, ok = new(big.Rat).SetString(s)
+		}
+		if !ok {
+			return fail()
+		}
+		if baseTypeName(tr) == "uint256" {
+			if !r.IsInt() || r.Sign() < 0 || r.Num().BitLen() > 256 {
+				return nil, fmt.Errorf("%s is out of range of uint256", FormatValue(r))
+			}
+			return r, nil
+		}
+		if args := tr.Args(); len(args) == 2 {
+			scale, _ := strconv.Atoi(args[1])
+			r = roundRat(r, scale)
+		}
+		return r, nil
+	case "text":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "uuid":
+		if s, ok := v.(string); ok {
+			if !uuidPattern.MatchString(s) {
+				return nil, fmt.Errorf("invalid uuid '%s'", s)
+			}
+			return strings.ToLower(s), nil
+		}
+	case "bool":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case "blob":
+		switch v := v.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			// JSON has blobs in base64
+			if b, err := base64.StdEncoding.DecodeString(v); err == nil {
+				return b, nil
+			}
+		}
+	default:
+		return v, nil
+	}
+	return fail()
+}
+
+func toRat(v any) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case *big.Rat:
+		return v, true
+	}
+	return nil, false
+}
+
+// roundRat rounds half away from zero to a number of decimal places.
+func roundRat(r *big.Rat, scale int) *big.Rat {
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(unit))
+	half := big.NewRat(1, 2)
+	if scaled.Sign() < 0 {
+		half.Neg(half)
+	}
+	scaled.Add(scaled, half)
+	n := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+	return new(big.Rat).SetFrac(n, unit)
+}
+
+// compareValues orders two values of the same type, and numbers of any type.
+func compareValues(a any, b any) (int, error) {
+	if ai, ok := a.(int64); ok {
+		if bi, ok := b.(int64); ok {
+			return cmpInt(ai, bi), nil
+		}
+	}
+	if ar, ok := toRat(a); ok {
+		if br, ok := toRat(b); ok {
+			return ar.Cmp(br), nil
+		}
+	}
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), nil
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			return cmpInt(boolInt(a), boolInt(b)), nil
+		}
+	case []byte:
+		if b, ok := b.([]byte); ok {
+			return bytes.Compare(a, b), nil
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			for i := 0; i < len(a) && i < len(b); i++ {
+				if a[i] == nil || b[i] == nil {
+					return cmpInt(boolInt(a[i] == nil), boolInt(b[i] == nil)), nil
+				}
+				if c, err := compareValues(a[i], b[i]); err != nil || c != 0 {
+					return c, err
+				}
+			}
+			return cmpInt(int64(len(a)), int64(len(b))), nil
+		}
+	}
+	return 0, fmt.Errorf("can't compare %s with %s", valueType(a), valueType(b))
+}
+
+func cmpInt(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// FormatValue returns the text of a value as PostgreSQL prints it.
+func FormatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case *big.Rat:
+		if v.IsInt() {
+			return v.Num().String()
+		}
+		// the shortest exact decimal, if there is one as long as a result of
+		// dividing decimals in PostgreSQL
+		for places := 1; places <= 20; places++ {
+			if roundRat(v, places).Cmp(v) == 0 {
+				return v.FloatString(places)
+			}
+		}
+		return v.FloatString(20)
+	case []byte:
+		return `\x` + hex.EncodeToString(v)
+	case []any:
+		items := []string{}
+		for _, e := range v {
+			items = append(items, FormatValue(e))
+		}
+		return "{" + strings.Join(items, ",") + "}"
+	}
+	return fmt.Sprint(v)
+}
+
+// jsonValue converts a value to one encoding/json writes in a way coerce
+// reads back.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case *big.Rat:
+		return json.Number(FormatValue(v))
+	case []any:
+		res := []any{}
+		for _, e := range v {
+			res = append(res, jsonValue(e))
+		}
+		return res
+	}
+	return v
+}
+
+// memDB is an in-memory SQLite database with the tables of a schema. Columns
+// are declared with their PostgreSQL types, which SQLite maps to the nearest
+// of its own, and the constraints of the tables become SQLite ones.
+type memDB struct {
+	db *sql.DB
+	// checks has the messages of the CHECK constraints by name, as SQLite
+	// only reports the name of the one violated.
+	checks map[string]string
+}
+
+var registerFunctions sync.Once
+
+// openMemDB creates a database with the tables of a file.
+func openMemDB(fr *FileRoot) (*memDB, error) {
+	registerFunctions.Do(func() {
+		// error() of Kuneiform, which fails the statement with the message
+		sqlite.MustRegisterScalarFunction("kf_error", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			return nil, errors.New(FormatValue(args[0]))
+		})
+	})
+	db, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	// every connection has a database of its own, which closing it drops
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+
+	m := &memDB{db: db, checks: map[string]string{}}
+	for _, td := range fr.TableDecls() {
+		for _, st := range m.createTable(td) {
+			if _, err := db.Exec(st); err != nil {
+				db.Close()
+				return nil, runError(td, "can't create table '%s' in SQLite: %s", td.Name(), m.message(err))
+			}
+		}
+	}
+	return m, nil
+}
+
+// sqliteIdent returns a Kuneiform name as a quoted SQLite identifier.
+func sqliteIdent(name string) string {
+	return `"` + strings.ReplaceAll(strings.ToLower(name), `"`, `""`) + `"`
+}
+
+func sqliteIdents(names []string) string {
+	res := []string{}
+	for _, n := range names {
+		res = append(res, sqliteIdent(n))
+	}
+	return strings.Join(res, ", ")
+}
+
+// createTable returns the statements creating a table and its indexes.
+func (m *memDB) createTable(td *TableDecl) []string {
+	name := sqliteIdent(td.Name())
+	pk := []string{}
+	for _, cd := range td.Columns() {
+		if cd.HasAttr("primary") || cd.HasAttr("pk") {
+			pk = append(pk, cd.Name())
+		}
+	}
+	for _, id := range td.Indexes() {
+		if id.Kind() == "primary" {
+			pk = id.Columns()
+		}
+	}
+
+	defs, checks := []string{}, []string{}
+	for _, cd := range td.Columns() {
+		col := sqliteIdent(cd.Name())
+		def := col + " " + pgTypeName(cd.Type())
+		// SQLite lets primary keys other than integer ones be null
+		if cd.HasAttr("notnull") || slices.ContainsFunc(pk, func(n string) bool { return strings.EqualFold(n, cd.Name()) }) {
+			def += " NOT NULL"
+		}
+		if cd.HasAttr("unique") {
+			def += " UNIQUE"
+		}
+		if cd.Type() != nil {
+			checks = append(checks, m.check(fmt.Sprintf("%s IS NULL OR %s", col, typeCheck(col, cd.Type())),
+				"column '%s' of '%s': expected %s", cd.Name(), td.Name(), typeText(cd.Type())))
+		}
+		for _, a := range cd.Attrs() {
+			if a.Arg() == nil {
+				continue
+			}
+			arg := strings.TrimSpace((*a.Arg()).Text())
+			cond := ""
+			switch a.Name() {
+			case "default":
+				def += " DEFAULT (" + arg + ")"
+				continue
+			case "min":
+				cond = col + " >= " + arg
+			case "max":
+				cond = col + " <= " + arg
+			case "minlen":
+				cond = "length(" + col + ") >= " + arg
+			case "maxlen":
+				cond = "length(" + col + ") <= " + arg
+			default:
+				continue
+			}
+			checks = append(checks, m.check(cond, "value of column '%s' of '%s' violates %s(%s)", cd.Name(), td.Name(), a.Name(), arg))
+		}
+		defs = append(defs, def)
+	}
+	if len(pk) > 0 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", sqliteIdents(pk)))
+	}
+	for _, fk := range td.ForeignKeys() {
+		ref := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", sqliteIdents(fk.Columns()), sqliteIdent(fk.RefTable()), sqliteIdents(fk.RefColumns()))
+		for _, a := range fk.Actions() {
+			if event, action := fkEvents[a.Event()], fkActions[a.Action()]; event != "" && action != "" {
+				ref += " " + event + " " + action
+			}
+		}
+		defs = append(defs, ref)
+	}
+	defs = append(defs, checks...)
+
+	res := []string{fmt.Sprintf("CREATE TABLE %s (\n    %s\n)", name, strings.Join(defs, ",\n    "))}
+	for _, id := range td.Indexes() {
+		// index names only have to be unique in their table in Kuneiform
+		idx := sqliteIdent(td.Name() + "." + id.Name())
+		switch id.Kind() {
+		case "unique":
+			res = append(res, fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)", idx, name, sqliteIdents(id.Columns())))
+		case "index":
+			res = append(res, fmt.Sprintf("CREATE INDEX %s ON %s (%s)", idx, name, sqliteIdents(id.Columns())))
+		}
+	}
+	return res
+}
+
+// check returns a named CHECK constraint, keeping the message to report when
+// it's violated.
+func (m *memDB) check(cond string, format string, args ...any) string {
+	name := fmt.Sprintf("check%d", len(m.checks)+1)
+	m.checks[name] = fmt.Sprintf(format, args...)
+	return fmt.Sprintf("CONSTRAINT %s CHECK (%s)", name, cond)
+}
+
+// typeCheck returns the condition that a column has values of its type, as
+// SQLite stores any value in any column.
+func typeCheck(col string, tr *TypeRef) string {
+	if tr.IsArray() {
+		// arrays are kept as JSON
+		return fmt.Sprintf("typeof(%s) = 'text'", col)
+	}
+	switch baseTypeName(tr) {
+	case "int":
+		return fmt.Sprintf("typeof(%s) = 'integer'", col)
+	case "bool":
+		return fmt.Sprintf("typeof(%s) = 'integer' AND %s IN (0, 1)", col, col)
+	case "blob":
+		return fmt.Sprintf("typeof(%s) = 'blob'", col)
+	case "decimal":
+		return fmt.Sprintf("typeof(%s) IN ('integer', 'real')", col)
+	case "uint256":
+		return fmt.Sprintf("typeof(%s) IN ('integer', 'real') AND %s >= 0", col, col)
+	}
+	return fmt.Sprintf("typeof(%s) = 'text'", col)
+}
+
+// sqliteFunction returns the SQLite function standing in for a function of
+// Kuneiform.
+func sqliteFunction(name string) string {
+	switch name = strings.ToLower(name); name {
+	case "error":
+		return "kf_error"
+	case "array_length":
+		return "json_array_length"
+	}
+	return name
+}
+
+// toSQLite converts a value of the interpreter to one SQLite stores. Bools
+// become 0 and 1, decimals which aren't ints floats, and arrays JSON.
+func toSQLite(v any) (any, error) {
+	switch v := v.(type) {
+	case bool:
+		return boolInt(v), nil
+	case *big.Rat:
+		if v.IsInt() && v.Num().IsInt64() {
+			return v.Num().Int64(), nil
+		}
+		f, _ := v.Float64()
+		return f, nil
+	case []any:
+		b, err := json.Marshal(jsonValue(v))
+		return string(b), err
+	case map[string]any:
+		return nil, fmt.Errorf("a record can't be used as a value")
+	}
+	return v, nil
+}
+
+// fromSQLite converts a value SQLite returns to a value of the interpreter
+// with the PostgreSQL type typ, or with the nearest type to SQLite's if typ is
+// empty.
+func fromSQLite(v any, typ string) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if base, ok := strings.CutSuffix(typ, "[]"); ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected an array, got %s", valueType(v))
+		}
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.UseNumber()
+		vs := []any{}
+		if err := dec.Decode(&vs); err != nil {
+			return nil, fmt.Errorf("invalid array %s", s)
+		}
+		for i, e := range vs {
+			if n, ok := e.(json.Number); ok {
+				e = n.String()
+				if i, err := n.Int64(); err == nil {
+					e = i
+				}
+			}
+			if s, ok := e.(string); ok && base == "BYTEA" {
+				// JSON has blobs in base64
+				if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+					e = b
+				}
+			}
+			var err error
+			if vs[i], err = fromSQLite(e, base); err != nil {
+				return nil, err
+			}
+		}
+		return vs, nil
+	}
+
+	switch {
+	case typ == "INT8":
+		if _, ok := v.(float64); ok {
+			// SQLite turns ints into floats when they overflow
+			return nil, errBigintRange
+		}
+	case typ == "BOOLEAN":
+		if i, ok := v.(int64); ok {
+			return i != 0, nil
+		}
+	case strings.HasPrefix(typ, "NUMERIC"):
+		r, ok := toRat(v)
+		switch v := v.(type) {
+		case float64:
+			r, ok = new(big.Rat).SetString(strconv.FormatFloat(v, 'g', -1, 64))
+		case string:
+			r, ok = new(big.Rat).SetString(v)
+		}
+		if !ok {
+			return nil, fmt.Errorf("numeric value %v out of range", v)
+		}
+		precision, scale := 0, 0
+		if n, _ := fmt.Sscanf(typ, "NUMERIC(%d, %d)", &precision, &scale); n == 2 {
+			r = roundRat(r, scale)
+		}
+		return r, nil
+	case typ == "BYTEA":
+		if s, ok := v.(string); ok {
+			return []byte(s), nil
+		}
+	case typ == "TEXT" || typ == "UUID":
+		if b, ok := v.([]byte); ok {
+			return string(b), nil
+		}
+	}
+	if f, ok := v.(float64); ok {
+		if r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64)); ok {
+			return r, nil
+		}
+		return nil, fmt.Errorf("numeric value %v out of range", f)
+	}
+	return v, nil
+}
+
+// pgValueType returns the PostgreSQL type of a value of the interpreter, or
+// an empty string for a record.
+func pgValueType(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return "INT8"
+	case bool:
+		return "BOOLEAN"
+	case *big.Rat:
+		return "NUMERIC"
+	case string:
+		return "TEXT"
+	case []byte:
+		return "BYTEA"
+	case []any:
+		for _, e := range v {
+			if e != nil {
+				return pgValueType(e) + "[]"
+			}
+		}
+		return "TEXT[]"
+	}
+	return ""
+}
+
+// sqliteMessage returns the message of an error of SQLite without its class
+// and code, e.g. "UNIQUE constraint failed: users.id" rather than
+// "constraint failed: UNIQUE constraint failed: users.id (2067)".
+func sqliteMessage(err error) string {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return err.Error()
+	}
+	msg := strings.TrimSuffix(se.Error(), fmt.Sprintf(" (%d)", se.Code()))
+	if _, rest, ok := strings.Cut(msg, ": "); ok {
+		return rest
+	}
+	return msg
+}
+
+// message is sqliteMessage with the violated CHECK constraints described.
+func (m *memDB) message(err error) string {
+	msg := sqliteMessage(err)
+	var se *sqlite.Error
+	if errors.As(err, &se) && se.Code() == sqlite3.SQLITE_CONSTRAINT_CHECK {
+		_, name, _ := strings.Cut(msg, ": ")
+		if check, ok := m.checks[name]; ok {
+			return check
+		}
+	}
+	return msg
+}
+
+// scanRows reads rows converting the values of each column from SQLite to the
+// PostgreSQL type of the column.
+func scanRows(rows *sql.Rows, types []string) ([][]any, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) != len(types) {
+		return nil, fmt.Errorf("expected %d column(s), SQLite returned %d", len(types), len(cols))
+	}
+	res := [][]any{}
+	for rows.Next() {
+		values := make([]any, len(cols))
+		ptrs := []any{}
+		for i := range values {
+			ptrs = append(ptrs, &values[i])
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if values[i], err = fromSQLite(v, types[i]); err != nil {
+				return nil, fmt.Errorf("column '%s': %v", cols[i], err)
+			}
+		}
+		res = append(res, values)
+	}
+	return res, rows.Err()
+}
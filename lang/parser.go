@@ -16,32 +16,36 @@ package lang
 func ParseFile(text string) *FileRoot {
 	ctx := newParseCtx(text)
 	parseFile(&ctx)
-	return ctx.build().(*FileRoot)
+	fr := ctx.build().(*FileRoot)
+	fr.errors = ctx.errors
+	return fr
 }
 
 func parseFile(ctx *parseContext) {
-	parseDbDirective(ctx)
+	if !parseDbDirective(ctx) {
+		ctx.expected("'database' directive")
+	}
 
 	for parseExtDirective(ctx) {
 	}
 
-	for parseDecl(ctx) {
+	for ctx.tokKind() != T_NONE {
+		if !parseDecl(ctx) {
+			ctx.errorNode("expected table, action or procedure declaration", T_TABLE, T_ACTION, T_PROCEDURE, T_USE)
+		}
 	}
 }
 
-func parseDbDirective(ctx *parseContext) {
+func parseDbDirective(ctx *parseContext) bool {
 	if ctx.tokKind() != T_DATABASE {
-		return
+		return false
 	}
 	m := ctx.mark()
 	ctx.advance()
-	if ctx.tokKind() == T_ID {
-		ctx.advance()
-	}
-	if ctx.tokKind() == T_SEMICOLON {
-		ctx.advance()
-	}
+	ctx.expectId("database name")
+	ctx.expect(T_SEMICOLON)
 	m.done(func(ns []AstNode) AstNode { return NewDbDirective(ns) })
+	return true
 }
 
 func parseExtDirective(ctx *parseContext) bool {
@@ -50,23 +54,54 @@ func parseExtDirective(ctx *parseContext) bool {
 	}
 	m := ctx.mark()
 	ctx.advance()
-	if ctx.tokKind() == T_ID {
+	ctx.expectId("extension name")
+
+	if ctx.tokKind() == T_LBRACE {
 		ctx.advance()
+		for parseExtParam(ctx) {
+			if ctx.tokKind() != T_COMMA {
+				break
+			}
+			ctx.advance()
+		}
+		ctx.expect(T_RBRACE)
 	}
-	if ctx.tokKind() == T_SEMICOLON {
+
+	if ctx.tokKind() == T_AS {
 		ctx.advance()
+		ctx.expectId("extension alias")
 	}
+
+	ctx.expect(T_SEMICOLON)
 	m.done(func(ns []AstNode) AstNode { return NewExtDirective(ns) })
 	return true
 }
 
+func parseExtParam(ctx *parseContext) bool {
+	if !ctx.atName() || ctx.peek() != T_COLON {
+		return false
+	}
+	m := ctx.mark()
+	ctx.advanceName()
+	ctx.expect(T_COLON)
+	if !parseExpr(ctx) {
+		ctx.expected("expression")
+	}
+	m.done(func(ns []AstNode) AstNode { return NewExtParam(ns) })
+	return true
+}
+
+func isDeclStart(k TokKind) bool {
+	return k == T_TABLE || k == T_ACTION || k == T_PROCEDURE || k == T_AT
+}
+
 func parseDecl(ctx *parseContext) bool {
 	if ctx.tokKind() == T_TABLE {
 		parseTable(ctx)
 		return true
 	}
 
-	if ctx.tokKind() == T_ACTION {
+	if ctx.tokKind() == T_ACTION || ctx.at(T_PROCEDURE) || ctx.tokKind() == T_AT {
 		parseAction(ctx)
 		return true
 	}
@@ -83,68 +118,357 @@ func parseTable(ctx *parseContext) bool {
 	m := ctx.mark()
 	m.ctx.advance()
 
-	if ctx.tokKind() == T_ID {
+	ctx.expectId("table name")
+	ctx.expect(T_LBRACE)
+
+	for ctx.tokKind() != T_RBRACE && ctx.tokKind() != T_NONE && !ctx.atDeclStart() {
+		if !parseTableItem(ctx) {
+			ctx.errorNode("expected column, index or foreign key")
+			continue
+		}
+		if ctx.tokKind() == T_COMMA {
+			ctx.advance()
+		} else if ctx.tokKind() != T_RBRACE {
+			ctx.expected("','")
+		}
+	}
+
+	ctx.expect(T_RBRACE)
+
+	if ctx.tokKind() == T_SEMICOLON {
 		ctx.advance()
 	}
 
-	if ctx.tokKind() == T_LBRACE {
+	m.done(func(ns []AstNode) AstNode { return NewTableDecl(ns) })
+
+	return true
+}
+
+func parseTableItem(ctx *parseContext) bool {
+	if ctx.tokKind() == T_HASH {
+		return parseIndexDecl(ctx)
+	}
+	if ctx.atId("foreign_key") || ctx.atId("fk") {
+		return parseForeignKeyDecl(ctx)
+	}
+	return parseColumnDecl(ctx)
+}
+
+func parseColumnDecl(ctx *parseContext) bool {
+	if !ctx.atName() {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advanceName()
+
+	if !parseTypeRef(ctx) {
+		ctx.expected("column type")
+	}
+
+	for parseColumnAttr(ctx) {
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewColumnDecl(ns) })
+
+	return true
+}
+
+func parseTypeRef(ctx *parseContext) bool {
+	if ctx.tokKind() != T_ID {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	if ctx.tokKind() == T_LPAREN {
 		ctx.advance()
+		ctx.expect(T_NUM)
+		if ctx.tokKind() == T_COMMA {
+			ctx.advance()
+			ctx.expect(T_NUM)
+		}
+		ctx.expect(T_RPAREN)
 	}
 
-	if ctx.tokKind() == T_RBRACE {
+	if ctx.tokKind() == T_LBRACKET {
 		ctx.advance()
+		ctx.expect(T_RBRACKET)
 	}
 
-	m.done(func(ns []AstNode) AstNode { return NewTableDecl(ns) })
+	m.done(func(ns []AstNode) AstNode { return NewTypeRef(ns) })
 
 	return true
 }
 
-func parseAction(ctx *parseContext) bool {
-	if ctx.tokKind() != T_ACTION {
+func parseColumnAttr(ctx *parseContext) bool {
+	if ctx.tokKind() != T_ID || ctx.atDeclStart() {
 		return false
 	}
 
 	m := ctx.mark()
-	m.ctx.advance()
+	primary := ctx.atId("primary")
+	ctx.advance()
 
-	if ctx.tokKind() == T_ID {
+	if primary && ctx.atId("key") {
 		ctx.advance()
 	}
 
 	if ctx.tokKind() == T_LPAREN {
 		ctx.advance()
+		if !parseExpr(ctx) {
+			ctx.expected("expression")
+		}
+		ctx.expect(T_RPAREN)
 	}
 
-	for parseParam(ctx) {
-		if ctx.tokKind() == T_COMMA {
-			ctx.advance()
+	m.done(func(ns []AstNode) AstNode { return NewColumnAttr(ns) })
+
+	return true
+}
+
+func parseIndexDecl(ctx *parseContext) bool {
+	if ctx.tokKind() != T_HASH {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	ctx.expectId("index name")
+	ctx.expectId("index kind")
+	if !parseColumnList(ctx) {
+		ctx.expected("column list")
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewIndexDecl(ns) })
+
+	return true
+}
+
+func parseForeignKeyDecl(ctx *parseContext) bool {
+	if !ctx.atId("foreign_key") && !ctx.atId("fk") {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	if !parseColumnList(ctx) {
+		ctx.expected("column list")
+	}
+
+	if ctx.tokKind() == T_REFERENCES || ctx.atId("ref") {
+		ctx.advance()
+	} else {
+		ctx.expected("'references'")
+	}
+
+	ctx.expectId("table name")
+	if !parseColumnList(ctx) {
+		ctx.expected("column list")
+	}
+
+	for parseFkAction(ctx) {
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewForeignKeyDecl(ns) })
+
+	return true
+}
+
+func parseFkAction(ctx *parseContext) bool {
+	if !ctx.atId("on_delete") && !ctx.atId("on_update") {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	ctx.expectId("foreign key action")
+
+	m.done(func(ns []AstNode) AstNode { return NewFkAction(ns) })
+
+	return true
+}
+
+func parseColumnList(ctx *parseContext) bool {
+	if ctx.tokKind() != T_LPAREN {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	for ctx.atName() {
+		ctx.advanceName()
+		if ctx.tokKind() != T_COMMA {
+			break
 		}
+		ctx.advance()
 	}
 
-	if ctx.tokKind() == T_RPAREN {
+	ctx.expect(T_RPAREN)
+
+	m.done(func(ns []AstNode) AstNode { return NewColumnList(ns) })
+
+	return true
+}
+
+func isActionModifier(k TokKind) bool {
+	return k == T_PUBLIC || k == T_PRIVATE || k == T_VIEW || k == T_OWNER
+}
+
+// parseAction parses both actions and procedures, which differ only in the
+// keyword and the optional returns clause, with the annotations preceding them.
+func parseAction(ctx *parseContext) bool {
+	if ctx.tokKind() != T_ACTION && !ctx.at(T_PROCEDURE) && ctx.tokKind() != T_AT {
+		return false
+	}
+
+	m := ctx.mark()
+	for parseAnnotation(ctx) {
+	}
+	if ctx.tokKind() != T_ACTION && !ctx.at(T_PROCEDURE) {
+		ctx.expected("action or procedure declaration")
+		m.done(func(ns []AstNode) AstNode { return NewActionDecl(ns) })
+		return true
+	}
+
+	what := "action name"
+	if ctx.at(T_PROCEDURE) {
+		what = "procedure name"
+		ctx.advanceAs(T_PROCEDURE)
+	} else {
 		ctx.advance()
 	}
 
-	if ctx.tokKind() == T_LBRACE {
+	ctx.expectId(what)
+	ctx.expect(T_LPAREN)
+
+	for parseParam(ctx) {
+		if ctx.tokKind() != T_COMMA {
+			break
+		}
 		ctx.advance()
 	}
 
-	for parseStmt(ctx) {
-		if ctx.tokKind() == T_SEMICOLON {
+	ctx.expect(T_RPAREN)
+
+	for isActionModifier(ctx.tokKind()) {
+		ctx.advance()
+	}
+
+	parseReturnsClause(ctx)
+
+	parseBlock(ctx)
+
+	m.done(func(ns []AstNode) AstNode { return NewActionDecl(ns) })
+
+	return true
+}
+
+func parseAnnotation(ctx *parseContext) bool {
+	if ctx.tokKind() != T_AT {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+	ctx.expectId("annotation name")
+
+	if ctx.expect(T_LPAREN) {
+		for ctx.atName() {
+			ctx.advanceName()
+			ctx.expect(T_ASSIGN)
+			if !parseExpr(ctx) {
+				ctx.expected("expression")
+			}
+			if ctx.tokKind() != T_COMMA {
+				break
+			}
 			ctx.advance()
 		}
+		ctx.expect(T_RPAREN)
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewAnnotation(ns) })
+
+	return true
+}
+
+func parseReturnsClause(ctx *parseContext) bool {
+	if !ctx.at(T_RETURNS) {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advanceAs(T_RETURNS)
+
+	if ctx.tokKind() == T_TABLE {
+		ctx.advance()
 	}
 
-	if ctx.tokKind() == T_RBRACE {
+	ctx.expect(T_LPAREN)
+	for parseReturnColumn(ctx) {
+		if ctx.tokKind() != T_COMMA {
+			break
+		}
 		ctx.advance()
 	}
+	ctx.expect(T_RPAREN)
 
-	m.done(func(ns []AstNode) AstNode { return NewActionDecl(ns) })
+	m.done(func(ns []AstNode) AstNode { return NewReturnsClause(ns) })
+
+	return true
+}
+
+// parseReturnColumn parses either a bare type or a column name followed by a type.
+func parseReturnColumn(ctx *parseContext) bool {
+	if !ctx.atName() {
+		return false
+	}
+
+	m := ctx.mark()
+	if ctx.peek() == T_ID {
+		ctx.advanceName()
+	}
+	parseTypeRef(ctx)
+
+	m.done(func(ns []AstNode) AstNode { return NewReturnColumn(ns) })
 
 	return true
 }
 
+func parseBlock(ctx *parseContext) {
+	ctx.expect(T_LBRACE)
+
+	for ctx.tokKind() != T_RBRACE && ctx.tokKind() != T_NONE && !ctx.atDeclStart() {
+		if ctx.tokKind() == T_SEMICOLON {
+			ctx.advance()
+			continue
+		}
+		compound := ctx.at(T_IF) || ctx.at(T_FOR)
+		if !parseStmt(ctx) {
+			ctx.errorNode("expected statement", T_SELECT, T_INSERT, T_UPDATE, T_DELETE, T_RETURN, T_IF, T_FOR)
+			continue
+		}
+		if ctx.tokKind() != T_RBRACE && !compound {
+			ctx.expect(T_SEMICOLON)
+		}
+	}
+
+	ctx.expect(T_RBRACE)
+}
+
+// parseNestedBlock parses the body of an if or for statement into a Block.
+func parseNestedBlock(ctx *parseContext) {
+	m := ctx.mark()
+	parseBlock(ctx)
+	m.done(func(ns []AstNode) AstNode { return NewBlock(ns) })
+}
+
 func parseParam(ctx *parseContext) bool {
 	if ctx.tokKind() != T_DOLLAR {
 		return false
@@ -153,9 +477,8 @@ func parseParam(ctx *parseContext) bool {
 	m := ctx.mark()
 	ctx.advance()
 
-	if ctx.tokKind() == T_ID {
-		ctx.advance()
-	}
+	ctx.expectId("parameter name")
+	parseTypeRef(ctx)
 
 	m.done(func(ns []AstNode) AstNode { return NewParamDecl(ns) })
 
@@ -163,13 +486,107 @@ func parseParam(ctx *parseContext) bool {
 }
 
 func parseStmt(ctx *parseContext) bool {
-	if ctx.tokKind() == T_DOLLAR {
+	switch ctx.tokKind() {
+	case T_DOLLAR:
 		return parseAssignStmt(ctx)
+	case T_SELECT:
+		return parseSelectStmt(ctx)
+	case T_INSERT:
+		return parseInsertStmt(ctx)
+	case T_UPDATE:
+		return parseUpdateStmt(ctx)
+	case T_DELETE:
+		return parseDeleteStmt(ctx)
+	case T_RETURN:
+		return parseReturnStmt(ctx)
+	case T_ID:
+		if ctx.at(T_IF) {
+			return parseIfStmt(ctx)
+		}
+		if ctx.at(T_FOR) {
+			return parseForStmt(ctx)
+		}
+		return parseCallStmt(ctx)
 	}
 
 	return false
 }
 
+func parseIfStmt(ctx *parseContext) bool {
+	if !ctx.at(T_IF) {
+		return false
+	}
+	m := ctx.mark()
+	ctx.advanceAs(T_IF)
+
+	if !parseExpr(ctx) {
+		ctx.expected("condition")
+	}
+	parseNestedBlock(ctx)
+
+	for ctx.at(T_ELSEIF) {
+		ctx.advanceAs(T_ELSEIF)
+		if !parseExpr(ctx) {
+			ctx.expected("condition")
+		}
+		parseNestedBlock(ctx)
+	}
+
+	if ctx.at(T_ELSE) {
+		ctx.advanceAs(T_ELSE)
+		parseNestedBlock(ctx)
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewIfStmt(ns) })
+
+	return true
+}
+
+// parseForStmt parses a loop over the rows of a query, a range of integers
+// like 1..$n or the elements of an array.
+func parseForStmt(ctx *parseContext) bool {
+	if !ctx.at(T_FOR) {
+		return false
+	}
+	m := ctx.mark()
+	ctx.advanceAs(T_FOR)
+
+	ctx.expect(T_DOLLAR)
+	ctx.expectId("loop variable name")
+	ctx.expect(T_IN)
+
+	if !parseSelectStmt(ctx) {
+		if parseExpr(ctx) {
+			if ctx.tokKind() == T_RANGE {
+				ctx.advance()
+				if !parseExpr(ctx) {
+					ctx.expected("expression")
+				}
+			}
+		} else {
+			ctx.expected("query, range or array")
+		}
+	}
+
+	parseNestedBlock(ctx)
+
+	m.done(func(ns []AstNode) AstNode { return NewForStmt(ns) })
+
+	return true
+}
+
+// parseCallStmt parses a function call used as a statement, e.g. error('not allowed').
+func parseCallStmt(ctx *parseContext) bool {
+	if ctx.tokKind() != T_ID || ctx.peek() != T_LPAREN {
+		return false
+	}
+	m := ctx.mark()
+	parsePrimExpr(ctx)
+	m.done(func(ns []AstNode) AstNode { return NewCallStmt(ns) })
+
+	return true
+}
+
 func parseAssignStmt(ctx *parseContext) bool {
 	if ctx.tokKind() != T_DOLLAR {
 		return false
@@ -177,36 +594,368 @@ func parseAssignStmt(ctx *parseContext) bool {
 	m := ctx.mark()
 	ctx.advance()
 
-	if ctx.tokKind() == T_ID {
+	ctx.expectId("variable name")
+
+	typed := parseTypeRef(ctx)
+
+	if ctx.tokKind() == T_DECLARE || ctx.tokKind() == T_ASSIGN || !typed {
+		if ctx.tokKind() == T_DECLARE {
+			ctx.advance()
+		} else {
+			ctx.expect(T_ASSIGN)
+		}
+		if !parseExpr(ctx) {
+			ctx.expected("expression")
+		}
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewAssignStmt(ns) })
+
+	return true
+
+}
+
+func parseSelectStmt(ctx *parseContext) bool {
+	if ctx.tokKind() != T_SELECT {
+		return false
+	}
+	m := ctx.mark()
+	ctx.advance()
+
+	for parseResultColumn(ctx) {
+		if ctx.tokKind() != T_COMMA {
+			break
+		}
+		ctx.advance()
+	}
+
+	if ctx.tokKind() == T_FROM {
 		ctx.advance()
+		if !parseTableRef(ctx) {
+			ctx.expected("table name")
+		}
+		for parseJoinClause(ctx) {
+		}
 	}
 
-	if ctx.tokKind() == T_ASSIGN {
+	parseWhereClause(ctx)
+
+	if ctx.tokKind() == T_ORDER {
+		om := ctx.mark()
 		ctx.advance()
-		parseExpr(ctx)
+		ctx.expect(T_BY)
+		for parseExpr(ctx) {
+			if ctx.tokKind() == T_ASC || ctx.tokKind() == T_DESC {
+				ctx.advance()
+			}
+			if ctx.tokKind() != T_COMMA {
+				break
+			}
+			ctx.advance()
+		}
+		om.done(func(ns []AstNode) AstNode { return NewOrderClause(ns) })
 	}
 
-	m.done(func(ns []AstNode) AstNode { return NewAssignStmt(ns) })
+	if ctx.tokKind() == T_LIMIT {
+		lm := ctx.mark()
+		ctx.advance()
+		if !parseExpr(ctx) {
+			ctx.expected("expression")
+		}
+		lm.done(func(ns []AstNode) AstNode { return NewLimitClause(ns) })
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewSelectStmt(ns) })
+
+	return true
+}
+
+func parseResultColumn(ctx *parseContext) bool {
+	m := ctx.mark()
+
+	if ctx.tokKind() == T_STAR {
+		ctx.advance()
+	} else if parseExpr(ctx) {
+		if ctx.tokKind() == T_AS {
+			ctx.advance()
+			ctx.expectId("column alias")
+		} else if ctx.tokKind() == T_ID {
+			ctx.advance()
+		}
+	} else {
+		m.drop()
+		return false
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewResultColumn(ns) })
+
+	return true
+}
+
+func parseTableRef(ctx *parseContext) bool {
+	if !ctx.atName() {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advanceName()
+
+	if ctx.tokKind() == T_AS {
+		ctx.advance()
+		ctx.expectId("table alias")
+	} else if ctx.tokKind() == T_ID {
+		ctx.advance()
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewTableRef(ns) })
+
+	return true
+}
+
+func parseJoinClause(ctx *parseContext) bool {
+	if ctx.tokKind() != T_JOIN && ctx.tokKind() != T_INNER && ctx.tokKind() != T_LEFT {
+		return false
+	}
+
+	m := ctx.mark()
+	if ctx.tokKind() != T_JOIN {
+		ctx.advance()
+	}
+	ctx.expect(T_JOIN)
+
+	if !parseTableRef(ctx) {
+		ctx.expected("table name")
+	}
+
+	if ctx.expect(T_ON) {
+		if !parseExpr(ctx) {
+			ctx.expected("expression")
+		}
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewJoinClause(ns) })
+
+	return true
+}
+
+func parseWhereClause(ctx *parseContext) bool {
+	if ctx.tokKind() != T_WHERE {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	if !parseExpr(ctx) {
+		ctx.expected("expression")
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewWhereClause(ns) })
+
+	return true
+}
+
+func parseInsertStmt(ctx *parseContext) bool {
+	if ctx.tokKind() != T_INSERT {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	ctx.expect(T_INTO)
+	if !parseTableRef(ctx) {
+		ctx.expected("table name")
+	}
+
+	parseColumnList(ctx)
+
+	if ctx.expect(T_VALUES) {
+		for parseValuesRow(ctx) {
+			if ctx.tokKind() != T_COMMA {
+				break
+			}
+			ctx.advance()
+		}
+	}
+
+	parseOnConflictClause(ctx)
+
+	m.done(func(ns []AstNode) AstNode { return NewInsertStmt(ns) })
+
+	return true
+}
+
+func parseValuesRow(ctx *parseContext) bool {
+	if ctx.tokKind() != T_LPAREN {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	parseExprList(ctx)
+
+	ctx.expect(T_RPAREN)
+
+	m.done(func(ns []AstNode) AstNode { return NewValuesRow(ns) })
 
 	return true
+}
+
+func parseExprList(ctx *parseContext) {
+	for parseExpr(ctx) {
+		if ctx.tokKind() != T_COMMA {
+			break
+		}
+		ctx.advance()
+	}
+}
+
+func parseOnConflictClause(ctx *parseContext) bool {
+	if ctx.tokKind() != T_ON {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	ctx.expect(T_CONFLICT)
+	parseColumnList(ctx)
+
+	if ctx.expect(T_DO) {
+		if ctx.tokKind() == T_NOTHING {
+			ctx.advance()
+		} else if ctx.tokKind() == T_UPDATE {
+			ctx.advance()
+			ctx.expect(T_SET)
+			parseSetItems(ctx)
+			parseWhereClause(ctx)
+		} else {
+			ctx.expected("'nothing' or 'update'")
+		}
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewOnConflictClause(ns) })
 
+	return true
+}
+
+func parseSetItems(ctx *parseContext) {
+	for parseSetItem(ctx) {
+		if ctx.tokKind() != T_COMMA {
+			break
+		}
+		ctx.advance()
+	}
+}
+
+func parseSetItem(ctx *parseContext) bool {
+	if !ctx.atName() || ctx.tokKind() != T_ID && ctx.peek() != T_ASSIGN {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advanceName()
+
+	if ctx.expect(T_ASSIGN) {
+		if !parseExpr(ctx) {
+			ctx.expected("expression")
+		}
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewSetItem(ns) })
+
+	return true
+}
+
+func parseUpdateStmt(ctx *parseContext) bool {
+	if ctx.tokKind() != T_UPDATE {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	if !parseTableRef(ctx) {
+		ctx.expected("table name")
+	}
+
+	if ctx.expect(T_SET) {
+		parseSetItems(ctx)
+	}
+
+	parseWhereClause(ctx)
+
+	m.done(func(ns []AstNode) AstNode { return NewUpdateStmt(ns) })
+
+	return true
+}
+
+func parseDeleteStmt(ctx *parseContext) bool {
+	if ctx.tokKind() != T_DELETE {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	ctx.expect(T_FROM)
+	if !parseTableRef(ctx) {
+		ctx.expected("table name")
+	}
+
+	parseWhereClause(ctx)
+
+	m.done(func(ns []AstNode) AstNode { return NewDeleteStmt(ns) })
+
+	return true
+}
+
+func parseReturnStmt(ctx *parseContext) bool {
+	if ctx.tokKind() != T_RETURN {
+		return false
+	}
+
+	m := ctx.mark()
+	ctx.advance()
+
+	if ctx.at(T_NEXT) {
+		ctx.advanceAs(T_NEXT)
+	}
+
+	if !parseSelectStmt(ctx) && parseExpr(ctx) {
+		for ctx.tokKind() == T_COMMA {
+			ctx.advance()
+			if !parseExpr(ctx) {
+				ctx.expected("expression")
+			}
+		}
+	}
+
+	m.done(func(ns []AstNode) AstNode { return NewReturnStmt(ns) })
+
+	return true
 }
 
 func parseExpr(ctx *parseContext) bool {
-	return parseTermExpr(ctx)
+	return parseOrExpr(ctx)
 }
 
-func parseTermExpr(ctx *parseContext) bool {
+// parseBinExpr parses a left-associative chain of operands separated by one of ops.
+func parseBinExpr(ctx *parseContext, operand func(*parseContext) bool, ops ...TokKind) bool {
 	m := ctx.mark()
 
-	if !parseFactorExpr(ctx) {
+	if !operand(ctx) {
 		m.drop()
 		return false
 	}
 
-	for ctx.tokKind() == T_PLUS || ctx.tokKind() == T_MINUS {
+	for isOneOf(ctx.tokKind(), ops) {
 		ctx.advance()
-		parseFactorExpr(ctx)
+		if !operand(ctx) {
+			ctx.expected("expression")
+		}
 		m.done(func(ns []AstNode) AstNode { return NewBinExpr(ns) })
 		m = m.precede()
 	}
@@ -214,46 +963,163 @@ func parseTermExpr(ctx *parseContext) bool {
 	m.drop()
 
 	return true
+}
 
+func isOneOf(k TokKind, ks []TokKind) bool {
+	for _, o := range ks {
+		if k == o {
+			return true
+		}
+	}
+	return false
 }
 
-func parseFactorExpr(ctx *parseContext) bool {
+func parseOrExpr(ctx *parseContext) bool {
+	return parseBinExpr(ctx, parseAndExpr, T_KW_OR)
+}
+
+func parseAndExpr(ctx *parseContext) bool {
+	return parseBinExpr(ctx, parseNotExpr, T_KW_AND)
+}
+
+func parseNotExpr(ctx *parseContext) bool {
+	if ctx.tokKind() == T_NOT {
+		m := ctx.mark()
+		ctx.advance()
+		if !parseNotExpr(ctx) {
+			ctx.expected("expression")
+		}
+		m.done(func(ns []AstNode) AstNode { return NewUnaryExpr(ns) })
+		return true
+	}
+
+	return parseCmpExpr(ctx)
+}
+
+func parseCmpExpr(ctx *parseContext) bool {
 	m := ctx.mark()
 
-	if !parsePrimExpr(ctx) {
+	if !parseBinExpr(ctx, parseConcatExpr, T_ASSIGN, T_EQ, T_NOT_EQ, T_NEQ, T_LESS, T_LESS_EQ, T_GT, T_GT_EQ) {
 		m.drop()
 		return false
 	}
 
-	for ctx.tokKind() == T_STAR || ctx.tokKind() == T_DIV || ctx.tokKind() == T_MOD {
+	if ctx.tokKind() == T_IS {
 		ctx.advance()
-		parsePrimExpr(ctx)
-		m.done(func(ns []AstNode) AstNode { return NewBinExpr(ns) })
-		m = m.precede()
+		if ctx.tokKind() == T_NOT {
+			ctx.advance()
+		}
+		ctx.expect(T_NULL)
+		m.done(func(ns []AstNode) AstNode { return NewIsNullExpr(ns) })
+		return true
 	}
 
 	m.drop()
 
 	return true
+}
+
+func parseConcatExpr(ctx *parseContext) bool {
+	return parseBinExpr(ctx, parseTermExpr, T_LOGIC_OR)
+}
+
+func parseTermExpr(ctx *parseContext) bool {
+	return parseBinExpr(ctx, parseFactorExpr, T_PLUS, T_MINUS)
+}
 
+func parseFactorExpr(ctx *parseContext) bool {
+	return parseBinExpr(ctx, parseUnaryExpr, T_STAR, T_DIV, T_MOD)
+}
+
+func parseUnaryExpr(ctx *parseContext) bool {
+	if ctx.tokKind() == T_MINUS || ctx.tokKind() == T_PLUS {
+		m := ctx.mark()
+		ctx.advance()
+		if !parseUnaryExpr(ctx) {
+			ctx.expected("expression")
+		}
+		m.done(func(ns []AstNode) AstNode { return NewUnaryExpr(ns) })
+		return true
+	}
+
+	return parsePrimExpr(ctx)
 }
 
 func parsePrimExpr(ctx *parseContext) bool {
-	if ctx.tokKind() == T_NUM {
+	switch ctx.tokKind() {
+	case T_NUM:
 		m := ctx.mark()
 		ctx.advance()
 		m.done(func(ns []AstNode) AstNode { return NewIntLitExpr(ns) })
 		return true
-	}
 
-	if ctx.tokKind() == T_DOLLAR {
+	case T_STRING:
+		m := ctx.mark()
+		ctx.advance()
+		m.done(func(ns []AstNode) AstNode { return NewStringLitExpr(ns) })
+		return true
+
+	case T_TRUE, T_FALSE:
+		m := ctx.mark()
+		ctx.advance()
+		m.done(func(ns []AstNode) AstNode { return NewBoolLitExpr(ns) })
+		return true
+
+	case T_NULL:
+		m := ctx.mark()
+		ctx.advance()
+		m.done(func(ns []AstNode) AstNode { return NewNullLitExpr(ns) })
+		return true
+
+	case T_DOLLAR:
 		m := ctx.mark()
 		ctx.advance()
-		if ctx.tokKind() == T_ID {
+		ctx.expectId("variable name")
+		if ctx.tokKind() == T_DOT {
 			ctx.advance()
+			ctx.expectId("field name")
 		}
 		m.done(func(ns []AstNode) AstNode { return NewVarExpr(ns) })
 		return true
+
+	case T_AT:
+		m := ctx.mark()
+		ctx.advance()
+		ctx.expectId("context variable name")
+		m.done(func(ns []AstNode) AstNode { return NewCtxVarExpr(ns) })
+		return true
+
+	case T_LPAREN:
+		m := ctx.mark()
+		ctx.advance()
+		if !parseExpr(ctx) {
+			ctx.expected("expression")
+		}
+		ctx.expect(T_RPAREN)
+		m.done(func(ns []AstNode) AstNode { return NewParenExpr(ns) })
+		return true
+	}
+
+	if ctx.atUnreserved() {
+		m := ctx.mark()
+		ctx.advanceName()
+		if ctx.tokKind() == T_LPAREN {
+			ctx.advance()
+			if ctx.tokKind() == T_STAR {
+				ctx.advance()
+			} else {
+				parseExprList(ctx)
+			}
+			ctx.expect(T_RPAREN)
+			m.done(func(ns []AstNode) AstNode { return NewCallExpr(ns) })
+			return true
+		}
+		if ctx.tokKind() == T_DOT {
+			ctx.advance()
+			ctx.expectId("column name")
+		}
+		m.done(func(ns []AstNode) AstNode { return NewColumnExpr(ns) })
+		return true
 	}
 
 	return false
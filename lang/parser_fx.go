@@ -13,7 +13,11 @@
 // limitations under the License.
 package lang
 
-import "slices"
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
 
 type marker struct {
 	ctx      *parseContext
@@ -29,6 +33,8 @@ type parseContext struct {
 	tokens  []Token
 	markers []*marker
 	pos     int
+	textLen int
+	errors  []Diagnostic
 }
 
 func (m *marker) drop() {
@@ -87,16 +93,19 @@ func (m *marker) done(f func([]AstNode) AstNode) {
 	}
 	m.end = len(m.ctx.markers)
 	m.endPos = m.ctx.pos - 1
+	for m.endPos >= m.startPos && isTrivia(m.ctx.tokens[m.endPos].kind) {
+		m.endPos--
+	}
 	m.factory = f
 	m.ctx.markers = append(m.ctx.markers, m)
 }
 
+func isTrivia(k TokKind) bool {
+	return k == T_WS || k == T_COMMENT
+}
+
 func (pc *parseContext) skipWs() {
-	for {
-		k := pc.tokKind()
-		if k != T_WS && k != T_COMMENT {
-			break
-		}
+	for isTrivia(pc.tokKind()) {
 		pc.pos++
 	}
 }
@@ -109,6 +118,81 @@ func (pc *parseContext) tokKind() TokKind {
 	}
 }
 
+func (pc *parseContext) tokText() string {
+	if pc.pos >= len(pc.tokens) {
+		return ""
+	} else {
+		return pc.tokens[pc.pos].text
+	}
+}
+
+// peek returns the kind of the significant token after the current one.
+func (pc *parseContext) peek() TokKind {
+	return pc.peekN(1)
+}
+
+// peekN returns the kind of the n-th significant token after the current one.
+func (pc *parseContext) peekN(n int) TokKind {
+	for i := pc.pos + 1; i < len(pc.tokens); i++ {
+		if !isTrivia(pc.tokens[i].kind) {
+			n--
+			if n == 0 {
+				return pc.tokens[i].kind
+			}
+		}
+	}
+	return T_NONE
+}
+
+// atName tells whether the current token can be a name. Keywords are only
+// special where they can start or continue a construct, so any of them is a
+// name where nothing but a name can appear, except the ones starting
+// declarations, which are needed to recover from errors.
+func (pc *parseContext) atName() bool {
+	k := pc.tokKind()
+	return k == T_ID || isKeyword(k) && !isDeclStart(k)
+}
+
+// atUnreserved tells whether the current token is an identifier or a keyword
+// which cannot start or continue an expression, so that it can be a column
+// or function name there too.
+func (pc *parseContext) atUnreserved() bool {
+	return pc.tokKind() == T_ID || unreservedKeywords[pc.tokKind()]
+}
+
+// at tells whether the current token is of kind k. Contextual keywords are
+// lexed as identifiers, so they are matched by their text.
+func (pc *parseContext) at(k TokKind) bool {
+	return pc.tokKind() == k || contextualKeywords[k] && pc.atId(string(k))
+}
+
+// advanceAs makes the current token of kind k and advances past it, which turns
+// a contextual keyword into a keyword where the grammar expects it.
+func (pc *parseContext) advanceAs(k TokKind) {
+	pc.tokens[pc.pos].kind = k
+	pc.advance()
+}
+
+// atDeclStart tells whether a declaration starts at the current token. A
+// procedure is told apart from a column or a statement named procedure by the
+// name and the parameter list following it.
+func (pc *parseContext) atDeclStart() bool {
+	if pc.at(T_PROCEDURE) {
+		return pc.peek() == T_ID && pc.peekN(2) == T_LPAREN
+	}
+	return isDeclStart(pc.tokKind())
+}
+
+// advanceName makes the current token an identifier and advances past it.
+func (pc *parseContext) advanceName() {
+	pc.tokens[pc.pos].kind = T_ID
+	pc.advance()
+}
+
+func (pc *parseContext) atId(text string) bool {
+	return pc.tokKind() == T_ID && strings.EqualFold(pc.tokText(), text)
+}
+
 func (pc *parseContext) advance() {
 	if pc.pos >= len(pc.tokens) {
 		panic("Can't advance")
@@ -117,6 +201,80 @@ func (pc *parseContext) advance() {
 	pc.skipWs()
 }
 
+func (pc *parseContext) expect(k TokKind) bool {
+	if pc.at(k) {
+		pc.advanceAs(k)
+		return true
+	}
+	pc.expected(fmt.Sprintf("'%s'", k))
+	switch k {
+	case T_SEMICOLON, T_RBRACE, T_RPAREN, T_RBRACKET:
+		d := &pc.errors[len(pc.errors)-1]
+		d.Fixes = []Fix{replaceFix(fmt.Sprintf("Insert '%s'", k), d.Start, d.Start, string(k))}
+	}
+	return false
+}
+
+func (pc *parseContext) expectId(what string) bool {
+	if pc.atName() {
+		pc.advanceName()
+		return true
+	}
+	pc.expected(what)
+	return false
+}
+
+// expected reports a missing construct right after the last significant token.
+func (pc *parseContext) expected(what string) {
+	end := 0
+	for i := pc.pos - 1; i >= 0; i-- {
+		if !isTrivia(pc.tokens[i].kind) {
+			end = pc.tokens[i].end
+			break
+		}
+	}
+	pc.errors = append(pc.errors, Diagnostic{
+		Start:    end,
+		End:      end,
+		Severity: SevError,
+		Code:     "syntax",
+		Message:  "expected " + what,
+	})
+}
+
+// errorNode reports the current token as unexpected and wraps it into an
+// ErrorNode. An identifier resembling one of the keywords which may appear here
+// is most likely a misspelling, so the error gets a fix for it.
+func (pc *parseContext) errorNode(msg string, expected ...TokKind) {
+	start, end := pc.textLen, pc.textLen
+	if pc.pos < len(pc.tokens) {
+		start, end = pc.tokens[pc.pos].start, pc.tokens[pc.pos].end
+	}
+	d := Diagnostic{
+		Start:    start,
+		End:      end,
+		Severity: SevError,
+		Code:     "syntax",
+		Message:  msg,
+	}
+	if pc.tokKind() == T_ID {
+		candidates := []string{}
+		for _, k := range expected {
+			candidates = append(candidates, string(k))
+		}
+		if c := closest(pc.tokText(), candidates); c != "" {
+			d.Fixes = []Fix{replaceFix(fmt.Sprintf("Change to '%s'", c), start, end, c)}
+		}
+	}
+	pc.errors = append(pc.errors, d)
+	if pc.pos >= len(pc.tokens) {
+		return
+	}
+	m := pc.mark()
+	pc.advance()
+	m.done(func(ns []AstNode) AstNode { return NewErrorNode(ns) })
+}
+
 func (pc *parseContext) mark() *marker {
 	marker := marker{
 		ctx:      pc,
@@ -166,6 +324,9 @@ func (pc *parseContext) build() AstNode {
 			n := m.factory(children[len(children)-1])
 			children = children[0 : len(children)-1]
 			markers = markers[0 : len(markers)-1]
+			if d, ok := n.(interface{ setComments([]*TokNode) }); ok {
+				d.setComments(leadingComments(children[len(children)-1]))
+			}
 			addChild(n)
 		default:
 			panic("This is impossible")
@@ -176,15 +337,52 @@ func (pc *parseContext) build() AstNode {
 		panic("There should be one marker left")
 	}
 
+	for tokPos < len(pc.tokens) {
+		addChild(&TokNode{tok: pc.tokens[tokPos]})
+		tokPos++
+	}
+
 	return NewFileRoot(children[0])
 }
 
+// leadingComments returns the comments at the end of the nodes preceding a
+// declaration, which are its documentation. A blank line ends them, and a
+// comment following something on its line belongs to that.
+func leadingComments(ns []AstNode) []*TokNode {
+	res := []*TokNode{}
+	for i := len(ns) - 1; i >= 0; i-- {
+		t, ok := ns[i].(*TokNode)
+		if !ok {
+			break
+		}
+		if t.tok.kind == T_WS {
+			if strings.Count(t.tok.text, "\n") > 1 {
+				break
+			}
+			continue
+		}
+		if t.tok.kind != T_COMMENT {
+			break
+		}
+		if i > 0 {
+			prev, ok := ns[i-1].(*TokNode)
+			if !ok || prev.tok.kind != T_WS || !strings.Contains(prev.tok.text, "\n") {
+				break
+			}
+		}
+		res = append(res, t)
+	}
+	slices.Reverse(res)
+	return res
+}
+
 func newParseCtx(text string) parseContext {
 	toks := tokenize(text)
 
 	res := parseContext{
-		tokens: toks,
-		pos:    0,
+		tokens:  toks,
+		pos:     0,
+		textLen: len(text),
 	}
 	res.skipWs()
 	return res
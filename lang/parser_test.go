@@ -134,6 +134,216 @@ func TestOpPriority(t *testing.T) {
 	assert.Equal(t, "2*3", r.Text())
 }
 
+func TestLogicalPriority(t *testing.T) {
+	e := buildExpr("$a = 1 or $b > 2 and not $c").(*BinExpr)
+	assert.Equal(t, T_KW_OR, e.Op())
+
+	r := (*e.Right()).(*BinExpr)
+	assert.Equal(t, T_KW_AND, r.Op())
+	assert.Equal(t, "not $c", (*r.Right()).(*UnaryExpr).Text())
+}
+
+func TestPrimaryExprs(t *testing.T) {
+	assert.Equal(t, "it's", buildExpr("'it''s'").(*StringLitExpr).Value())
+	assert.Equal(t, "@caller", buildExpr("@caller").(*CtxVarExpr).VarName())
+	assert.Equal(t, true, buildExpr("true").(*BoolLitExpr).Value())
+
+	ce := buildExpr("u.name").(*ColumnExpr)
+	assert.Equal(t, "u", ce.Table())
+	assert.Equal(t, "name", ce.Column())
+
+	call := buildExpr("coalesce($a, 1)").(*CallExpr)
+	assert.Equal(t, "coalesce", call.Name())
+	assert.Equal(t, 2, len(call.Args()))
+
+	is := buildExpr("$a is not null").(*IsNullExpr)
+	assert.True(t, is.Negated())
+}
+
+func TestTableColumns(t *testing.T) {
+	fr := ParseFile(`database d;
+		table users {
+			id uuid primary key,
+			name text notnull maxlen(10),
+			tags text[],
+			price decimal(10, 2) default(0),
+			#name_idx unique(name, id),
+			foreign_key (id) references accounts(id) on_delete cascade
+		}`)
+	assert.Empty(t, fr.SyntaxErrors())
+
+	td := fr.TableDecls()[0]
+	cols := td.Columns()
+	assert.Equal(t, 4, len(cols))
+	assert.Equal(t, "id", cols[0].Name())
+	assert.Equal(t, "uuid", cols[0].Type().Name())
+	assert.True(t, cols[0].HasAttr("primary"))
+	assert.Equal(t, "10", (*cols[1].Attr("maxlen").Arg()).Text())
+	assert.True(t, cols[2].Type().IsArray())
+	assert.Equal(t, "decimal(10, 2)", cols[3].Type().Text())
+
+	idx := td.Indexes()[0]
+	assert.Equal(t, "name_idx", idx.Name())
+	assert.Equal(t, "unique", idx.Kind())
+	assert.Equal(t, []string{"name", "id"}, idx.Columns())
+
+	fk := td.ForeignKeys()[0]
+	assert.Equal(t, []string{"id"}, fk.Columns())
+	assert.Equal(t, "accounts", fk.RefTable())
+	assert.Equal(t, []string{"id"}, fk.RefColumns())
+	assert.Equal(t, "cascade", fk.Actions()[0].Action())
+}
+
+func TestSqlStmts(t *testing.T) {
+	fr := ParseFile(`database d;
+		action a($id) public view {
+			SELECT u.name AS n, count(*) FROM users u LEFT JOIN orders o ON o.uid = u.id WHERE u.id = $id ORDER BY n DESC LIMIT 10;
+			INSERT INTO users (id, name) VALUES ($id, 'a'), ($id, 'b') ON CONFLICT (id) DO UPDATE SET name = excluded.name;
+			UPDATE users SET name = 'x', age = age + 1 WHERE id = $id;
+			DELETE FROM users WHERE id = $id;
+		}`)
+	assert.Empty(t, fr.SyntaxErrors())
+
+	ad := fr.ActionDecls()[0]
+	assert.Equal(t, []TokKind{T_PUBLIC, T_VIEW}, ad.Modifiers())
+
+	sts := ad.Stmts()
+	assert.Equal(t, 4, len(sts))
+
+	ss := sts[0].(*SelectStmt)
+	assert.Equal(t, "n", ss.ResultColumns()[0].Alias())
+	assert.Equal(t, "users", ss.From().Name())
+	assert.Equal(t, "u", ss.From().Alias())
+	assert.Equal(t, "orders", ss.Joins()[0].Table().Name())
+	assert.Equal(t, "u.id = $id", (*ss.Where().Expr()).Text())
+	assert.NotNil(t, ss.Limit())
+
+	is := sts[1].(*InsertStmt)
+	assert.Equal(t, []string{"id", "name"}, is.Columns().Names())
+	assert.Equal(t, 2, len(is.Rows()))
+	assert.Equal(t, []string{"id"}, is.OnConflict().Columns().Names())
+	assert.Equal(t, "name", is.OnConflict().Sets()[0].Column())
+
+	us := sts[2].(*UpdateStmt)
+	assert.Equal(t, 2, len(us.Sets()))
+
+	ds := sts[3].(*DeleteStmt)
+	assert.Equal(t, "users", ds.Table().Name())
+}
+
+func TestLosslessTree(t *testing.T) {
+	text := "database d;\n// c\naction a( { $x = ; } }} trailing"
+	fr := ParseFile(text)
+	assert.Equal(t, text, fr.Text())
+	assert.NotEmpty(t, fr.SyntaxErrors())
+}
+
+func TestNodeRangesExcludeTrailingTrivia(t *testing.T) {
+	text := "database d;\ntable t {} // c\n"
+	fr := ParseFile(text)
+	td := fr.TableDecls()[0]
+	assert.Equal(t, "table t {}", td.Text())
+	assert.Equal(t, 12, td.Start())
+	assert.Equal(t, 22, td.End())
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	fr := ParseFile("database d\naction a($x) { $x = 1 $y = 2; }")
+	msgs := []string{}
+	for _, d := range fr.SyntaxErrors() {
+		msgs = append(msgs, d.Message)
+	}
+	assert.Equal(t, []string{"expected ';'", "expected ';'"}, msgs)
+	assert.Equal(t, 10, fr.SyntaxErrors()[0].Start)
+}
+
+func TestDumpTableDecl(t *testing.T) {
+	fr := ParseFile("database d;\ntable t {\n  id int primary\n}")
+
+	assert.Equal(t, `FileRoot@0..40
+  DbDirective@0..11
+    DATABASE@0..8 "database"
+    WS@8..9 " "
+    ID@9..10 "d"
+    SEMICOLON@10..11 ";"
+  WS@11..12 "\n"
+  TableDecl@12..40
+    TABLE@12..17 "table"
+    WS@17..18 " "
+    ID@18..19 "t"
+    WS@19..20 " "
+    LBRACE@20..21 "{"
+    WS@21..24 "\n  "
+    ColumnDecl@24..38
+      ID@24..26 "id"
+      WS@26..27 " "
+      TypeRef@27..30
+        ID@27..30 "int"
+      WS@30..31 " "
+      ColumnAttr@31..38
+        ID@31..38 "primary"
+    WS@38..39 "\n"
+    RBRACE@39..40 "}"
+`, Dump(fr))
+}
+
+func TestDumpIncompleteStmt(t *testing.T) {
+	fr := ParseFile("database d;\naction a($x) { $x = ; }")
+
+	assert.Equal(t, `ActionDecl@12..35
+  ACTION@12..18 "action"
+  WS@18..19 " "
+  ID@19..20 "a"
+  LPAREN@20..21 "("
+  ParamDecl@21..23
+    DOLLAR@21..22 "$"
+    ID@22..23 "x"
+  RPAREN@23..24 ")"
+  WS@24..25 " "
+  LBRACE@25..26 "{"
+  WS@26..27 " "
+  AssignStmt@27..31
+    DOLLAR@27..28 "$"
+    ID@28..29 "x"
+    WS@29..30 " "
+    ASSIGN@30..31 "="
+  WS@31..32 " "
+  SEMICOLON@32..33 ";"
+  WS@33..34 " "
+  RBRACE@34..35 "}"
+`, Dump(fr.ActionDecls()[0]))
+}
+
+func TestDumpExprPriority(t *testing.T) {
+	assert.Equal(t, `BinExpr@14..21
+  IntLitExpr@14..15
+    NUM@14..15 "1"
+  PLUS@15..16 "+"
+  BinExpr@16..21
+    IntLitExpr@16..17
+      NUM@16..17 "2"
+    STAR@17..18 "*"
+    UnaryExpr@18..21
+      MINUS@18..19 "-"
+      VarExpr@19..21
+        DOLLAR@19..20 "$"
+        ID@20..21 "y"
+`, Dump(buildExpr("1+2*-$y")))
+}
+
+func TestDumpJSON(t *testing.T) {
+	b, err := DumpJSON(ParseFile("use x;"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"kind": "FileRoot", "start": 0, "end": 6, "children": [
+		{"kind": "ExtDirective", "start": 0, "end": 6, "children": [
+			{"kind": "USE", "start": 0, "end": 3, "text": "use"},
+			{"kind": "WS", "start": 3, "end": 4, "text": " "},
+			{"kind": "ID", "start": 4, "end": 5, "text": "x"},
+			{"kind": "SEMICOLON", "start": 5, "end": 6, "text": ";"}
+		]}
+	]}`, string(b))
+}
+
 func buildExpr(text string) Expr {
 	ctx := newParseCtx("action a(){$x=" + text + ";}")
 	parseFile(&ctx)
@@ -143,3 +353,43 @@ func buildExpr(text string) Expr {
 	as := ad.Stmts()[0].(*AssignStmt)
 	return *as.Expr()
 }
+
+func TestReturnStmt(t *testing.T) {
+	fr := ParseFile("database d; action a($x) public { return $x, 1 + 2; return; }")
+	assert.Empty(t, fr.SyntaxErrors())
+
+	sts := fr.ActionDecls()[0].Stmts()
+	assert.Equal(t, 2, len(sts))
+	assert.Equal(t, 2, len(sts[0].(*ReturnStmt).Exprs()))
+	assert.Empty(t, sts[1].(*ReturnStmt).Exprs())
+}
+
+func TestDocComments(t *testing.T) {
+	fr := ParseFile(`database d;
+// Users of the app.
+//
+// Everyone who signed up.
+table users {
+	// The id.
+	id int primary, // trailing
+	/**
+	 * The name,
+	 * unique.
+	 */
+	name text,
+
+	age int
+}
+
+// Not about get.
+
+/* Gets a user. */
+@kgw(authn='true')
+procedure get() public view {}`)
+	td := fr.TableDecls()[0]
+	assert.Equal(t, "Users of the app.\n\nEveryone who signed up.", td.DocComment())
+	assert.Equal(t, "The id.", td.Column("id").DocComment())
+	assert.Equal(t, "The name,\nunique.", td.Column("name").DocComment())
+	assert.Equal(t, "", td.Column("age").DocComment())
+	assert.Equal(t, "Gets a user.", fr.ActionDecls()[0].DocComment())
+}
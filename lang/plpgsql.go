@@ -0,0 +1,705 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	CodeUnknownContextVar    = "unknown-context-variable"
+	CodeUnsupportedStatement = "unsupported-statement"
+)
+
+// contextVars are the context variables of Kuneiform with the types of the
+// function arguments they're passed as.
+var contextVars = []struct {
+	name string
+	typ  string
+}{
+	{"caller", "TEXT"},
+	{"signer", "BYTEA"},
+	{"txid", "TEXT"},
+	{"height", "INT8"},
+	{"block_timestamp", "INT8"},
+	{"authenticator", "TEXT"},
+	{"foreign_caller", "TEXT"},
+}
+
+func contextVarType(name string) string {
+	for _, cv := range contextVars {
+		if cv.name == name {
+			return cv.typ
+		}
+	}
+	return ""
+}
+
+// pgVar returns the PL/pgSQL name of a Kuneiform variable. The underscore keeps
+// variables apart from the columns used in the same statements.
+func pgVar(name string) string {
+	return "_" + strings.ToLower(strings.TrimPrefix(name, "$"))
+}
+
+// pgColumn is a column of a function result.
+type pgColumn struct {
+	name string
+	typ  string
+}
+
+type returnKind int
+
+const (
+	returnVoid returnKind = iota
+	returnScalar
+	returnOut
+	returnTable
+)
+
+// fnGen translates an action or a procedure to a PL/pgSQL function.
+type fnGen struct {
+	g       *sqlGen
+	c       *checker
+	ad      *ActionDecl
+	vars    map[string]string      // types by lowercased variable name
+	records map[string]*SelectStmt // queries of the loops over rows
+	locals  []string
+	kind    returnKind
+	outs    []string
+	result  *SelectStmt // the SELECT whose rows an action returns
+	sb      strings.Builder
+	indent  int
+}
+
+// function returns an action or a procedure as a function taking the context
+// variables it needs followed by its parameters. Actions return the rows of
+// their last SELECT.
+func (g *sqlGen) function(ad *ActionDecl) string {
+	f := fnGen{
+		g:       g,
+		c:       &checker{fr: g.fr},
+		ad:      ad,
+		vars:    map[string]string{},
+		records: map[string]*SelectStmt{},
+	}
+
+	args := []string{}
+	for _, name := range g.contextArgs(ad) {
+		args = append(args, "ctx_"+name+" "+contextVarType(name))
+	}
+	for _, pd := range ad.Params() {
+		typ := ""
+		if pd.Type() != nil {
+			typ = g.typeName(pd.Type())
+		} else {
+			typ = f.paramType(pd.Name())
+		}
+		f.vars[strings.ToLower(pd.Name())] = typ
+		args = append(args, pgVar(pd.Name())+" "+typ)
+	}
+
+	f.declare(ad.Stmts())
+	returns := f.returnType(&args)
+	f.stmts(ad.Stmts())
+
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "\nCREATE OR REPLACE FUNCTION %s(%s)\nRETURNS %s\nLANGUAGE plpgsql", g.qualified(ad.Name()), strings.Join(args, ", "), returns)
+	if ad.HasModifier(T_VIEW) {
+		sb.WriteString(" STABLE")
+	}
+	sb.WriteString(" AS $$\n")
+	if len(f.outs) > 0 {
+		// result columns are variables too, and they mustn't hide the table columns
+		sb.WriteString("#variable_conflict use_column\n")
+	}
+	if len(f.locals) > 0 {
+		sb.WriteString("DECLARE\n")
+		for _, l := range f.locals {
+			sb.WriteString("    " + l + ";\n")
+		}
+	}
+	sb.WriteString("BEGIN\n")
+	sb.WriteString(f.sb.String())
+	sb.WriteString("END;\n$$;\n")
+	return sb.String()
+}
+
+// contextArgs returns the context variables a function reads, directly or in
+// the functions it calls.
+func (g *sqlGen) contextArgs(ad *ActionDecl) []string {
+	used := map[string]bool{}
+	seen := map[*ActionDecl]bool{}
+	var visit func(ad *ActionDecl)
+	visit = func(ad *ActionDecl) {
+		if seen[ad] {
+			return
+		}
+		seen[ad] = true
+		Walk(ad, func(n AstNode) bool {
+			switch n := n.(type) {
+			case *CtxVarExpr:
+				used[strings.ToLower(strings.TrimPrefix(n.VarName(), "@"))] = true
+			case *CallExpr:
+				if callee := g.fr.ActionDecl(n.Name()); callee != nil {
+					visit(callee)
+				}
+			}
+			return true
+		})
+	}
+	visit(ad)
+
+	res := []string{}
+	for _, cv := range contextVars {
+		if used[cv.name] {
+			res = append(res, cv.name)
+		}
+	}
+	return res
+}
+
+// returnType returns the result type of the function, adding OUT arguments
+// for procedures returning several values.
+func (f *fnGen) returnType(args *[]string) string {
+	rc := f.ad.Returns()
+	if rc == nil {
+		if f.ad.IsProcedure() {
+			return "VOID"
+		}
+		for _, st := range f.ad.Stmts() {
+			if ss, ok := st.(*SelectStmt); ok {
+				f.result = ss
+			}
+		}
+		if f.result == nil {
+			return "VOID"
+		}
+		f.kind = returnTable
+		cols := []string{}
+		for _, c := range f.resultColumns(f.result) {
+			f.outs = append(f.outs, c.name)
+			cols = append(cols, c.name+" "+c.typ)
+		}
+		return "TABLE(" + strings.Join(cols, ", ") + ")"
+	}
+
+	cols := []string{}
+	for i, c := range rc.Columns() {
+		name := c.Name()
+		if name == "" {
+			name = fmt.Sprintf("column%d", i+1)
+		}
+		f.outs = append(f.outs, pgIdent(name))
+		cols = append(cols, pgIdent(name)+" "+f.g.typeName(c.Type()))
+	}
+	switch {
+	case rc.IsTable():
+		f.kind = returnTable
+		return "TABLE(" + strings.Join(cols, ", ") + ")"
+	case len(cols) == 1:
+		f.kind = returnScalar
+		f.outs = nil
+		return f.g.typeName(rc.Columns()[0].Type())
+	default:
+		f.kind = returnOut
+		for _, c := range cols {
+			*args = append(*args, "OUT "+c)
+		}
+		return "RECORD"
+	}
+}
+
+// resultColumns returns the names and types of the columns of a query.
+// Expressions of unknown type are assumed to be TEXT.
+func (f *fnGen) resultColumns(ss *SelectStmt) []pgColumn {
+	scope := f.scope(ss)
+	res := []pgColumn{}
+	for i, rc := range ss.ResultColumns() {
+		if rc.IsStar() {
+			for _, e := range scope {
+				if e.table == nil {
+					continue
+				}
+				for _, cd := range e.table.Columns() {
+					res = append(res, pgColumn{name: pgIdent(cd.Name()), typ: orText(pgTypeName(cd.Type()))})
+				}
+			}
+			continue
+		}
+		if rc.Expr() == nil {
+			continue
+		}
+		e := *rc.Expr()
+		name := rc.Alias()
+		if name == "" {
+			switch e := e.(type) {
+			case *ColumnExpr:
+				name = e.Column()
+			case *CallExpr:
+				name = e.Name()
+			default:
+				name = fmt.Sprintf("column%d", i+1)
+			}
+		}
+		res = append(res, pgColumn{name: pgIdent(name), typ: orText(f.exprType(e, scope))})
+	}
+	return res
+}
+
+func orText(typ string) string {
+	if typ == "" {
+		return "TEXT"
+	}
+	return typ
+}
+
+// scope returns the tables visible in a SQL statement.
+func (f *fnGen) scope(st Stmt) []scopeEntry {
+	res := []scopeEntry{}
+	switch st := st.(type) {
+	case *SelectStmt:
+		for _, tr := range st.TableRefs() {
+			res = append(res, f.c.scopeEntry(tr))
+		}
+	case *InsertStmt:
+		if st.Table() != nil {
+			e := f.c.scopeEntry(st.Table())
+			res = append(res, e, scopeEntry{name: "excluded", table: e.table})
+		}
+	case *UpdateStmt:
+		if st.Table() != nil {
+			res = append(res, f.c.scopeEntry(st.Table()))
+		}
+	case *DeleteStmt:
+		if st.Table() != nil {
+			res = append(res, f.c.scopeEntry(st.Table()))
+		}
+	}
+	return res
+}
+
+// exprType returns the PostgreSQL type of an expression, or an empty string
+// when it can't be told.
+func (f *fnGen) exprType(e Expr, scope []scopeEntry) string {
+	switch e := e.(type) {
+	case *IntLitExpr:
+		return "INT8"
+	case *StringLitExpr:
+		return "TEXT"
+	case *BoolLitExpr:
+		return "BOOLEAN"
+	case *VarExpr:
+		name := strings.ToLower(e.VarName())
+		if e.Field() == "" {
+			return f.vars[name]
+		}
+		if ss := f.records[name]; ss != nil {
+			for _, c := range f.resultColumns(ss) {
+				if c.name == pgIdent(e.Field()) {
+					return c.typ
+				}
+			}
+		}
+	case *CtxVarExpr:
+		return contextVarType(strings.ToLower(strings.TrimPrefix(e.VarName(), "@")))
+	case *ColumnExpr:
+		if cd := f.c.lookupColumn(e, scope); cd != nil {
+			return pgTypeName(cd.Type())
+		}
+	case *ParenExpr:
+		if e.Expr() != nil {
+			return f.exprType(*e.Expr(), scope)
+		}
+	case *IsNullExpr:
+		return "BOOLEAN"
+	case *UnaryExpr:
+		if e.Op() == T_NOT {
+			return "BOOLEAN"
+		}
+		if e.Operand() != nil {
+			return f.exprType(*e.Operand(), scope)
+		}
+	case *CallExpr:
+		switch strings.ToLower(e.Name()) {
+		case "count":
+			return "INT8"
+		case "sum":
+			return "NUMERIC"
+		case "upper", "lower", "trim", "concat", "format":
+			return "TEXT"
+		case "min", "max", "abs", "coalesce":
+			if len(e.Args()) > 0 {
+				return f.exprType(e.Args()[0], scope)
+			}
+		}
+		if callee := f.g.fr.ActionDecl(e.Name()); callee != nil && callee.Returns() != nil && !callee.Returns().IsTable() {
+			if cols := callee.Returns().Columns(); len(cols) == 1 {
+				return pgTypeName(cols[0].Type())
+			}
+		}
+	case *BinExpr:
+		switch e.Op() {
+		case T_PLUS, T_MINUS, T_STAR, T_DIV, T_MOD:
+			for _, o := range []*Expr{e.Left(), e.Right()} {
+				if o != nil {
+					if typ := f.exprType(*o, scope); typ != "" {
+						return typ
+					}
+				}
+			}
+		case T_LOGIC_OR:
+			return "TEXT"
+		default:
+			return "BOOLEAN"
+		}
+	}
+	return ""
+}
+
+// isComparison tells whether an operator compares its operands, so that they
+// have the same type.
+func isComparison(k TokKind) bool {
+	switch k {
+	case T_ASSIGN, T_EQ, T_NOT_EQ, T_NEQ, T_LESS, T_LESS_EQ, T_GT, T_GT_EQ:
+		return true
+	}
+	return false
+}
+
+func isVar(e *Expr, name string) bool {
+	if e == nil {
+		return false
+	}
+	ve, ok := (*e).(*VarExpr)
+	return ok && ve.Field() == "" && strings.EqualFold(ve.VarName(), name)
+}
+
+// paramType infers the type of an untyped action parameter from the first
+// column it's compared with or stored into, or from its use as a loop bound or
+// as an argument of a typed procedure. Other parameters are TEXT.
+func (f *fnGen) paramType(name string) string {
+	if typ, ok := f.inferParamType(name); ok {
+		return typ
+	}
+	return "TEXT"
+}
+
+// inferParamType is paramType telling whether the type could be inferred.
+func (f *fnGen) inferParamType(name string) (string, bool) {
+	for _, st := range f.ad.AllStmts() {
+		scope := f.scope(st)
+		res := ""
+		if is, ok := st.(*InsertStmt); ok && len(scope) > 0 && scope[0].table != nil {
+			cols := []string{}
+			if is.Columns() != nil {
+				cols = is.Columns().Names()
+			} else {
+				for _, cd := range scope[0].table.Columns() {
+					cols = append(cols, cd.Name())
+				}
+			}
+			for _, row := range is.Rows() {
+				for i, e := range row.Exprs() {
+					if res == "" && i < len(cols) && isVar(&e, name) {
+						if cd := scope[0].table.Column(cols[i]); cd != nil {
+							res = pgTypeName(cd.Type())
+						}
+					}
+				}
+			}
+		}
+		Walk(st, func(n AstNode) bool {
+			switch n := n.(type) {
+			case *BinExpr:
+				if !isComparison(n.Op()) || n.Left() == nil || n.Right() == nil {
+					return true
+				}
+				if isVar(n.Left(), name) && res == "" {
+					res = f.exprType(*n.Right(), scope)
+				}
+				if isVar(n.Right(), name) && res == "" {
+					res = f.exprType(*n.Left(), scope)
+				}
+			case *SetItem:
+				if isVar(n.Expr(), name) && res == "" && len(scope) > 0 && scope[0].table != nil {
+					if cd := scope[0].table.Column(n.Column()); cd != nil {
+						res = pgTypeName(cd.Type())
+					}
+				}
+			case *ForStmt:
+				for _, e := range n.Exprs() {
+					if n.IsRange() && isVar(&e, name) {
+						res = "INT8"
+					}
+				}
+			case *CallExpr:
+				callee := f.g.fr.ActionDecl(n.Name())
+				if callee == nil {
+					return true
+				}
+				params := callee.Params()
+				for i, a := range n.Args() {
+					if i < len(params) && params[i].Type() != nil && isVar(&a, name) {
+						res = pgTypeName(params[i].Type())
+					}
+				}
+			}
+			return res == ""
+		})
+		if res != "" {
+			return res, true
+		}
+	}
+	return "", false
+}
+
+// declare collects the variables assigned in the body, which PL/pgSQL needs
+// declared upfront. Untyped variables get the type of the first value assigned.
+func (f *fnGen) declare(sts []Stmt) {
+	add := func(name string, typ string) {
+		f.vars[name] = typ
+		f.locals = append(f.locals, pgVar(name)+" "+typ)
+	}
+	for _, st := range sts {
+		switch st := st.(type) {
+		case *AssignStmt:
+			name := strings.ToLower(st.VarName())
+			if _, ok := f.vars[name]; ok || name == "$" {
+				continue
+			}
+			typ := ""
+			if st.Type() != nil {
+				typ = f.g.typeName(st.Type())
+			} else if st.Expr() != nil {
+				typ = f.exprType(*st.Expr(), nil)
+			}
+			add(name, orText(typ))
+		case *IfStmt:
+			for _, b := range st.Blocks() {
+				f.declare(b.Stmts())
+			}
+		case *ForStmt:
+			name := strings.ToLower(st.VarName())
+			if _, ok := f.vars[name]; !ok && name != "$" {
+				switch {
+				case st.Query() != nil:
+					f.records[name] = st.Query()
+					add(name, "RECORD")
+				case st.IsRange():
+					// the variable of an integer loop is declared by the loop
+					f.vars[name] = "INT8"
+				case len(st.Exprs()) > 0:
+					add(name, orText(strings.TrimSuffix(f.exprType(st.Exprs()[0], nil), "[]")))
+				}
+			}
+			if st.Body() != nil {
+				f.declare(st.Body().Stmts())
+			}
+		}
+	}
+}
+
+func (f *fnGen) line(format string, args ...any) {
+	f.sb.WriteString(strings.Repeat("    ", f.indent))
+	fmt.Fprintf(&f.sb, format, args...)
+	f.sb.WriteString("\n")
+}
+
+func (f *fnGen) stmts(sts []Stmt) {
+	f.indent++
+	for _, st := range sts {
+		f.stmt(st)
+	}
+	f.indent--
+}
+
+func (f *fnGen) stmt(st Stmt) {
+	switch st := st.(type) {
+	case *AssignStmt:
+		if st.Expr() != nil {
+			f.line("%s := %s;", pgVar(st.VarName()), f.sql(*st.Expr()))
+		}
+	case *SelectStmt:
+		if st == f.result {
+			f.line("RETURN QUERY %s;", f.sql(st))
+		} else {
+			f.line("PERFORM %s;", strings.TrimPrefix(f.sql(st), "SELECT "))
+		}
+	case *InsertStmt, *UpdateStmt, *DeleteStmt:
+		f.line("%s;", f.sql(st))
+	case *ReturnStmt:
+		f.ret(st)
+	case *IfStmt:
+		blocks := st.Blocks()
+		for i, c := range st.Conds() {
+			kw := "ELSIF"
+			if i == 0 {
+				kw = "IF"
+			}
+			f.line("%s %s THEN", kw, f.sql(c))
+			if i < len(blocks) {
+				f.stmts(blocks[i].Stmts())
+			}
+		}
+		if e := st.Else(); e != nil {
+			f.line("ELSE")
+			f.stmts(e.Stmts())
+		}
+		f.line("END IF;")
+	case *ForStmt:
+		v := pgVar(st.VarName())
+		es := st.Exprs()
+		switch {
+		case st.Query() != nil:
+			f.line("FOR %s IN %s LOOP", v, f.sql(st.Query()))
+		case st.IsRange() && len(es) == 2:
+			f.line("FOR %s IN %s..%s LOOP", v, f.sql(es[0]), f.sql(es[1]))
+		case len(es) > 0:
+			f.line("FOREACH %s IN ARRAY %s LOOP", v, f.sql(es[0]))
+		}
+		if st.Body() != nil {
+			f.stmts(st.Body().Stmts())
+		}
+		f.line("END LOOP;")
+	case *CallStmt:
+		ce := st.Call()
+		if ce == nil {
+			return
+		}
+		if strings.EqualFold(ce.Name(), "error") && len(ce.Args()) == 1 {
+			f.line("RAISE EXCEPTION '%%', %s;", f.sql(ce.Args()[0]))
+		} else {
+			f.line("PERFORM %s;", f.sql(ce))
+		}
+	}
+}
+
+func (f *fnGen) ret(rs *ReturnStmt) {
+	if rs.IsNext() && f.kind != returnTable {
+		f.g.error(rs, CodeUnsupportedStatement, "return next needs a procedure returning a table")
+		return
+	}
+	exprs := rs.Exprs()
+	switch {
+	case rs.Query() != nil:
+		switch f.kind {
+		case returnTable:
+			f.line("RETURN QUERY %s;", f.sql(rs.Query()))
+			f.line("RETURN;")
+		case returnScalar:
+			f.line("RETURN (%s);", f.sql(rs.Query()))
+		default:
+			f.g.error(rs, CodeUnsupportedStatement, "a query can only be returned by a procedure returning a table or a single value")
+		}
+	case len(exprs) == 0:
+		f.line("RETURN;")
+	case f.kind == returnScalar && len(exprs) == 1:
+		f.line("RETURN %s;", f.sql(exprs[0]))
+	case (f.kind == returnOut || f.kind == returnTable) && len(exprs) == len(f.outs):
+		for i, e := range exprs {
+			f.line("%s := %s;", f.outs[i], f.sql(e))
+		}
+		if rs.IsNext() {
+			f.line("RETURN NEXT;")
+		} else {
+			f.line("RETURN;")
+		}
+	default:
+		declared := len(f.outs)
+		if f.kind == returnScalar {
+			declared = 1
+		}
+		f.g.error(rs, CodeUnsupportedStatement, "%s '%s' returns %d value(s) here but declares %d", f.ad.Kind(), f.ad.Name(), len(exprs), declared)
+	}
+}
+
+// sql renders a statement or an expression as PostgreSQL text. Names are
+// quoted where needed, tables and called functions are qualified with the
+// schema, variables are replaced with the function's ones and comments are
+// dropped.
+func (f *fnGen) sql(n AstNode) string {
+	ls := f.leaves(nil, []AstNode{n}, []leaf{})
+	sb := strings.Builder{}
+	for i, l := range ls {
+		if i > 0 && needSpace(ls[i-1], l) {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(leafText(l, KeywordUpper))
+	}
+	return sb.String()
+}
+
+func synthLeaf(parent AstNode, kind TokKind, text string) leaf {
+	return leaf{tok: newTok(Token{kind: kind, text: text}), parent: parent}
+}
+
+func (f *fnGen) leaves(parent AstNode, ns []AstNode, res []leaf) []leaf {
+	for _, n := range ns {
+		switch n := n.(type) {
+		case *VarExpr:
+			text := pgVar(n.VarName())
+			if n.Field() != "" {
+				text += "." + pgIdent(n.Field())
+			}
+			res = append(res, synthLeaf(n, T_ID, text))
+		case *CtxVarExpr:
+			name := strings.ToLower(strings.TrimPrefix(n.VarName(), "@"))
+			if contextVarType(name) == "" {
+				f.g.error(n, CodeUnknownContextVar, "unknown context variable '%s'", n.VarName())
+			}
+			res = append(res, synthLeaf(n, T_ID, "ctx_"+name))
+		case *CallExpr:
+			callee := f.g.fr.ActionDecl(n.Name())
+			if callee == nil {
+				res = f.leaves(n, n.Children(), res)
+				continue
+			}
+			args := []string{}
+			for _, name := range f.g.contextArgs(callee) {
+				args = append(args, "ctx_"+name)
+			}
+			for _, a := range n.Args() {
+				args = append(args, f.sql(a))
+			}
+			res = append(res, synthLeaf(n, T_ID, f.g.qualified(callee.Name())+"("+strings.Join(args, ", ")+")"))
+		case *TokNode:
+			switch n.tok.kind {
+			case T_WS, T_COMMENT:
+			case T_EQ:
+				res = append(res, synthLeaf(parent, T_ASSIGN, "="))
+			case T_ID:
+				res = append(res, synthLeaf(parent, T_ID, f.name(parent, n)))
+			default:
+				res = append(res, leaf{tok: n, parent: parent})
+			}
+		default:
+			res = f.leaves(n, n.Children(), res)
+		}
+	}
+	return res
+}
+
+// name returns the PostgreSQL text of an identifier.
+func (f *fnGen) name(parent AstNode, t *TokNode) string {
+	switch parent.(type) {
+	case *TableRef:
+		if findTok(parent.Children(), T_ID) == t {
+			return f.g.qualified(t.Text())
+		}
+	case *CallExpr:
+		return strings.ToLower(t.Text())
+	}
+	return pgIdent(t.Text())
+}
@@ -0,0 +1,334 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Schema is a compiled Kuneiform file in the JSON format of the official
+// Kuneiform compiler, which is what Kwil deployment tools accept. The code
+// generators work from Bindings instead, which have the inferred types.
+type Schema struct {
+	Name       string       `json:"name"`
+	Owner      string       `json:"owner"`
+	Extensions []*Extension `json:"extensions"`
+	Tables     []*Table     `json:"tables"`
+	Actions    []*Action    `json:"actions"`
+	Procedures []*Procedure `json:"procedures"`
+}
+
+type Extension struct {
+	Name           string             `json:"name"`
+	Initialization []*ExtensionConfig `json:"initialization"`
+	Alias          string             `json:"alias"`
+}
+
+type ExtensionConfig struct {
+	Key   string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Table struct {
+	Name        string        `json:"name"`
+	Columns     []*Column     `json:"columns"`
+	Indexes     []*Index      `json:"indexes,omitempty"`
+	ForeignKeys []*ForeignKey `json:"foreign_keys"`
+}
+
+type Column struct {
+	Name       string       `json:"name"`
+	Type       *DataType    `json:"type"`
+	Attributes []*Attribute `json:"attributes,omitempty"`
+}
+
+// DataType is a type name with the precision and scale of decimals as metadata.
+type DataType struct {
+	Name     string    `json:"name"`
+	IsArray  bool      `json:"is_array"`
+	Metadata [2]uint16 `json:"metadata"`
+}
+
+type Attribute struct {
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+type Index struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Type    string   `json:"type"`
+}
+
+type ForeignKey struct {
+	ChildKeys   []string            `json:"child_keys"`
+	ParentKeys  []string            `json:"parent_keys"`
+	ParentTable string              `json:"parent_table"`
+	Actions     []*ForeignKeyAction `json:"actions"`
+}
+
+type ForeignKeyAction struct {
+	On string `json:"on"`
+	Do string `json:"do"`
+}
+
+type Action struct {
+	Name        string   `json:"name"`
+	Annotations []string `json:"annotations"`
+	Parameters  []string `json:"parameters"`
+	Public      bool     `json:"public"`
+	Modifiers   []string `json:"modifiers"`
+	Body        string   `json:"body"`
+}
+
+type Procedure struct {
+	Name        string                `json:"name"`
+	Parameters  []*ProcedureParameter `json:"parameters"`
+	Public      bool                  `json:"public"`
+	Modifiers   []string              `json:"modifiers"`
+	Body        string                `json:"body"`
+	Returns     *ProcedureReturn      `json:"return_types"`
+	Annotations []string              `json:"annotations"`
+}
+
+type ProcedureParameter struct {
+	Name string    `json:"name"`
+	Type *DataType `json:"type"`
+}
+
+type ProcedureReturn struct {
+	IsTable bool         `json:"is_table"`
+	Fields  []*NamedType `json:"fields"`
+}
+
+type NamedType struct {
+	Name string    `json:"name"`
+	Type *DataType `json:"type"`
+}
+
+// schemaTypes are the type names of schemas.
+var schemaTypes = map[string]bool{
+	"int": true, "text": true, "bool": true, "blob": true, "uuid": true, "uint256": true, "decimal": true,
+}
+
+var schemaAttributes = map[string]string{
+	"primary": "PRIMARY_KEY", "pk": "PRIMARY_KEY", "unique": "UNIQUE", "notnull": "NOT_NULL",
+	"default": "DEFAULT", "min": "MIN", "max": "MAX", "minlen": "MIN_LENGTH", "maxlen": "MAX_LENGTH",
+}
+
+var schemaIndexTypes = map[string]string{
+	"index": "BTREE", "unique": "UNIQUE_BTREE", "primary": "PRIMARY",
+}
+
+var schemaFkEvents = map[string]string{
+	"on_delete": "DELETE", "on_update": "UPDATE",
+}
+
+var schemaFkActions = map[string]string{
+	"cascade": "CASCADE", "restrict": "RESTRICT", "set_null": "SET NULL", "set_default": "SET DEFAULT", "no_action": "NO ACTION",
+}
+
+var schemaModifiers = map[TokKind]string{
+	T_VIEW: "VIEW", T_OWNER: "OWNER",
+}
+
+type compiler struct {
+	diags []Diagnostic
+}
+
+// Compile converts a file to a schema. Files with errors, or with constructs
+// the schema format can't express, produce diagnostics instead.
+func Compile(fr *FileRoot) (*Schema, []Diagnostic) {
+	if diags := Check(fr); len(diags) > 0 {
+		return nil, diags
+	}
+	c := compiler{}
+	s := &Schema{
+		Extensions: []*Extension{},
+		Tables:     []*Table{},
+		Actions:    []*Action{},
+		Procedures: []*Procedure{},
+	}
+	if dd := fr.DbDirective(); dd != nil {
+		s.Name = dd.Name()
+	}
+	for _, ed := range fr.ExtDirectives() {
+		s.Extensions = append(s.Extensions, c.extension(ed))
+	}
+	for _, td := range fr.TableDecls() {
+		s.Tables = append(s.Tables, c.table(td))
+	}
+	for _, ad := range fr.ActionDecls() {
+		if ad.IsProcedure() {
+			s.Procedures = append(s.Procedures, c.procedure(ad))
+		} else {
+			s.Actions = append(s.Actions, c.action(ad))
+		}
+	}
+
+	if len(c.diags) > 0 {
+		sortDiagnostics(c.diags)
+		return nil, c.diags
+	}
+	return s, nil
+}
+
+func (c *compiler) error(n AstNode, code string, format string, args ...any) {
+	c.diags = append(c.diags, Diagnostic{
+		Start:    n.Start(),
+		End:      n.End(),
+		Severity: SevError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *compiler) extension(ed *ExtDirective) *Extension {
+	res := &Extension{Name: ed.Name(), Alias: ed.Alias(), Initialization: []*ExtensionConfig{}}
+	for _, ep := range ed.Params() {
+		value := ""
+		if ep.Value() != nil {
+			value = inlineText(*ep.Value())
+		}
+		res.Initialization = append(res.Initialization, &ExtensionConfig{Key: ep.Name(), Value: value})
+	}
+	return res
+}
+
+func (c *compiler) table(td *TableDecl) *Table {
+	res := &Table{Name: td.Name(), Columns: []*Column{}, ForeignKeys: []*ForeignKey{}}
+	for _, cd := range td.Columns() {
+		col := &Column{Name: cd.Name(), Type: c.dataType(cd.Type())}
+		for _, a := range cd.Attrs() {
+			typ, ok := schemaAttributes[a.Name()]
+			if !ok {
+				c.error(a, CodeUnsupportedAttribute, "unknown column attribute '%s'", a.Name())
+				continue
+			}
+			attr := &Attribute{Type: typ}
+			if a.Arg() != nil {
+				attr.Value = inlineText(*a.Arg())
+			}
+			col.Attributes = append(col.Attributes, attr)
+		}
+		res.Columns = append(res.Columns, col)
+	}
+	for _, id := range td.Indexes() {
+		typ, ok := schemaIndexTypes[id.Kind()]
+		if !ok {
+			c.error(id, CodeUnsupportedAttribute, "unknown index kind '%s'", id.Kind())
+			continue
+		}
+		res.Indexes = append(res.Indexes, &Index{Name: id.Name(), Columns: id.Columns(), Type: typ})
+	}
+	for _, fk := range td.ForeignKeys() {
+		key := &ForeignKey{ChildKeys: fk.Columns(), ParentKeys: fk.RefColumns(), ParentTable: fk.RefTable(), Actions: []*ForeignKeyAction{}}
+		for _, a := range fk.Actions() {
+			on, onOk := schemaFkEvents[a.Event()]
+			do, doOk := schemaFkActions[a.Action()]
+			if !onOk || !doOk {
+				c.error(a, CodeUnsupportedAttribute, "unknown foreign key action '%s'", strings.Join(strings.Fields(a.Text()), " "))
+				continue
+			}
+			key.Actions = append(key.Actions, &ForeignKeyAction{On: on, Do: do})
+		}
+		res.ForeignKeys = append(res.ForeignKeys, key)
+	}
+	return res
+}
+
+func (c *compiler) dataType(tr *TypeRef) *DataType {
+	if tr == nil {
+		return nil
+	}
+	res := &DataType{Name: baseTypeName(tr), IsArray: tr.IsArray()}
+	if !schemaTypes[res.Name] {
+		c.error(tr, CodeUnsupportedType, "unknown type '%s'", tr.Name())
+	}
+	for i, a := range tr.Args() {
+		if i >= len(res.Metadata) {
+			break
+		}
+		n, err := strconv.ParseUint(a, 10, 16)
+		if err != nil {
+			c.error(tr, CodeUnsupportedType, "type argument %s is out of range", a)
+			continue
+		}
+		res.Metadata[i] = uint16(n)
+	}
+	return res
+}
+
+func (c *compiler) annotations(ad *ActionDecl) []string {
+	res := []string{}
+	for _, an := range ad.Annotations() {
+		res = append(res, an.String())
+	}
+	return res
+}
+
+func (c *compiler) modifiers(ad *ActionDecl) []string {
+	res := []string{}
+	for _, m := range ad.Modifiers() {
+		if s, ok := schemaModifiers[m]; ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func (c *compiler) action(ad *ActionDecl) *Action {
+	res := &Action{
+		Name:        ad.Name(),
+		Annotations: c.annotations(ad),
+		Parameters:  []string{},
+		Public:      ad.HasModifier(T_PUBLIC),
+		Modifiers:   c.modifiers(ad),
+		Body:        formatBody(ad),
+	}
+	for _, pd := range ad.Params() {
+		if pd.Type() != nil {
+			c.error(pd.Type(), CodeUnsupportedType, "action parameters have no types, only procedure ones do")
+		}
+		res.Parameters = append(res.Parameters, pd.Name())
+	}
+	return res
+}
+
+func (c *compiler) procedure(ad *ActionDecl) *Procedure {
+	res := &Procedure{
+		Name:        ad.Name(),
+		Parameters:  []*ProcedureParameter{},
+		Public:      ad.HasModifier(T_PUBLIC),
+		Modifiers:   c.modifiers(ad),
+		Body:        formatBody(ad),
+		Annotations: c.annotations(ad),
+	}
+	for _, pd := range ad.Params() {
+		if pd.Type() == nil {
+			c.error(pd, CodeUnsupportedType, "procedure parameter '%s' needs a type", pd.Name())
+			continue
+		}
+		res.Parameters = append(res.Parameters, &ProcedureParameter{Name: pd.Name(), Type: c.dataType(pd.Type())})
+	}
+	if rc := ad.Returns(); rc != nil {
+		res.Returns = &ProcedureReturn{IsTable: rc.IsTable(), Fields: []*NamedType{}}
+		for _, col := range rc.Columns() {
+			res.Returns.Fields = append(res.Returns.Fields, &NamedType{Name: col.Name(), Type: c.dataType(col.Type())})
+		}
+	}
+	return res
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compile(t *testing.T, text string) string {
+	s, diags := Compile(ParseFile(text))
+	require.Empty(t, diags)
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestCompile(t *testing.T) {
+	text := `database shop;
+use erc20 { address: '0x1', decimals: 18 } as token;
+
+table users {
+    id uuid primary,
+    name text notnull maxlen(50) default('x'),
+    #name_idx unique(name)
+}
+
+table orders {
+    id int pk,
+    user_id uuid,
+    price decimal(10, 2)[],
+    foreign_key (user_id) references users(id) on_delete cascade
+}
+
+@kgw(authn='true')
+action get_user($id) public view owner {
+  SELECT name FROM users WHERE id=$id;
+}
+
+procedure total($user uuid) private returns (sum decimal(10, 2), int) {
+    return 1, 2;
+}`
+
+	typ := func(name string, array bool, meta string) string {
+		return `{"name":"` + name + `","is_array":` + map[bool]string{true: "true", false: "false"}[array] + `,"metadata":` + meta + `}`
+	}
+	assert.JSONEq(t, `{
+  "name": "shop",
+  "owner": "",
+  "extensions": [{"name": "erc20", "alias": "token", "initialization": [
+    {"name": "address", "value": "'0x1'"},
+    {"name": "decimals", "value": "18"}
+  ]}],
+  "tables": [
+    {"name": "users", "columns": [
+      {"name": "id", "type": `+typ("uuid", false, "[0,0]")+`, "attributes": [{"type": "PRIMARY_KEY"}]},
+      {"name": "name", "type": `+typ("text", false, "[0,0]")+`, "attributes": [
+        {"type": "NOT_NULL"}, {"type": "MAX_LENGTH", "value": "50"}, {"type": "DEFAULT", "value": "'x'"}
+      ]}
+    ], "indexes": [{"name": "name_idx", "columns": ["name"], "type": "UNIQUE_BTREE"}], "foreign_keys": []},
+    {"name": "orders", "columns": [
+      {"name": "id", "type": `+typ("int", false, "[0,0]")+`, "attributes": [{"type": "PRIMARY_KEY"}]},
+      {"name": "user_id", "type": `+typ("uuid", false, "[0,0]")+`},
+      {"name": "price", "type": `+typ("decimal", true, "[10,2]")+`}
+    ], "foreign_keys": [
+      {"child_keys": ["user_id"], "parent_keys": ["id"], "parent_table": "users", "actions": [{"on": "DELETE", "do": "CASCADE"}]}
+    ]}
+  ],
+  "actions": [{
+    "name": "get_user",
+    "annotations": ["kgw(authn='true')"],
+    "parameters": ["$id"],
+    "public": true,
+    "modifiers": ["VIEW", "OWNER"],
+    "body": "SELECT name FROM users WHERE id = $id;"
+  }],
+  "procedures": [{
+    "name": "total",
+    "parameters": [{"name": "$user", "type": `+typ("uuid", false, "[0,0]")+`}],
+    "public": false,
+    "modifiers": [],
+    "body": "return 1, 2;",
+    "return_types": {"is_table": false, "fields": [
+      {"name": "sum", "type": `+typ("decimal", false, "[10,2]")+`},
+      {"name": "", "type": `+typ("int", false, "[0,0]")+`}
+    ]},
+    "annotations": []
+  }]
+}`, compile(t, text))
+}
+
+func TestCompileErrors(t *testing.T) {
+	_, diags := Compile(ParseFile(`database d;
+table t { id int pk, at timestamp, n int sparkly }
+action a($x int) public {}
+procedure p($y) public {}`))
+	messages := []string{}
+	for _, d := range diags {
+		messages = append(messages, d.Message)
+	}
+	assert.Equal(t, []string{
+		"unknown type 'timestamp'",
+		"unknown column attribute 'sparkly'",
+		"action parameters have no types, only procedure ones do",
+		"procedure parameter '$y' needs a type",
+	}, messages)
+}
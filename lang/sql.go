@@ -0,0 +1,335 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	CodeUnsupportedType      = "unsupported-type"
+	CodeUnsupportedAttribute = "unsupported-attribute"
+)
+
+// pgReserved are the PostgreSQL keywords which can't be used as identifiers
+// without quotes.
+var pgReserved = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true, "array": true, "as": true,
+	"asc": true, "asymmetric": true, "both": true, "case": true, "cast": true, "check": true,
+	"collate": true, "column": true, "constraint": true, "create": true, "current_catalog": true,
+	"current_date": true, "current_role": true, "current_time": true, "current_timestamp": true,
+	"current_user": true, "default": true, "deferrable": true, "desc": true, "distinct": true,
+	"do": true, "else": true, "end": true, "except": true, "false": true, "fetch": true, "for": true,
+	"foreign": true, "from": true, "grant": true, "group": true, "having": true, "in": true,
+	"initially": true, "intersect": true, "into": true, "lateral": true, "leading": true,
+	"limit": true, "localtime": true, "localtimestamp": true, "not": true, "null": true,
+	"offset": true, "on": true, "only": true, "or": true, "order": true, "placing": true,
+	"primary": true, "references": true, "returning": true, "select": true, "session_user": true,
+	"some": true, "symmetric": true, "table": true, "then": true, "to": true, "trailing": true,
+	"true": true, "union": true, "unique": true, "user": true, "using": true, "variadic": true,
+	"when": true, "where": true, "window": true, "with": true,
+}
+
+var plainIdent = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// pgIdent returns a Kuneiform name as a PostgreSQL identifier. Names are case
+// insensitive in Kuneiform, so they are lowercased, which is what PostgreSQL
+// does with unquoted identifiers.
+func pgIdent(name string) string {
+	name = strings.ToLower(name)
+	if plainIdent.MatchString(name) && !pgReserved[name] {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func pgIdents(names []string) string {
+	res := []string{}
+	for _, n := range names {
+		res = append(res, pgIdent(n))
+	}
+	return strings.Join(res, ", ")
+}
+
+var fkEvents = map[string]string{
+	"on_delete": "ON DELETE",
+	"on_update": "ON UPDATE",
+}
+
+var fkActions = map[string]string{
+	"cascade":     "CASCADE",
+	"restrict":    "RESTRICT",
+	"set_null":    "SET NULL",
+	"set_default": "SET DEFAULT",
+	"no_action":   "NO ACTION",
+}
+
+// sqlGen generates the PostgreSQL statements creating a schema.
+type sqlGen struct {
+	fr     *FileRoot
+	schema string
+	sb     strings.Builder
+	diags  []Diagnostic
+}
+
+// GenerateSQL returns the PostgreSQL statements creating the tables of a file
+// and the PL/pgSQL functions of its actions and procedures. Tables are created
+// in the schema named after the database, in an order which lets foreign keys
+// reference tables declared further down. Files with errors, or tables using
+// types and attributes PostgreSQL has no equivalent for, produce diagnostics
+// instead. Actions and procedures which can't be translated are left out and
+// reported, while the rest of the statements are still returned.
+func GenerateSQL(fr *FileRoot) (string, []Diagnostic) {
+	if diags := Check(fr); len(diags) > 0 {
+		return "", diags
+	}
+	g := sqlGen{fr: fr}
+	if dd := fr.DbDirective(); dd != nil && dd.Name() != "" {
+		g.schema = dd.Name()
+		fmt.Fprintf(&g.sb, "CREATE SCHEMA IF NOT EXISTS %s;\n", pgIdent(g.schema))
+	}
+
+	created := map[string]bool{}
+	deferred := []string{}
+	for _, td := range tableOrder(fr) {
+		deferred = append(deferred, g.table(td, created)...)
+		created[strings.ToLower(td.Name())] = true
+	}
+	if len(deferred) > 0 {
+		g.sb.WriteString("\n")
+		for _, s := range deferred {
+			g.sb.WriteString(s)
+		}
+	}
+
+	if len(g.diags) > 0 {
+		sortDiagnostics(g.diags)
+		return "", g.diags
+	}
+
+	for _, ad := range fr.ActionDecls() {
+		n := len(g.diags)
+		if f := g.function(ad); len(g.diags) == n {
+			g.sb.WriteString(f)
+		}
+	}
+
+	sortDiagnostics(g.diags)
+	return strings.TrimLeft(g.sb.String(), "\n"), g.diags
+}
+
+func (g *sqlGen) error(n AstNode, code string, format string, args ...any) {
+	g.diags = append(g.diags, Diagnostic{
+		Start:    n.Start(),
+		End:      n.End(),
+		Severity: SevError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// qualified returns the name of a table in the schema.
+func (g *sqlGen) qualified(table string) string {
+	if g.schema == "" {
+		return pgIdent(table)
+	}
+	return pgIdent(g.schema) + "." + pgIdent(table)
+}
+
+// table writes the statements creating a table and its indexes. Foreign keys
+// to tables which aren't created yet, which only happens with cycles, are
+// returned as statements to run after all the tables are created.
+func (g *sqlGen) table(td *TableDecl, created map[string]bool) []string {
+	name := g.qualified(td.Name())
+	defs := []string{}
+	checks := []string{}
+
+	pk := []string{}
+	for _, cd := range td.Columns() {
+		if cd.HasAttr("primary") || cd.HasAttr("pk") {
+			pk = append(pk, cd.Name())
+		}
+	}
+	for _, cd := range td.Columns() {
+		def, colChecks := g.column(cd, len(pk) == 1)
+		defs = append(defs, def)
+		checks = append(checks, colChecks...)
+	}
+	if len(pk) > 1 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", pgIdents(pk)))
+	}
+
+	indexes := []string{}
+	for _, id := range td.Indexes() {
+		switch id.Kind() {
+		case "primary":
+			defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", pgIdents(id.Columns())))
+		case "unique":
+			indexes = append(indexes, fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s);\n", pgIdent(id.Name()), name, pgIdents(id.Columns())))
+		case "index":
+			indexes = append(indexes, fmt.Sprintf("CREATE INDEX %s ON %s (%s);\n", pgIdent(id.Name()), name, pgIdents(id.Columns())))
+		default:
+			g.error(id, CodeUnsupportedAttribute, "unknown index kind '%s'", id.Kind())
+		}
+	}
+
+	deferred := []string{}
+	for _, fk := range td.ForeignKeys() {
+		ref := g.foreignKey(fk)
+		target := strings.ToLower(fk.RefTable())
+		if created[target] || strings.EqualFold(target, td.Name()) {
+			defs = append(defs, ref)
+		} else {
+			deferred = append(deferred, fmt.Sprintf("ALTER TABLE %s ADD %s;\n", name, ref))
+		}
+	}
+	defs = append(defs, checks...)
+
+	fmt.Fprintf(&g.sb, "\nCREATE TABLE %s (\n    %s\n);\n", name, strings.Join(defs, ",\n    "))
+	for _, s := range indexes {
+		g.sb.WriteString(s)
+	}
+	return deferred
+}
+
+// column returns the definition of a column and the checks its attributes
+// amount to.
+func (g *sqlGen) column(cd *ColumnDecl, inlinePk bool) (string, []string) {
+	name := pgIdent(cd.Name())
+	def := name + " " + g.typeName(cd.Type())
+	checks := []string{}
+	for _, a := range cd.Attrs() {
+		arg := ""
+		switch a.Name() {
+		case "default", "min", "max", "minlen", "maxlen":
+			if a.Arg() == nil {
+				g.error(a, CodeUnsupportedAttribute, "attribute '%s' needs an argument", a.Name())
+				continue
+			}
+			arg = strings.TrimSpace((*a.Arg()).Text())
+		}
+
+		switch a.Name() {
+		case "primary", "pk":
+			if inlinePk {
+				def += " PRIMARY KEY"
+			}
+		case "notnull":
+			def += " NOT NULL"
+		case "unique":
+			def += " UNIQUE"
+		case "default":
+			def += " DEFAULT " + arg
+		case "min":
+			checks = append(checks, fmt.Sprintf("CHECK (%s >= %s)", name, arg))
+		case "max":
+			checks = append(checks, fmt.Sprintf("CHECK (%s <= %s)", name, arg))
+		case "minlen":
+			checks = append(checks, fmt.Sprintf("CHECK (length(%s) >= %s)", name, arg))
+		case "maxlen":
+			checks = append(checks, fmt.Sprintf("CHECK (length(%s) <= %s)", name, arg))
+		default:
+			g.error(a, CodeUnsupportedAttribute, "unknown column attribute '%s'", a.Name())
+		}
+	}
+	return def, checks
+}
+
+func (g *sqlGen) typeName(tr *TypeRef) string {
+	if tr == nil {
+		return ""
+	}
+	res := pgTypeName(tr)
+	if res == "" {
+		g.error(tr, CodeUnsupportedType, "unknown type '%s'", tr.Name())
+		return tr.Name()
+	}
+	return res
+}
+
+// pgTypeName returns the PostgreSQL type of a Kuneiform one, or an empty
+// string if there is none.
+func pgTypeName(tr *TypeRef) string {
+	if tr == nil {
+		return ""
+	}
+	res := ""
+	switch baseTypeName(tr) {
+	case "int":
+		res = "INT8"
+	case "text":
+		res = "TEXT"
+	case "bool":
+		res = "BOOLEAN"
+	case "blob":
+		res = "BYTEA"
+	case "uuid":
+		res = "UUID"
+	case "uint256":
+		res = "NUMERIC(78, 0)"
+	case "decimal":
+		res = "NUMERIC"
+		if args := tr.Args(); len(args) > 0 {
+			res += "(" + strings.Join(args, ", ") + ")"
+		}
+	default:
+		return ""
+	}
+	if tr.IsArray() {
+		res += "[]"
+	}
+	return res
+}
+
+func (g *sqlGen) foreignKey(fk *ForeignKeyDecl) string {
+	res := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", pgIdents(fk.Columns()), g.qualified(fk.RefTable()), pgIdents(fk.RefColumns()))
+	for _, a := range fk.Actions() {
+		event, eventOk := fkEvents[a.Event()]
+		action, actionOk := fkActions[a.Action()]
+		if !eventOk || !actionOk {
+			g.error(a, CodeUnsupportedAttribute, "unknown foreign key action '%s'", strings.Join(strings.Fields(a.Text()), " "))
+			continue
+		}
+		res += " " + event + " " + action
+	}
+	return res
+}
+
+// tableOrder sorts the tables so that the ones referenced by foreign keys come
+// first, otherwise keeping the order of the declarations.
+func tableOrder(fr *FileRoot) []*TableDecl {
+	tables := fr.TableDecls()
+	res := []*TableDecl{}
+	state := map[*TableDecl]int{} // 1 while visiting, 2 when added
+	var visit func(td *TableDecl)
+	visit = func(td *TableDecl) {
+		if state[td] != 0 {
+			return
+		}
+		state[td] = 1
+		for _, fk := range td.ForeignKeys() {
+			if ref := fr.TableDecl(fk.RefTable()); ref != nil && ref != td {
+				visit(ref)
+			}
+		}
+		state[td] = 2
+		res = append(res, td)
+	}
+	for _, td := range tables {
+		visit(td)
+	}
+	return res
+}
@@ -0,0 +1,168 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateSQL(t *testing.T, text string) string {
+	res, diags := GenerateSQL(ParseFile(text))
+	require.Empty(t, diags)
+	return res
+}
+
+func TestGenerateSQL(t *testing.T) {
+	b, err := os.ReadFile("testdata/valid/tables.kf")
+	require.NoError(t, err)
+
+	assert.Equal(t, `CREATE SCHEMA IF NOT EXISTS shop;
+
+CREATE TABLE shop.users (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    age INT8 DEFAULT 0,
+    tags TEXT[],
+    wallet TEXT NOT NULL UNIQUE,
+    CHECK (length(name) >= 3),
+    CHECK (length(name) <= 50)
+);
+CREATE INDEX name_idx ON shop.users (name);
+
+CREATE TABLE shop.orders (
+    id INT8 PRIMARY KEY,
+    user_id UUID NOT NULL,
+    price NUMERIC(10, 2),
+    FOREIGN KEY (user_id) REFERENCES shop.users (id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+CREATE UNIQUE INDEX user_name ON shop.orders (user_id, price);
+`, generateSQL(t, string(b)))
+}
+
+func TestGenerateSQLTableOrder(t *testing.T) {
+	text := `database d;
+table a { id int pk, b_id int, foreign_key (b_id) references b(id) }
+table b { id int pk, a_id int, foreign_key (a_id) references a(id) }
+table c { id int pk, parent int, foreign_key (parent) references c(id) on_delete set_null }
+table d { id int pk, c_id int, foreign_key (c_id) references c(id) on_delete set_null }`
+
+	assert.Equal(t, `CREATE SCHEMA IF NOT EXISTS d;
+
+CREATE TABLE d.b (
+    id INT8 PRIMARY KEY,
+    a_id INT8
+);
+
+CREATE TABLE d.a (
+    id INT8 PRIMARY KEY,
+    b_id INT8,
+    FOREIGN KEY (b_id) REFERENCES d.b (id)
+);
+
+CREATE TABLE d.c (
+    id INT8 PRIMARY KEY,
+    parent INT8,
+    FOREIGN KEY (parent) REFERENCES d.c (id) ON DELETE SET NULL
+);
+
+CREATE TABLE d.d (
+    id INT8 PRIMARY KEY,
+    c_id INT8,
+    FOREIGN KEY (c_id) REFERENCES d.c (id) ON DELETE SET NULL
+);
+
+ALTER TABLE d.b ADD FOREIGN KEY (a_id) REFERENCES d.a (id);
+`, generateSQL(t, text))
+}
+
+func TestGenerateSQLNames(t *testing.T) {
+	text := `database Shop;
+table User { Check int, Window text, amount uint256 min(0) max(100), data blob[], ok boolean default(true), #pk primary(Check, Window) }`
+
+	assert.Equal(t, `CREATE SCHEMA IF NOT EXISTS shop;
+
+CREATE TABLE shop."user" (
+    "check" INT8,
+    "window" TEXT,
+    amount NUMERIC(78, 0),
+    data BYTEA[],
+    ok BOOLEAN DEFAULT true,
+    PRIMARY KEY ("check", "window"),
+    CHECK (amount >= 0),
+    CHECK (amount <= 100)
+);
+`, generateSQL(t, text))
+}
+
+// TestGeneratePlpgsql compares the functions generated from testdata/plpgsql/*.kf,
+// a file per construct, with the .sql files next to them. Run
+// `go test ./lang -run TestGeneratePlpgsql -update` to regenerate them.
+func TestGeneratePlpgsql(t *testing.T) {
+	files, err := filepath.Glob("testdata/plpgsql/*.kf")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, f := range files {
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			b, err := os.ReadFile(f)
+			require.NoError(t, err)
+			golden(t, strings.TrimSuffix(f, ".kf")+".sql", generateSQL(t, string(b)))
+		})
+	}
+}
+
+func TestGeneratePlpgsqlErrors(t *testing.T) {
+	sql, diags := GenerateSQL(ParseFile(`database d;
+procedure a() public returns (int) { return next 1; }
+procedure b() public returns (x int, y int) { return 1; }
+action c() public { INSERT INTO t (id) VALUES (@nonce); }
+action e() public { INSERT INTO t (id) VALUES (1); }
+table t { id int pk }`))
+	assert.Contains(t, sql, "CREATE TABLE d.t (")
+	assert.Contains(t, sql, "FUNCTION d.e(")
+	assert.NotContains(t, sql, "FUNCTION d.a(")
+	assert.NotContains(t, sql, "FUNCTION d.c(")
+	messages := []string{}
+	for _, d := range diags {
+		messages = append(messages, d.Message)
+	}
+	assert.Equal(t, []string{
+		"return next needs a procedure returning a table",
+		"procedure 'b' returns 1 value(s) here but declares 2",
+		"unknown context variable '@nonce'",
+	}, messages)
+}
+
+func TestGenerateSQLErrors(t *testing.T) {
+	_, diags := GenerateSQL(ParseFile("database d; table t { id int pk, foreign_key (id) references u(id) }"))
+	assert.Equal(t, []string{CodeUnknownTable}, diagCodes(diags))
+
+	_, diags = GenerateSQL(ParseFile("database d; table t { id int pk, at timestamp, n int sparkly, m int max, #i fulltext(n) }"))
+	messages := []string{}
+	for _, d := range diags {
+		messages = append(messages, d.Message)
+	}
+	assert.Equal(t, []string{
+		"unknown type 'timestamp'",
+		"unknown column attribute 'sparkly'",
+		"attribute 'max' needs an argument",
+		"unknown index kind 'fulltext'",
+	}, messages)
+}
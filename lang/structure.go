@@ -0,0 +1,169 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import "strings"
+
+type FoldingKind string
+
+const (
+	FoldComment FoldingKind = "comment"
+	FoldImports FoldingKind = "imports"
+	FoldRegion  FoldingKind = "region"
+)
+
+type FoldingRange struct {
+	StartLine int
+	EndLine   int
+	Kind      FoldingKind
+}
+
+type Range struct {
+	Start int
+	End   int
+}
+
+// FoldingRanges returns foldable regions of a file: bodies in braces, which fold
+// up to the line before the closing brace, SQL statements spanning several
+// lines, block comments, runs of line comments and of use directives.
+func FoldingRanges(fr *FileRoot, li *LineIndex) []FoldingRange {
+	res := []FoldingRange{}
+	add := func(start int, end int, kind FoldingKind) {
+		sl := li.Position(start).Line
+		el := li.Position(end).Line
+		if el > sl {
+			res = append(res, FoldingRange{StartLine: sl, EndLine: el, Kind: kind})
+		}
+	}
+
+	Walk(fr, func(n AstNode) bool {
+		switch n := n.(type) {
+		case *SelectStmt, *InsertStmt, *UpdateStmt, *DeleteStmt:
+			add(n.Start(), n.End(), FoldRegion)
+		case *TokNode:
+			if n.tok.kind == T_COMMENT && strings.HasPrefix(n.tok.text, "/*") {
+				add(n.Start(), n.End(), FoldComment)
+			}
+		default:
+			lb := findTok(n.Children(), T_LBRACE)
+			rb := findTok(n.Children(), T_RBRACE)
+			if lb != nil && rb != nil {
+				sl := li.Position(lb.Start()).Line
+				el := li.Position(rb.Start()).Line - 1
+				if el > sl {
+					res = append(res, FoldingRange{StartLine: sl, EndLine: el, Kind: FoldRegion})
+				}
+			}
+		}
+		return true
+	})
+
+	res = append(res, lineCommentRuns(fr, li)...)
+	res = append(res, useDirectiveRuns(fr, li)...)
+	return res
+}
+
+func lineCommentRuns(fr *FileRoot, li *LineIndex) []FoldingRange {
+	res := []FoldingRange{}
+	start, end := -1, -1
+	flush := func() {
+		if start >= 0 && end > start {
+			res = append(res, FoldingRange{StartLine: start, EndLine: end, Kind: FoldComment})
+		}
+		start, end = -1, -1
+	}
+	Walk(fr, func(n AstNode) bool {
+		t, ok := n.(*TokNode)
+		if !ok {
+			return true
+		}
+		switch {
+		case t.tok.kind == T_COMMENT && strings.HasPrefix(t.tok.text, "//"):
+			line := li.Position(t.Start()).Line
+			if start < 0 || line != end+1 || !onlyWsBefore(li, t) {
+				flush()
+				start = line
+			}
+			end = line
+		case t.tok.kind == T_WS && strings.Count(t.tok.text, "\n") <= 1:
+		default:
+			flush()
+		}
+		return true
+	})
+	flush()
+	return res
+}
+
+// onlyWsBefore tells whether a token is the first one on its line.
+func onlyWsBefore(li *LineIndex, t *TokNode) bool {
+	p := li.Position(t.Start())
+	line := li.text[li.Offset(Position{Line: p.Line}):t.Start()]
+	return strings.TrimSpace(line) == ""
+}
+
+func useDirectiveRuns(fr *FileRoot, li *LineIndex) []FoldingRange {
+	eds := fr.ExtDirectives()
+	if len(eds) < 2 {
+		return []FoldingRange{}
+	}
+	sl := li.Position(eds[0].Start()).Line
+	el := li.Position(eds[len(eds)-1].End()).Line
+	if el <= sl {
+		return []FoldingRange{}
+	}
+	return []FoldingRange{{StartLine: sl, EndLine: el, Kind: FoldImports}}
+}
+
+// SelectionRanges returns the ranges for expanding a selection from offset, from
+// the innermost token to the whole file. Nested nodes covering the same range
+// are reported once.
+func SelectionRanges(fr *FileRoot, offset int) []Range {
+	path := []AstNode{}
+	var visit func(n AstNode)
+	visit = func(n AstNode) {
+		path = append(path, n)
+		var best AstNode
+		for _, c := range n.Children() {
+			if isTriviaNode(c) {
+				continue
+			}
+			if c.Start() <= offset && offset < c.End() {
+				best = c
+				break
+			}
+			if c.End() == offset {
+				best = c
+			}
+		}
+		if best != nil {
+			visit(best)
+		}
+	}
+	visit(fr)
+
+	res := []Range{}
+	for i := len(path) - 1; i >= 0; i-- {
+		r := Range{Start: path[i].Start(), End: path[i].End()}
+		if len(res) == 0 || res[len(res)-1] != r {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+func isTriviaNode(n AstNode) bool {
+	t, ok := n.(*TokNode)
+	return ok && isTrivia(t.tok.kind)
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldingRanges(t *testing.T) {
+	text := `database d;
+use a;
+use b;
+// first
+// second
+table t {
+    a int
+}
+/*
+ block
+*/
+action x() {
+    SELECT *
+    FROM t;
+    $y = 1;
+}
+`
+	fr := ParseFile(text)
+	assert.ElementsMatch(t, []FoldingRange{
+		{StartLine: 5, EndLine: 6, Kind: FoldRegion},
+		{StartLine: 8, EndLine: 10, Kind: FoldComment},
+		{StartLine: 11, EndLine: 14, Kind: FoldRegion},
+		{StartLine: 12, EndLine: 13, Kind: FoldRegion},
+		{StartLine: 3, EndLine: 4, Kind: FoldComment},
+		{StartLine: 1, EndLine: 2, Kind: FoldImports},
+	}, FoldingRanges(fr, NewLineIndex(text)))
+}
+
+func TestFoldingIgnoresSingleLines(t *testing.T) {
+	text := "database d; table t { a int } // a\naction x() { $y = 1; } // b"
+	assert.Empty(t, FoldingRanges(ParseFile(text), NewLineIndex(text)))
+}
+
+func TestSelectionRanges(t *testing.T) {
+	text := "database d;\naction a() { $x = 1 + $y; }"
+	fr := ParseFile(text)
+
+	texts := []string{}
+	for _, r := range SelectionRanges(fr, 35) {
+		texts = append(texts, text[r.Start:r.End])
+	}
+	assert.Equal(t, []string{
+		"y",
+		"$y",
+		"1 + $y",
+		"$x = 1 + $y",
+		"action a() { $x = 1 + $y; }",
+		text,
+	}, texts)
+
+	texts = []string{}
+	for _, r := range SelectionRanges(fr, 30) {
+		texts = append(texts, text[r.Start:r.End])
+	}
+	assert.Equal(t, "1", texts[0])
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import "strings"
+
+// editDistance is the case insensitive Levenshtein distance which also counts
+// a transposition of adjacent characters as a single edit.
+func editDistance(a string, b string) int {
+	ar := []rune(strings.ToLower(a))
+	br := []rune(strings.ToLower(b))
+	d := make([][]int, len(ar)+1)
+	for i := range d {
+		d[i] = make([]int, len(br)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ar[i-1] == br[j-2] && ar[i-2] == br[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ar)][len(br)]
+}
+
+// closest returns the candidate most similar to name, or an empty string if none
+// is similar enough to be a likely misspelling.
+func closest(name string, candidates []string) string {
+	best := ""
+	bestDist := max(1, min(2, len(name)/3))
+	for _, c := range candidates {
+		if strings.EqualFold(c, name) {
+			continue
+		}
+		d := editDistance(name, c)
+		if d >= len(name) {
+			continue
+		}
+		if d < bestDist || d == bestDist && best == "" {
+			best = c
+			bestDist = d
+		}
+	}
+	return best
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"strings"
+	"unicode"
+)
+
+type SymbolKind int
+
+const (
+	SymDatabase SymbolKind = iota
+	SymExtension
+	SymTable
+	SymColumn
+	SymIndex
+	SymAction
+	SymParameter
+)
+
+// Symbol is a declaration in a file. Range covers the whole declaration and
+// NameRange only its name, which is what editors select when navigating to it.
+type Symbol struct {
+	Name      string
+	Detail    string
+	Kind      SymbolKind
+	Range     Range
+	NameRange Range
+	Children  []Symbol
+}
+
+func newSymbol(n AstNode, name string, kind SymbolKind) Symbol {
+	nn := nameNode(n)
+	return Symbol{
+		Name:      name,
+		Kind:      kind,
+		Range:     Range{Start: n.Start(), End: n.End()},
+		NameRange: Range{Start: nn.Start(), End: nn.End()},
+	}
+}
+
+// Symbols returns the declarations of a file with tables and actions containing
+// their columns, indexes and parameters. Declarations without a name are skipped.
+func Symbols(fr *FileRoot) []Symbol {
+	res := []Symbol{}
+	if dd := fr.DbDirective(); dd != nil && dd.Name() != "" {
+		res = append(res, newSymbol(dd, dd.Name(), SymDatabase))
+	}
+	for _, ed := range fr.ExtDirectives() {
+		if ed.Name() == "" {
+			continue
+		}
+		s := newSymbol(ed, ed.Name(), SymExtension)
+		if ed.Alias() != "" {
+			s.Detail = "as " + ed.Alias()
+		}
+		res = append(res, s)
+	}
+	for _, td := range fr.TableDecls() {
+		if td.Name() == "" {
+			continue
+		}
+		s := newSymbol(td, td.Name(), SymTable)
+		for _, cd := range td.Columns() {
+			if cd.Name() == "" {
+				continue
+			}
+			cs := newSymbol(cd, cd.Name(), SymColumn)
+			if cd.Type() != nil {
+				cs.Detail = cd.Type().Text()
+			}
+			s.Children = append(s.Children, cs)
+		}
+		for _, id := range td.Indexes() {
+			if id.Name() == "" {
+				continue
+			}
+			is := newSymbol(id, id.Name(), SymIndex)
+			is.Detail = id.Kind() + "(" + strings.Join(id.Columns(), ", ") + ")"
+			s.Children = append(s.Children, is)
+		}
+		res = append(res, s)
+	}
+	for _, ad := range fr.ActionDecls() {
+		if ad.Name() == "" {
+			continue
+		}
+		s := newSymbol(ad, ad.Name(), SymAction)
+		mods := []string{}
+		for _, m := range ad.Modifiers() {
+			mods = append(mods, string(m))
+		}
+		s.Detail = strings.Join(mods, " ")
+		for _, pd := range ad.Params() {
+			if pd.Name() != "$" {
+				s.Children = append(s.Children, newSymbol(pd, pd.Name(), SymParameter))
+			}
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// FuzzyMatch reports whether all characters of the query appear in the name in
+// the same order, ignoring case. Higher scores mean better matches: consecutive
+// characters and ones at the start of the words count more, the start of the
+// name the most.
+func FuzzyMatch(query string, name string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	n := []rune(name)
+	score := 0
+	qi := 0
+	prev := -2
+	for i := 0; i < len(n) && qi < len(q); i++ {
+		if unicode.ToLower(n[i]) != q[qi] {
+			continue
+		}
+		score++
+		switch {
+		case i == 0:
+			score += 3
+		case i == prev+1:
+			score += 2
+		case !unicode.IsLetter(n[i-1]) || unicode.IsUpper(n[i]) && unicode.IsLower(n[i-1]):
+			score += 2
+		}
+		prev = i
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	if strings.EqualFold(query, name) {
+		score += 10
+	}
+	return score, true
+}
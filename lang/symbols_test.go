@@ -0,0 +1,70 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package lang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbols(t *testing.T) {
+	text := `database shop;
+use math as m;
+table users {
+	id int primary,
+	#by_id unique(id)
+}
+action get($id) public view { SELECT id FROM users WHERE id = $id; }
+action`
+	ss := Symbols(ParseFile(text))
+
+	names := []string{}
+	for _, s := range ss {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"shop", "math", "users", "get"}, names)
+	assert.Equal(t, "as m", ss[1].Detail)
+
+	users := ss[2]
+	assert.Equal(t, SymTable, users.Kind)
+	assert.Equal(t, "users", text[users.NameRange.Start:users.NameRange.End])
+	assert.Equal(t, "}", text[users.Range.End-1:users.Range.End])
+	assert.Equal(t, []Symbol{
+		{Name: "id", Detail: "int", Kind: SymColumn, Range: Range{45, 59}, NameRange: Range{45, 47}},
+		{Name: "by_id", Detail: "unique(id)", Kind: SymIndex, Range: Range{62, 79}, NameRange: Range{63, 68}},
+	}, users.Children)
+
+	get := ss[3]
+	assert.Equal(t, "public view", get.Detail)
+	assert.Equal(t, 1, len(get.Children))
+	assert.Equal(t, "$id", get.Children[0].Name)
+	assert.Equal(t, SymParameter, get.Children[0].Kind)
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	_, ok := FuzzyMatch("usr", "users")
+	assert.True(t, ok)
+	_, ok = FuzzyMatch("rsu", "users")
+	assert.False(t, ok)
+	_, ok = FuzzyMatch("", "users")
+	assert.True(t, ok)
+
+	exact, _ := FuzzyMatch("users", "users")
+	prefix, _ := FuzzyMatch("us", "users")
+	words, _ := FuzzyMatch("gu", "get_user")
+	scattered, _ := FuzzyMatch("gu", "get_bug")
+	assert.Greater(t, exact, prefix)
+	assert.Greater(t, words, scattered)
+}
@@ -0,0 +1,44 @@
+CREATE SCHEMA IF NOT EXISTS shop;
+
+CREATE TABLE shop.users (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    age INT8 DEFAULT 0,
+    wallet TEXT
+);
+
+CREATE OR REPLACE FUNCTION shop.add_user(ctx_caller TEXT, _id UUID, _name TEXT, _age INT8)
+RETURNS VOID
+LANGUAGE plpgsql AS $$
+BEGIN
+    INSERT INTO shop.users (id, name, age, wallet) VALUES (_id, _name, _age, ctx_caller);
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION shop.rename(ctx_caller TEXT, _id UUID, _name TEXT)
+RETURNS TABLE(id UUID)
+LANGUAGE plpgsql AS $$
+#variable_conflict use_column
+BEGIN
+    RETURN QUERY SELECT id FROM shop.users WHERE id = _id AND wallet = ctx_caller;
+    UPDATE shop.users SET name = _name WHERE id = _id;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION shop.get_users(_min_age INT8)
+RETURNS TABLE(id UUID, name TEXT, age INT8, wallet TEXT)
+LANGUAGE plpgsql STABLE AS $$
+#variable_conflict use_column
+BEGIN
+    RETURN QUERY SELECT * FROM shop.users WHERE age >= _min_age ORDER BY name;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION shop.count_users()
+RETURNS TABLE(count INT8, oldest INT8)
+LANGUAGE plpgsql STABLE AS $$
+#variable_conflict use_column
+BEGIN
+    RETURN QUERY SELECT count(*), max(age) AS oldest FROM shop.users;
+END;
+$$;
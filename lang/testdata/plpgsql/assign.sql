@@ -0,0 +1,26 @@
+CREATE SCHEMA IF NOT EXISTS shop;
+
+CREATE TABLE shop.users (
+    id INT8 PRIMARY KEY,
+    name TEXT
+);
+
+CREATE OR REPLACE FUNCTION shop.greet(_name TEXT)
+RETURNS TEXT
+LANGUAGE plpgsql STABLE AS $$
+DECLARE
+    _greeting TEXT;
+    _count INT8;
+    _shout BOOLEAN;
+    _message TEXT;
+BEGIN
+    _greeting := 'hello, ';
+    _count := 1;
+    _shout := true;
+    _message := _greeting || _name;
+    IF _shout THEN
+        _message := upper(_message);
+    END IF;
+    RETURN _message;
+END;
+$$;
@@ -0,0 +1,40 @@
+CREATE SCHEMA IF NOT EXISTS shop;
+
+CREATE TABLE shop.users (
+    id INT8 PRIMARY KEY,
+    wallet TEXT
+);
+
+CREATE OR REPLACE FUNCTION shop.check_owner(ctx_caller TEXT, _id INT8)
+RETURNS VOID
+LANGUAGE plpgsql STABLE AS $$
+DECLARE
+    _row RECORD;
+BEGIN
+    FOR _row IN SELECT wallet FROM shop.users WHERE id = _id LOOP
+        IF _row.wallet != ctx_caller THEN
+            RAISE EXCEPTION '%', 'not the owner of ' || _id;
+        END IF;
+    END LOOP;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION shop.remove(ctx_caller TEXT, _id INT8)
+RETURNS VOID
+LANGUAGE plpgsql AS $$
+BEGIN
+    PERFORM shop.check_owner(ctx_caller, _id);
+    DELETE FROM shop.users WHERE id = _id;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION shop.remove_all(ctx_caller TEXT, ctx_txid TEXT, ctx_height INT8, _first INT8, _last INT8)
+RETURNS VOID
+LANGUAGE plpgsql AS $$
+BEGIN
+    FOR _i IN _first.._last LOOP
+        PERFORM shop.remove(ctx_caller, _i);
+    END LOOP;
+    INSERT INTO shop.users (id, wallet) VALUES (ctx_height, ctx_txid);
+END;
+$$;
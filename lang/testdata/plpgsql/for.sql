@@ -0,0 +1,51 @@
+CREATE SCHEMA IF NOT EXISTS shop;
+
+CREATE TABLE shop.users (
+    id INT8 PRIMARY KEY,
+    name TEXT,
+    age INT8
+);
+
+CREATE OR REPLACE FUNCTION shop.total_age()
+RETURNS INT8
+LANGUAGE plpgsql STABLE AS $$
+DECLARE
+    _total INT8;
+    _row RECORD;
+BEGIN
+    _total := 0;
+    FOR _row IN SELECT age FROM shop.users WHERE age IS NOT NULL LOOP
+        _total := _total + _row.age;
+    END LOOP;
+    RETURN _total;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION shop.sum_to(_n INT8)
+RETURNS INT8
+LANGUAGE plpgsql STABLE AS $$
+DECLARE
+    _sum INT8;
+BEGIN
+    _sum := 0;
+    FOR _i IN 1.._n LOOP
+        _sum := _sum + _i;
+    END LOOP;
+    RETURN _sum;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION shop.join_names(_names TEXT[])
+RETURNS TEXT
+LANGUAGE plpgsql STABLE AS $$
+DECLARE
+    _res TEXT;
+    _name TEXT;
+BEGIN
+    _res := '';
+    FOREACH _name IN ARRAY _names LOOP
+        _res := _res || _name;
+    END LOOP;
+    RETURN _res;
+END;
+$$;
@@ -0,0 +1,25 @@
+CREATE SCHEMA IF NOT EXISTS shop;
+
+CREATE TABLE shop.users (
+    id INT8 PRIMARY KEY,
+    age INT8
+);
+
+CREATE OR REPLACE FUNCTION shop.classify(_age INT8)
+RETURNS TEXT
+LANGUAGE plpgsql STABLE AS $$
+BEGIN
+    IF _age < 0 THEN
+        RAISE EXCEPTION '%', 'age must not be negative';
+    ELSIF _age < 18 THEN
+        RETURN 'minor';
+    ELSIF _age = 18 THEN
+        RETURN 'adult';
+    ELSE
+        IF _age > 120 AND NOT _age IS NULL THEN
+            RAISE EXCEPTION '%', 'age is too high: ' || _age;
+        END IF;
+    END IF;
+    RETURN 'adult';
+END;
+$$;
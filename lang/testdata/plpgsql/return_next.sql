@@ -0,0 +1,34 @@
+CREATE SCHEMA IF NOT EXISTS shop;
+
+CREATE TABLE shop.users (
+    id INT8 PRIMARY KEY,
+    name TEXT,
+    age INT8
+);
+
+CREATE OR REPLACE FUNCTION shop.adults()
+RETURNS TABLE(id INT8, name TEXT)
+LANGUAGE plpgsql STABLE AS $$
+#variable_conflict use_column
+DECLARE
+    _u RECORD;
+BEGIN
+    FOR _u IN SELECT id, name, age FROM shop.users LOOP
+        IF _u.age >= 18 THEN
+            id := _u.id;
+            name := _u.name;
+            RETURN NEXT;
+        END IF;
+    END LOOP;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION shop.by_name(_name TEXT)
+RETURNS TABLE(id INT8, age INT8)
+LANGUAGE plpgsql STABLE AS $$
+#variable_conflict use_column
+BEGIN
+    RETURN QUERY SELECT id, age FROM shop.users WHERE name = _name;
+    RETURN;
+END;
+$$;
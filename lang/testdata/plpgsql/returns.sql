@@ -0,0 +1,47 @@
+CREATE SCHEMA IF NOT EXISTS shop;
+
+CREATE TABLE shop.users (
+    id INT8 PRIMARY KEY,
+    name TEXT,
+    age INT8
+);
+
+CREATE OR REPLACE FUNCTION shop.user_count()
+RETURNS INT8
+LANGUAGE plpgsql STABLE AS $$
+BEGIN
+    RETURN (SELECT count(*) FROM shop.users);
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION shop.stats(OUT users INT8, OUT oldest INT8)
+RETURNS RECORD
+LANGUAGE plpgsql STABLE AS $$
+#variable_conflict use_column
+DECLARE
+    _n INT8;
+    _max INT8;
+    _row RECORD;
+BEGIN
+    _n := 0;
+    _max := 0;
+    FOR _row IN SELECT age FROM shop.users LOOP
+        _n := _n + 1;
+        IF _row.age > _max THEN
+            _max := _row.age;
+        END IF;
+    END LOOP;
+    users := _n;
+    oldest := _max;
+    RETURN;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION shop.touch(_id INT8)
+RETURNS VOID
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE shop.users SET age = age + 1 WHERE id = _id;
+    RETURN;
+END;
+$$;
@@ -23,6 +23,7 @@ type TokKind string
 
 const (
 	T_COLON     TokKind = ":"
+	T_DECLARE   TokKind = ":="
 	T_SEMICOLON TokKind = ";"
 	T_LPAREN    TokKind = "("
 	T_RPAREN    TokKind = ")"
@@ -33,6 +34,7 @@ const (
 	T_HASH      TokKind = "#"
 	T_AT        TokKind = "@"
 	T_DOT       TokKind = "."
+	T_RANGE     TokKind = ".."
 	T_ASSIGN    TokKind = "="
 	T_PLUS      TokKind = "+"
 	T_MINUS     TokKind = "-"
@@ -52,21 +54,100 @@ const (
 	T_GT_EQ     TokKind = ">="
 	T_NOT_EQ    TokKind = "!="
 	T_NEQ       TokKind = "<>"
+	T_LBRACKET  TokKind = "["
+	T_RBRACKET  TokKind = "]"
 
 	T_ID      TokKind = "id"
 	T_WS      TokKind = "ws"
 	T_COMMENT TokKind = "comment"
 	T_NUM     TokKind = "num"
+	T_STRING  TokKind = "string"
 
-	T_DATABASE TokKind = "database"
-	T_USE      TokKind = "use"
-	T_TABLE    TokKind = "table"
-	T_ACTION   TokKind = "action"
+	T_DATABASE   TokKind = "database"
+	T_USE        TokKind = "use"
+	T_TABLE      TokKind = "table"
+	T_ACTION     TokKind = "action"
+	T_PUBLIC     TokKind = "public"
+	T_PRIVATE    TokKind = "private"
+	T_VIEW       TokKind = "view"
+	T_OWNER      TokKind = "owner"
+	T_REFERENCES TokKind = "references"
+	T_RETURN     TokKind = "return"
+	T_PROCEDURE  TokKind = "procedure"
+	T_RETURNS    TokKind = "returns"
+	T_IF         TokKind = "if"
+	T_ELSEIF     TokKind = "elseif"
+	T_ELSE       TokKind = "else"
+	T_FOR        TokKind = "for"
+	T_IN         TokKind = "in"
+	T_NEXT       TokKind = "next"
+
+	T_SELECT   TokKind = "select"
+	T_INSERT   TokKind = "insert"
+	T_INTO     TokKind = "into"
+	T_VALUES   TokKind = "values"
+	T_UPDATE   TokKind = "update"
+	T_SET      TokKind = "set"
+	T_DELETE   TokKind = "delete"
+	T_FROM     TokKind = "from"
+	T_WHERE    TokKind = "where"
+	T_JOIN     TokKind = "join"
+	T_INNER    TokKind = "inner"
+	T_LEFT     TokKind = "left"
+	T_ON       TokKind = "on"
+	T_AS       TokKind = "as"
+	T_CONFLICT TokKind = "conflict"
+	T_DO       TokKind = "do"
+	T_NOTHING  TokKind = "nothing"
+	T_ORDER    TokKind = "order"
+	T_BY       TokKind = "by"
+	T_ASC      TokKind = "asc"
+	T_DESC     TokKind = "desc"
+	T_LIMIT    TokKind = "limit"
+	T_KW_AND   TokKind = "and"
+	T_KW_OR    TokKind = "or"
+	T_NOT      TokKind = "not"
+	T_IS       TokKind = "is"
+	T_NULL     TokKind = "null"
+	T_TRUE     TokKind = "true"
+	T_FALSE    TokKind = "false"
 
 	T_ERROR TokKind = "error"
 	T_NONE  TokKind = "none"
 )
 
+var keywords = map[string]TokKind{}
+
+func init() {
+	for _, k := range []TokKind{
+		T_DATABASE, T_USE, T_TABLE, T_ACTION, T_PUBLIC, T_PRIVATE, T_VIEW, T_OWNER, T_REFERENCES, T_RETURN,
+		T_SELECT, T_INSERT, T_INTO, T_VALUES, T_UPDATE, T_SET, T_DELETE, T_FROM, T_WHERE,
+		T_JOIN, T_INNER, T_LEFT, T_ON, T_AS, T_CONFLICT, T_DO, T_NOTHING, T_ORDER, T_BY,
+		T_ASC, T_DESC, T_LIMIT, T_KW_AND, T_KW_OR, T_NOT, T_IS, T_NULL, T_TRUE, T_FALSE,
+	} {
+		keywords[string(k)] = k
+	}
+}
+
+// contextualKeywords are lexed as identifiers, and the parser makes them
+// keywords only where the grammar expects them, so that schemas written before
+// procedures existed can keep using these words as names.
+var contextualKeywords = map[TokKind]bool{
+	T_PROCEDURE: true, T_RETURNS: true, T_IF: true, T_ELSEIF: true, T_ELSE: true, T_FOR: true,
+	T_IN: true, T_NEXT: true,
+}
+
+// unreservedKeywords may be used as bare column names in expressions.
+var unreservedKeywords = map[TokKind]bool{
+	T_DATABASE: true, T_USE: true, T_PUBLIC: true, T_PRIVATE: true, T_VIEW: true, T_OWNER: true,
+	T_REFERENCES: true, T_CONFLICT: true, T_DO: true, T_NOTHING: true, T_BY: true, T_SET: true,
+	T_VALUES: true,
+}
+
+func isKeyword(k TokKind) bool {
+	return keywords[string(k)] == k || contextualKeywords[k]
+}
+
 type Token struct {
 	start int
 	end   int
@@ -115,10 +196,28 @@ func tokenize(text string) []Token {
 
 		switch r {
 
-		case ':', ';', '(', ')', '{', '}', ',', '.', '$', '#', '@', '+', '-', '*', '%', '~', '&':
+		case ';', '(', ')', '{', '}', '[', ']', ',', '$', '#', '@', '+', '-', '*', '%', '~', '&':
 			advance()
 			finish(TokKind(string(r)))
 
+		case ':':
+			advance()
+			if curRune() == '=' {
+				advance()
+				finish(T_DECLARE)
+			} else {
+				finish(T_COLON)
+			}
+
+		case '.':
+			advance()
+			if curRune() == '.' {
+				advance()
+				finish(T_RANGE)
+			} else {
+				finish(T_DOT)
+			}
+
 		case '/':
 			advance()
 			if curRune() == '/' {
@@ -173,6 +272,24 @@ func tokenize(text string) []Token {
 			if curRune() == '=' {
 				advance()
 				finish(T_NOT_EQ)
+			} else {
+				finish(T_ERROR)
+			}
+		case '\'':
+			advance()
+			for {
+				if curRune() == utf8.RuneError {
+					finish(T_STRING)
+					break
+				}
+				if curRune() == '\'' {
+					advance()
+					if curRune() != '\'' {
+						finish(T_STRING)
+						break
+					}
+				}
+				advance()
 			}
 		case '<':
 			advance()
@@ -192,12 +309,11 @@ func tokenize(text string) []Token {
 			advance()
 			if curRune() == '=' {
 				advance()
-				finish(T_LESS_EQ)
+				finish(T_GT_EQ)
 			} else if curRune() == '>' {
 				advance()
 				finish(T_RSHIFT)
 			} else {
-				advance()
 				finish(T_GT)
 			}
 		default:
@@ -207,17 +323,10 @@ func tokenize(text string) []Token {
 					advance()
 				}
 
-				tokText := tokText()
-				switch strings.ToLower(tokText) {
-				case "database":
-					finish(T_DATABASE)
-				case "use":
-					finish(T_USE)
-				case "table":
-					finish(T_TABLE)
-				case "action":
-					finish(T_ACTION)
-				default:
+				kw, ok := keywords[strings.ToLower(tokText())]
+				if ok {
+					finish(kw)
+				} else {
 					finish(T_ID)
 				}
 			} else if unicode.IsDigit(r) {
@@ -171,3 +171,33 @@ func TestIncompleteMultiLineComment(t *testing.T) {
 		},
 	}, toks)
 }
+
+func TestKeywordsAndStrings(t *testing.T) {
+	toks := tokenize("SELECT 'a''b' >= x")
+
+	kinds := []TokKind{}
+	for _, t := range toks {
+		kinds = append(kinds, t.kind)
+	}
+	assert.Equal(t, []TokKind{T_SELECT, T_WS, T_STRING, T_WS, T_GT_EQ, T_WS, T_ID}, kinds)
+	assert.Equal(t, "'a''b'", toks[2].text)
+}
+
+func TestGreater(t *testing.T) {
+	toks := tokenize(">1")
+
+	assert.Equal(t, []Token{
+		{
+			start: 0,
+			end:   1,
+			text:  ">",
+			kind:  T_GT,
+		},
+		{
+			start: 1,
+			end:   2,
+			text:  "1",
+			kind:  T_NUM,
+		},
+	}, toks)
+}
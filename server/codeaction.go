@@ -0,0 +1,55 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"github.com/sourcegraph/go-lsp"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+type codeAction struct {
+	Title       string            `json:"title"`
+	Kind        string            `json:"kind,omitempty"`
+	Diagnostics []lsp.Diagnostic  `json:"diagnostics,omitempty"`
+	IsPreferred bool              `json:"isPreferred,omitempty"`
+	Edit        lsp.WorkspaceEdit `json:"edit"`
+}
+
+// codeActions returns the quick fixes of the diagnostics ds intersecting the range.
+// The caller passes the server's own diagnostics of the document rather than the
+// ones in the request, since only those have fixes.
+func codeActions(uri lsp.DocumentURI, text string, ds []lang.Diagnostic, r lsp.Range) []codeAction {
+	li := lang.NewLineIndex(text)
+	start := li.Offset(fromPosition(r.Start))
+	end := li.Offset(fromPosition(r.End))
+
+	res := []codeAction{}
+	for _, d := range ds {
+		if d.End < start || d.Start > end {
+			continue
+		}
+		for _, f := range d.Fixes {
+			res = append(res, codeAction{
+				Title:       f.Title,
+				Kind:        "quickfix",
+				Diagnostics: []lsp.Diagnostic{toDiagnostic(li, d)},
+				IsPreferred: len(d.Fixes) == 1,
+				Edit: lsp.WorkspaceEdit{
+					Changes: map[string][]lsp.TextEdit{string(uri): toTextEdits(li, f.Edits)},
+				},
+			})
+		}
+	}
+	return res
+}
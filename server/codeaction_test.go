@@ -0,0 +1,43 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func TestCodeActions(t *testing.T) {
+	text := "database d;\naction a() {\n  SELECT $x;\n}"
+	r := lsp.Range{Start: lsp.Position{Line: 2, Character: 9}, End: lsp.Position{Line: 2, Character: 9}}
+
+	ds := diagnostics(lang.ParseFile(text), config{})
+	res := codeActions("file:///a.kf", text, ds, r)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "Declare '$x' as a parameter of 'a'", res[0].Title)
+	assert.Equal(t, "quickfix", res[0].Kind)
+	assert.True(t, res[0].IsPreferred)
+	assert.Equal(t, "undefined-variable", res[0].Diagnostics[0].Code)
+	assert.Equal(t, map[string][]lsp.TextEdit{
+		"file:///a.kf": {{
+			Range:   lsp.Range{Start: lsp.Position{Line: 1, Character: 9}, End: lsp.Position{Line: 1, Character: 9}},
+			NewText: "$x",
+		}},
+	}, res[0].Edit.Changes)
+
+	assert.Empty(t, codeActions("file:///a.kf", text, ds, lsp.Range{}))
+}
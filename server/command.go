@@ -0,0 +1,98 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+// commandGenerateSQL returns the PostgreSQL DDL of the document whose URI is
+// the only argument. The extension shows it beside the source.
+const commandGenerateSQL = "kuneiform.generateSql"
+
+var commands = []string{commandGenerateSQL}
+
+type executeCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments"`
+}
+
+// documentURI returns the URI of the document a command is about.
+func (p executeCommandParams) documentURI() (lsp.DocumentURI, bool) {
+	uri := lsp.DocumentURI("")
+	if p.Command != commandGenerateSQL || len(p.Arguments) != 1 || json.Unmarshal(p.Arguments[0], &uri) != nil {
+		return "", false
+	}
+	return uri, true
+}
+
+// executeCommand runs a command. sn is the snapshot of the document the command
+// is about if it's open.
+func (l *lspHandler) executeCommand(params executeCommandParams, sn *snapshot) (any, error) {
+	switch params.Command {
+	case commandGenerateSQL:
+		uri, ok := params.documentURI()
+		if !ok {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "expected the document URI as the only argument"}
+		}
+		text, err := documentText(uri, sn)
+		if err != nil {
+			return nil, &jsonrpc2.Error{Code: codeRequestFailed, Message: err.Error()}
+		}
+		sql, diags := lang.GenerateSQL(lang.ParseFile(text))
+		li := lang.NewLineIndex(text)
+		if sql == "" && len(diags) > 0 {
+			pos := li.Position(diags[0].Start)
+			return nil, &jsonrpc2.Error{
+				Code:    codeRequestFailed,
+				Message: fmt.Sprintf("can't generate SQL, the schema has errors: %d:%d: %s", pos.Line+1, pos.Character+1, diags[0].Message),
+			}
+		}
+		// The actions and procedures which can't be translated are left out,
+		// which the comments at the top tell.
+		header := ""
+		for _, d := range diags {
+			pos := li.Position(d.Start)
+			header += fmt.Sprintf("-- %d:%d: %s\n", pos.Line+1, pos.Character+1, d.Message)
+		}
+		if header != "" {
+			sql = header + "\n" + sql
+		}
+		return sql, nil
+	}
+	return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("unknown command %q", params.Command)}
+}
+
+// documentText returns the text of the snapshot of an open document, or reads
+// the document from disk if sn is nil.
+func documentText(uri lsp.DocumentURI, sn *snapshot) (string, error) {
+	if sn != nil {
+		return sn.text, nil
+	}
+	path, ok := uriToPath(uri)
+	if !ok {
+		return "", fmt.Errorf("unsupported URI %s", uri)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,221 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+// configSection is the section of the client settings the server reads.
+const configSection = "kuneiform"
+
+const defaultMaxDiagnostics = 1000
+
+// settings are the client settings in the "kuneiform" section. Everything is
+// optional.
+type settings struct {
+	Lint   json.RawMessage `json:"lint"`
+	Format struct {
+		IndentWidth int    `json:"indentWidth"`
+		KeywordCase string `json:"keywordCase"`
+	} `json:"format"`
+	Dialect        string   `json:"dialect"`
+	IncludePaths   []string `json:"includePaths"`
+	MaxDiagnostics int      `json:"maxDiagnostics"`
+}
+
+// config is what the settings and the workspace kflint.json amount to.
+type config struct {
+	lint lang.LintConfig
+	// indentWidth overrides the tab size of the editor if it's positive.
+	indentWidth    int
+	keywordCase    lang.KeywordCase
+	dialect        lang.Dialect
+	includePaths   []string
+	maxDiagnostics int
+}
+
+func defaultConfig(fileLint lang.LintConfig) config {
+	return config{lint: fileLint, dialect: lang.DefaultDialect, maxDiagnostics: defaultMaxDiagnostics}
+}
+
+var keywordCases = map[string]lang.KeywordCase{
+	"upper":    lang.KeywordUpper,
+	"lower":    lang.KeywordLower,
+	"preserve": lang.KeywordPreserve,
+}
+
+// resolve applies the settings on top of the workspace lint configuration.
+// Invalid settings are ignored and reported as problems, so that a typo
+// doesn't disable the server. Relative include paths are relative to root.
+func (s *settings) resolve(fileLint lang.LintConfig, root string) (config, []error) {
+	cfg := defaultConfig(fileLint)
+	problems := []error{}
+
+	if len(s.Lint) > 0 && string(s.Lint) != "null" {
+		lint, err := lang.ParseLintConfig(s.Lint)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s.lint: %w", configSection, err))
+		} else {
+			cfg.lint = mergeLintConfigs(fileLint, lint)
+		}
+	}
+
+	switch w := s.Format.IndentWidth; {
+	case w > 0 && w <= 16:
+		cfg.indentWidth = w
+	case w != 0:
+		problems = append(problems, fmt.Errorf("%s.format.indentWidth: %d isn't between 1 and 16", configSection, w))
+	}
+
+	if s.Format.KeywordCase != "" {
+		kc, ok := keywordCases[s.Format.KeywordCase]
+		if ok {
+			cfg.keywordCase = kc
+		} else {
+			problems = append(problems, fmt.Errorf("%s.format.keywordCase: unknown case %q", configSection, s.Format.KeywordCase))
+		}
+	}
+
+	if s.Dialect != "" {
+		if d := lang.Dialect(s.Dialect); slices.Contains(lang.Dialects, d) {
+			cfg.dialect = d
+		} else {
+			problems = append(problems, fmt.Errorf("%s.dialect: unknown dialect %q", configSection, s.Dialect))
+		}
+	}
+
+	for _, p := range s.IncludePaths {
+		if !filepath.IsAbs(p) {
+			if root == "" {
+				problems = append(problems, fmt.Errorf("%s.includePaths: %q is relative but there is no workspace folder", configSection, p))
+				continue
+			}
+			p = filepath.Join(root, p)
+		}
+		cfg.includePaths = append(cfg.includePaths, filepath.Clean(p))
+	}
+
+	switch m := s.MaxDiagnostics; {
+	case m > 0:
+		cfg.maxDiagnostics = m
+	case m < 0:
+		problems = append(problems, fmt.Errorf("%s.maxDiagnostics: %d is negative", configSection, m))
+	}
+	return cfg, problems
+}
+
+// mergeLintConfigs returns the rules of base overridden by those of over.
+func mergeLintConfigs(base lang.LintConfig, over lang.LintConfig) lang.LintConfig {
+	res := lang.LintConfig{Rules: map[string]bool{}}
+	for name, on := range base.Rules {
+		res.Rules[name] = on
+	}
+	for name, on := range over.Rules {
+		res.Rules[name] = on
+	}
+	return res
+}
+
+// formatOptions combines the options of a formatting request with the settings.
+func (c config) formatOptions(o lsp.FormattingOptions) lang.FormatOptions {
+	res := lang.DefaultFormatOptions
+	if o.TabSize > 0 {
+		res.TabSize = o.TabSize
+		res.InsertSpaces = o.InsertSpaces
+	}
+	if c.indentWidth > 0 {
+		res.TabSize = c.indentWidth
+	}
+	res.KeywordCase = c.keywordCase
+	return res
+}
+
+type configurationItem struct {
+	ScopeURI lsp.DocumentURI `json:"scopeUri,omitempty"`
+	Section  string          `json:"section,omitempty"`
+}
+
+type configurationParams struct {
+	Items []configurationItem `json:"items"`
+}
+
+// didChangeConfigurationParams has the settings the client pushes, if any,
+// under their section.
+type didChangeConfigurationParams struct {
+	Settings struct {
+		Kuneiform *settings `json:"kuneiform"`
+	} `json:"settings"`
+}
+
+func (l *lspHandler) currentConfig() config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.config
+}
+
+// pullSettings asks the client for the settings and applies them. It has to
+// run on its own goroutine since the response arrives on the connection's one.
+func (l *lspHandler) pullSettings(conn *jsonrpc2.Conn) {
+	defer l.recoverPanic("workspace/configuration")
+	res := []json.RawMessage{}
+	params := configurationParams{Items: []configurationItem{{Section: configSection}}}
+	if err := conn.Call(context.Background(), "workspace/configuration", params, &res); err != nil {
+		l.logger.Warn("can't get the settings", "err", err)
+		return
+	}
+	s := &settings{}
+	if len(res) > 0 {
+		if err := json.Unmarshal(res[0], s); err != nil {
+			l.logger.Warn("invalid settings", "err", err)
+			return
+		}
+	}
+	l.applySettings(conn, s)
+}
+
+// applySettings switches to the configuration of the settings and re-analyses
+// the open documents. Include paths are indexed the first time they appear;
+// those dropped from the settings stay indexed until the server restarts.
+func (l *lspHandler) applySettings(conn *jsonrpc2.Conn, s *settings) {
+	l.mu.Lock()
+	cfg, problems := s.resolve(l.fileLint, l.root)
+	l.config = cfg
+	added := []string{}
+	for _, p := range cfg.includePaths {
+		if !l.included[p] {
+			l.included[p] = true
+			added = append(added, p)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, err := range problems {
+		l.logger.Warn("invalid setting", "err", err)
+	}
+	for _, p := range added {
+		go l.index.addFolder(pathToURI(p))
+	}
+	for _, sn := range l.docs.all() {
+		l.scheduleDiagnostics(conn, sn, 0)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func TestResolveSettings(t *testing.T) {
+	fileLint := lang.LintConfig{Rules: map[string]bool{"select-star": false, "missing-view": false}}
+
+	s := &settings{}
+	cfg, problems := s.resolve(fileLint, "/ws")
+	assert.Empty(t, problems)
+	assert.Equal(t, defaultConfig(fileLint), cfg)
+
+	s = &settings{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"lint": {"rules": {"missing-view": true, "no-primary-key": false}},
+		"format": {"indentWidth": 2, "keywordCase": "lower"},
+		"dialect": "kwil-0.7",
+		"includePaths": ["shared", "/opt/schemas"],
+		"maxDiagnostics": 10
+	}`), s))
+	cfg, problems = s.resolve(fileLint, "/ws")
+	assert.Empty(t, problems)
+	assert.Equal(t, config{
+		lint:           lang.LintConfig{Rules: map[string]bool{"select-star": false, "missing-view": true, "no-primary-key": false}},
+		indentWidth:    2,
+		keywordCase:    lang.KeywordLower,
+		dialect:        lang.DialectKwil07,
+		includePaths:   []string{filepath.Join("/ws", "shared"), "/opt/schemas"},
+		maxDiagnostics: 10,
+	}, cfg)
+
+	s = &settings{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"lint": {"rules": {"no-such-rule": false}},
+		"format": {"indentWidth": -1, "keywordCase": "title"},
+		"dialect": "sqlite",
+		"includePaths": ["shared"],
+		"maxDiagnostics": -5
+	}`), s))
+	cfg, problems = s.resolve(fileLint, "")
+	assert.Equal(t, defaultConfig(fileLint), cfg)
+	messages := []string{}
+	for _, p := range problems {
+		messages = append(messages, p.Error())
+	}
+	assert.Equal(t, []string{
+		`kuneiform.lint: unknown lint rule "no-such-rule"`,
+		`kuneiform.format.indentWidth: -1 isn't between 1 and 16`,
+		`kuneiform.format.keywordCase: unknown case "title"`,
+		`kuneiform.dialect: unknown dialect "sqlite"`,
+		`kuneiform.includePaths: "shared" is relative but there is no workspace folder`,
+		`kuneiform.maxDiagnostics: -5 is negative`,
+	}, messages)
+}
+
+func TestSettings(t *testing.T) {
+	const uri = "file:///a.kf"
+	h := newLspHandler(1)
+	h.diagnosticsDelay = 0
+	c := dial(t, h)
+	c.settings = map[string]any{
+		"lint":           map[string]any{"rules": map[string]bool{"no-primary-key": false}},
+		"maxDiagnostics": 1,
+	}
+	params := lsp.InitializeParams{}
+	params.Capabilities.Workspace.Configuration = true
+	require.NoError(t, c.call("initialize", params, nil))
+
+	text := "database d;\ntable t { a int }\naction b() public { SELECT * FROM t; }"
+	c.open(uri, text)
+	codes := func(ds []lsp.Diagnostic) []string {
+		res := []string{}
+		for _, d := range ds {
+			res = append(res, d.Code)
+		}
+		return res
+	}
+	assert.Equal(t, []string{"no-primary-key", "missing-view", "select-star"}, codes(c.diagnostics(uri).Diagnostics))
+
+	// The settings are pulled once the client is initialized.
+	c.notify("initialized", struct{}{})
+	req := configurationParams{}
+	c.notification("workspace/configuration", &req)
+	assert.Equal(t, configurationParams{Items: []configurationItem{{Section: "kuneiform"}}}, req)
+	assert.Equal(t, []string{"missing-view"}, codes(c.diagnostics(uri).Diagnostics))
+
+	// Pushed settings are used as they are.
+	c.notify("workspace/didChangeConfiguration", map[string]any{"settings": map[string]any{"kuneiform": map[string]any{
+		"lint":   map[string]any{"rules": map[string]bool{"select-star": false}},
+		"format": map[string]any{"indentWidth": 2, "keywordCase": "lower"},
+	}}})
+	assert.Equal(t, []string{"no-primary-key", "missing-view"}, codes(c.diagnostics(uri).Diagnostics))
+	edits := []lsp.TextEdit{}
+	require.NoError(t, c.call("textDocument/formatting", lsp.DocumentFormattingParams{TextDocument: textDoc(uri)}, &edits))
+	require.Equal(t, 1, len(edits))
+	assert.Equal(t, "database d;\n\ntable t {\n  a int\n}\n\naction b() public {\n  select * from t;\n}\n", edits[0].NewText)
+
+	// Otherwise they are pulled again.
+	c.settings = nil
+	c.notify("workspace/didChangeConfiguration", map[string]any{"settings": nil})
+	c.notification("workspace/configuration", &req)
+	assert.Equal(t, []string{"no-primary-key", "missing-view", "select-star"}, codes(c.diagnostics(uri).Diagnostics))
+}
+
+func TestDialectSetting(t *testing.T) {
+	const uri = "file:///a.kf"
+	h := newLspHandler(1)
+	h.diagnosticsDelay = 0
+	c := connect(t, h)
+
+	c.open(uri, "database d;\nprocedure p() public view {}\n")
+	assert.Empty(t, c.diagnostics(uri).Diagnostics)
+
+	// Procedures came with Kwil 0.8.
+	c.notify("workspace/didChangeConfiguration", map[string]any{"settings": map[string]any{"kuneiform": map[string]any{
+		"dialect": "kwil-0.7",
+	}}})
+	ds := c.diagnostics(uri).Diagnostics
+	require.Len(t, ds, 1)
+	assert.Equal(t, lang.CodeDialect, ds[0].Code)
+	assert.Equal(t, lsp.Range{Start: lsp.Position{Line: 1}, End: lsp.Position{Line: 1, Character: 9}}, ds[0].Range)
+}
+
+func TestInvalidSettings(t *testing.T) {
+	h := newLspHandler(1)
+	c := connect(t, h)
+
+	c.notify("workspace/didChangeConfiguration", map[string]any{"settings": map[string]any{"kuneiform": map[string]any{
+		"format": map[string]any{"keywordCase": "title"},
+	}}})
+	params := lsp.LogMessageParams{}
+	c.notification("window/logMessage", &params)
+	assert.Equal(t, lsp.LogMessageParams{
+		Type:    lsp.MTWarning,
+		Message: `invalid setting err=kuneiform.format.keywordCase: unknown case "title"`,
+	}, params)
+}
@@ -0,0 +1,197 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/require"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+// testClient talks to a handler over an in-memory pipe like an editor would.
+// It keeps its own copy of the open documents to send their full text on edits.
+type testClient struct {
+	t      *testing.T
+	conn   *jsonrpc2.Conn
+	notifs chan *jsonrpc2.Request
+	docs   map[string]*testDoc
+	// settings answer workspace/configuration requests.
+	settings any
+}
+
+type testDoc struct {
+	version int
+	text    string
+}
+
+// dial starts the handler and returns a client which hasn't initialized it yet.
+func dial(t *testing.T, h *lspHandler) *testClient {
+	serverSide, clientSide := net.Pipe()
+	server := serve(context.Background(), serverSide, h)
+	t.Cleanup(func() { server.Close() })
+	return newTestClient(t, clientSide)
+}
+
+// newTestClient returns a client talking to a server on the other end of rwc.
+func newTestClient(t *testing.T, rwc io.ReadWriteCloser) *testClient {
+	ctx := context.Background()
+	c := &testClient{
+		t:      t,
+		notifs: make(chan *jsonrpc2.Request, 1000),
+		docs:   map[string]*testDoc{},
+	}
+	c.conn = jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(rwc, jsonrpc2.VSCodeObjectCodec{}),
+		jsonrpc2.HandlerWithError(func(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			c.notifs <- req
+			if req.Method == "workspace/configuration" {
+				return []any{c.settings}, nil
+			}
+			return nil, nil
+		}))
+	t.Cleanup(func() { c.conn.Close() })
+	return c
+}
+
+// connect is dial followed by initialize.
+func connect(t *testing.T, h *lspHandler) *testClient {
+	c := dial(t, h)
+	require.NoError(t, c.call("initialize", lsp.InitializeParams{}, nil))
+	c.notify("initialized", struct{}{})
+	return c
+}
+
+func (c *testClient) call(method string, params any, result any) error {
+	return c.conn.Call(context.Background(), method, params, result)
+}
+
+func (c *testClient) notify(method string, params any) {
+	require.NoError(c.t, c.conn.Notify(context.Background(), method, params))
+}
+
+func (c *testClient) open(uri string, text string) {
+	c.docs[uri] = &testDoc{version: 1, text: text}
+	c.notify("textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: lsp.DocumentURI(uri), LanguageID: "kuneiform", Version: 1, Text: text},
+	})
+}
+
+func (c *testClient) change(uri string, text string) {
+	d := c.docs[uri]
+	d.version++
+	d.text = text
+	c.notify("textDocument/didChange", lsp.DidChangeTextDocumentParams{
+		TextDocument: lsp.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: lsp.TextDocumentIdentifier{URI: lsp.DocumentURI(uri)},
+			Version:                d.version,
+		},
+		ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: text}},
+	})
+}
+
+// edit replaces a range of a document the way typing in the editor would.
+func (c *testClient) edit(uri string, r lsp.Range, newText string) {
+	text := c.docs[uri].text
+	li := lang.NewLineIndex(text)
+	start := li.Offset(fromPosition(r.Start))
+	end := li.Offset(fromPosition(r.End))
+	c.change(uri, text[:start]+newText+text[end:])
+}
+
+func (c *testClient) close(uri string) {
+	delete(c.docs, uri)
+	c.notify("textDocument/didClose", lsp.DidCloseTextDocumentParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: lsp.DocumentURI(uri)},
+	})
+}
+
+// diagnostics waits for the next diagnostics published for the document.
+func (c *testClient) diagnostics(uri string) lsp.PublishDiagnosticsParams {
+	for {
+		params := lsp.PublishDiagnosticsParams{}
+		c.notification("textDocument/publishDiagnostics", &params)
+		if string(params.URI) == uri {
+			return params
+		}
+	}
+}
+
+// notification waits for the next notification of the method from the
+// server, skipping any others.
+func (c *testClient) notification(method string, params any) {
+	for {
+		select {
+		case n := <-c.notifs:
+			if n.Method == method {
+				require.NoError(c.t, json.Unmarshal(*n.Params, params))
+				return
+			}
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("no %s notification", method)
+		}
+	}
+}
+
+func errorCode(t *testing.T, err error) int64 {
+	rpcErr, ok := err.(*jsonrpc2.Error)
+	require.True(t, ok, "expected a JSON-RPC error, got %v", err)
+	return rpcErr.Code
+}
+
+func textDoc(uri string) lsp.TextDocumentIdentifier {
+	return lsp.TextDocumentIdentifier{URI: lsp.DocumentURI(uri)}
+}
+
+func rng(startLine int, startChar int, endLine int, endChar int) lsp.Range {
+	return lsp.Range{
+		Start: lsp.Position{Line: startLine, Character: startChar},
+		End:   lsp.Position{Line: endLine, Character: endChar},
+	}
+}
+
+// captureLog makes the handler log to the returned buffer, as well as to the
+// client.
+func captureLog(h *lspHandler) *syncBuffer {
+	buf := &syncBuffer{}
+	h.logger = slog.New(newLogHandler(slog.NewTextHandler(buf, nil), h.client))
+	return buf
+}
+
+// syncBuffer is a bytes.Buffer which can be written from several goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,280 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"cmp"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/go-lsp"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+const maxWorkspaceSymbols = 256
+
+type workspaceFolder struct {
+	URI  lsp.DocumentURI `json:"uri"`
+	Name string          `json:"name"`
+}
+
+type initializeParams struct {
+	lsp.InitializeParams
+	WorkspaceFolders []workspaceFolder `json:"workspaceFolders,omitempty"`
+}
+
+// roots returns the workspace folders, or the root for clients which don't support them.
+func (p *initializeParams) roots() []lsp.DocumentURI {
+	res := []lsp.DocumentURI{}
+	for _, f := range p.WorkspaceFolders {
+		res = append(res, f.URI)
+	}
+	if len(res) == 0 && (p.RootURI != "" || p.RootPath != "") {
+		res = append(res, p.Root())
+	}
+	return res
+}
+
+type fileEvent struct {
+	URI  lsp.DocumentURI `json:"uri"`
+	Type int             `json:"type"`
+}
+
+type didChangeWatchedFilesParams struct {
+	Changes []fileEvent `json:"changes"`
+}
+
+const fileDeleted = 3
+
+type documentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           lsp.SymbolKind   `json:"kind"`
+	Range          lsp.Range        `json:"range"`
+	SelectionRange lsp.Range        `json:"selectionRange"`
+	Children       []documentSymbol `json:"children,omitempty"`
+}
+
+// workspaceIndex holds the symbols of every .kf file in the workspace, whether
+// it's open in the editor or not.
+type workspaceIndex struct {
+	mu    sync.RWMutex
+	files map[string][]lsp.SymbolInformation
+	// isOpen tells whether a document is open in the editor, in which case its
+	// symbols come from the editor's text rather than from disk.
+	isOpen func(uri lsp.DocumentURI) bool
+}
+
+func newWorkspaceIndex(isOpen func(uri lsp.DocumentURI) bool) *workspaceIndex {
+	return &workspaceIndex{files: map[string][]lsp.SymbolInformation{}, isOpen: isOpen}
+}
+
+// uriToPath converts a file URI to a path. Percent escapes are decoded, and the
+// slash before a drive letter is dropped, so file:///c%3A/a.kf becomes c:\a.kf.
+func uriToPath(uri lsp.DocumentURI) (string, bool) {
+	u, err := url.Parse(string(uri))
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	p := u.Path
+	if len(p) >= 3 && p[0] == '/' && hasDriveLetter(p[1:]) {
+		p = strings.ToLower(p[1:2]) + p[2:]
+	}
+	return filepath.FromSlash(p), true
+}
+
+func pathToURI(path string) lsp.DocumentURI {
+	p := filepath.ToSlash(path)
+	if hasDriveLetter(p) {
+		p = "/" + strings.ToLower(p[:1]) + p[1:]
+	}
+	u := url.URL{Scheme: "file", Path: p}
+	return lsp.DocumentURI(u.String())
+}
+
+func hasDriveLetter(p string) bool {
+	return len(p) >= 2 && p[1] == ':' && ('a' <= p[0] && p[0] <= 'z' || 'A' <= p[0] && p[0] <= 'Z')
+}
+
+// normalizeURI returns the canonical form of a file URI, so that the spellings
+// of a URI which clients and the file system produce refer to the same file.
+func normalizeURI(uri lsp.DocumentURI) lsp.DocumentURI {
+	if path, ok := uriToPath(uri); ok {
+		return pathToURI(path)
+	}
+	return uri
+}
+
+// addFolder indexes the .kf files under a workspace folder. Unreadable files
+// and directories are skipped rather than failing the whole folder.
+func (wi *workspaceIndex) addFolder(root lsp.DocumentURI) {
+	dir, ok := uriToPath(root)
+	if !ok {
+		return
+	}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".kf") {
+			wi.load(pathToURI(path))
+		}
+		return nil
+	})
+}
+
+// load indexes a file from disk, removing it from the index if it can't be read.
+// Open documents are skipped, so that a background scan doesn't overwrite the
+// symbols of the editor's text. The check is done under the lock update takes,
+// which orders it with the update following a document being opened.
+func (wi *workspaceIndex) load(uri lsp.DocumentURI) {
+	path, ok := uriToPath(uri)
+	if !ok {
+		return
+	}
+	uri = normalizeURI(uri)
+	b, err := os.ReadFile(path)
+	var syms []lsp.SymbolInformation
+	if err == nil {
+		syms = fileSymbols(uri, string(b))
+	}
+
+	wi.mu.Lock()
+	defer wi.mu.Unlock()
+	if wi.isOpen(uri) {
+		return
+	}
+	if err != nil {
+		delete(wi.files, string(uri))
+	} else {
+		wi.files[string(uri)] = syms
+	}
+}
+
+// update indexes the text of a document open in the editor.
+func (wi *workspaceIndex) update(uri lsp.DocumentURI, text string) {
+	uri = normalizeURI(uri)
+	syms := fileSymbols(uri, text)
+
+	wi.mu.Lock()
+	defer wi.mu.Unlock()
+	wi.files[string(uri)] = syms
+}
+
+func fileSymbols(uri lsp.DocumentURI, text string) []lsp.SymbolInformation {
+	li := lang.NewLineIndex(text)
+	res := []lsp.SymbolInformation{}
+	var add func(ss []lang.Symbol, container string)
+	add = func(ss []lang.Symbol, container string) {
+		for _, s := range ss {
+			res = append(res, lsp.SymbolInformation{
+				Name:          s.Name,
+				Kind:          toSymbolKind(s.Kind),
+				Location:      lsp.Location{URI: uri, Range: toRange(li, s.NameRange.Start, s.NameRange.End)},
+				ContainerName: container,
+			})
+			add(s.Children, s.Name)
+		}
+	}
+	add(lang.Symbols(lang.ParseFile(text)), "")
+	return res
+}
+
+func (wi *workspaceIndex) remove(uri lsp.DocumentURI) {
+	wi.mu.Lock()
+	defer wi.mu.Unlock()
+	delete(wi.files, string(normalizeURI(uri)))
+}
+
+// symbols returns the symbols fuzzy matching the query, the best matches first.
+func (wi *workspaceIndex) symbols(query string, limit int) []lsp.SymbolInformation {
+	type match struct {
+		score int
+		sym   lsp.SymbolInformation
+	}
+	ms := []match{}
+	wi.mu.RLock()
+	for _, syms := range wi.files {
+		for _, s := range syms {
+			if score, ok := lang.FuzzyMatch(query, s.Name); ok {
+				ms = append(ms, match{score, s})
+			}
+		}
+	}
+	wi.mu.RUnlock()
+	slices.SortFunc(ms, func(a, b match) int {
+		return cmp.Or(
+			cmp.Compare(b.score, a.score),
+			cmp.Compare(a.sym.Name, b.sym.Name),
+			cmp.Compare(a.sym.Location.URI, b.sym.Location.URI),
+			cmp.Compare(a.sym.Location.Range.Start.Line, b.sym.Location.Range.Start.Line),
+		)
+	})
+
+	if limit <= 0 || limit > maxWorkspaceSymbols {
+		limit = maxWorkspaceSymbols
+	}
+	res := []lsp.SymbolInformation{}
+	for _, m := range ms[:min(limit, len(ms))] {
+		res = append(res, m.sym)
+	}
+	return res
+}
+
+func toSymbolKind(k lang.SymbolKind) lsp.SymbolKind {
+	switch k {
+	case lang.SymDatabase:
+		return lsp.SKNamespace
+	case lang.SymExtension:
+		return lsp.SKPackage
+	case lang.SymTable:
+		return lsp.SKStruct
+	case lang.SymColumn:
+		return lsp.SKField
+	case lang.SymIndex:
+		return lsp.SKKey
+	case lang.SymAction:
+		return lsp.SKFunction
+	default:
+		return lsp.SKVariable
+	}
+}
+
+func documentSymbols(fr *lang.FileRoot) []documentSymbol {
+	li := lang.NewLineIndex(fr.Text())
+	var convert func(ss []lang.Symbol) []documentSymbol
+	convert = func(ss []lang.Symbol) []documentSymbol {
+		res := []documentSymbol{}
+		for _, s := range ss {
+			res = append(res, documentSymbol{
+				Name:           s.Name,
+				Detail:         s.Detail,
+				Kind:           toSymbolKind(s.Kind),
+				Range:          toRange(li, s.Range.Start, s.Range.End),
+				SelectionRange: toRange(li, s.NameRange.Start, s.NameRange.End),
+				Children:       convert(s.Children),
+			})
+		}
+		return res
+	}
+	return convert(lang.Symbols(fr))
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func symbolNames(ss []lsp.SymbolInformation) []string {
+	res := []string{}
+	for _, s := range ss {
+		res = append(res, s.Name)
+	}
+	return res
+}
+
+func TestWorkspaceIndex(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.kf"), []byte("database a;\ntable users { id int primary }"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.kf"), []byte("database b;\naction get_user() public view {}"), 0644)
+	os.WriteFile(filepath.Join(dir, ".git", "c.kf"), []byte("database c;\ntable user_copy {}"), 0644)
+
+	wi := newWorkspaceIndex(func(lsp.DocumentURI) bool { return false })
+	wi.addFolder(pathToURI(dir))
+
+	res := wi.symbols("user", 0)
+	assert.Equal(t, []string{"users", "get_user"}, symbolNames(res))
+	assert.Equal(t, pathToURI(filepath.Join(dir, "a.kf")), res[0].Location.URI)
+	assert.Equal(t, lsp.Range{Start: lsp.Position{Line: 1, Character: 6}, End: lsp.Position{Line: 1, Character: 11}}, res[0].Location.Range)
+	assert.Equal(t, lsp.SKStruct, res[0].Kind)
+
+	res = wi.symbols("id", 0)
+	assert.Equal(t, []string{"id"}, symbolNames(res))
+	assert.Equal(t, "users", res[0].ContainerName)
+
+	wi.update(pathToURI(filepath.Join(dir, "a.kf")), "database a;\ntable people {}")
+	assert.Equal(t, []string{"get_user"}, symbolNames(wi.symbols("user", 0)))
+
+	wi.remove(pathToURI(filepath.Join(dir, "sub", "b.kf")))
+	assert.Empty(t, wi.symbols("user", 0))
+	assert.Equal(t, 2, len(wi.symbols("", 10)))
+	assert.Equal(t, 1, len(wi.symbols("", 1)))
+}
+
+func TestWorkspaceIndexSkipsOpenDocuments(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.kf"), []byte("database a;\ntable on_disk {}"), 0644)
+
+	docs := newSnapshotStore()
+	wi := newWorkspaceIndex(docs.isOpen)
+	docs.set(pathToURI(filepath.Join(dir, "a.kf")), 1, "database a;\ntable in_editor {}")
+	wi.update(pathToURI(filepath.Join(dir, "a.kf")), "database a;\ntable in_editor {}")
+
+	wi.addFolder(pathToURI(dir))
+	assert.Equal(t, []string{"a", "in_editor"}, symbolNames(wi.symbols("", 0)))
+
+	docs.remove(pathToURI(filepath.Join(dir, "a.kf")))
+	wi.load(pathToURI(filepath.Join(dir, "a.kf")))
+	assert.Equal(t, []string{"a", "on_disk"}, symbolNames(wi.symbols("", 0)))
+}
+
+func TestURIs(t *testing.T) {
+	path, ok := uriToPath("file:///c%3A/My%20Schemas/a.kf")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.FromSlash("c:/My Schemas/a.kf"), path)
+	assert.Equal(t, lsp.DocumentURI("file:///c:/My%20Schemas/a.kf"), pathToURI(path))
+	assert.Equal(t, lsp.DocumentURI("file:///c:/My%20Schemas/a.kf"), pathToURI(filepath.FromSlash("C:/My Schemas/a.kf")))
+
+	path, ok = uriToPath("file:///home/a%20b/c.kf")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.FromSlash("/home/a b/c.kf"), path)
+
+	_, ok = uriToPath("untitled:Untitled-1")
+	assert.False(t, ok)
+
+	assert.Equal(t, normalizeURI("file:///C:/a.kf"), normalizeURI("file:///c%3A/a.kf"))
+	assert.Equal(t, lsp.DocumentURI("untitled:Untitled-1"), normalizeURI("untitled:Untitled-1"))
+
+	wi := newWorkspaceIndex(func(lsp.DocumentURI) bool { return false })
+	wi.update("file:///c%3A/a.kf", "database a;\ntable users {}")
+	wi.update("file:///C:/a.kf", "database a;\ntable users {}")
+	res := wi.symbols("users", 0)
+	assert.Equal(t, []string{"users"}, symbolNames(res))
+	assert.Equal(t, lsp.DocumentURI("file:///c:/a.kf"), res[0].Location.URI)
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	res := documentSymbols(lang.ParseFile("database d;\ntable t {\n  a int\n}"))
+	assert.Equal(t, 2, len(res))
+	assert.Equal(t, "t", res[1].Name)
+	assert.Equal(t, lsp.SKStruct, res[1].Kind)
+	assert.Equal(t, lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 3, Character: 1}}, res[1].Range)
+	assert.Equal(t, []documentSymbol{{
+		Name:           "a",
+		Detail:         "int",
+		Kind:           lsp.SKField,
+		Range:          lsp.Range{Start: lsp.Position{Line: 2, Character: 2}, End: lsp.Position{Line: 2, Character: 7}},
+		SelectionRange: lsp.Range{Start: lsp.Position{Line: 2, Character: 2}, End: lsp.Position{Line: 2, Character: 3}},
+		Children:       []documentSymbol{},
+	}}, res[1].Children)
+}
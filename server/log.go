@@ -0,0 +1,203 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// logClient forwards log records and traces to the client once the
+// connection is established.
+type logClient struct {
+	mu    sync.Mutex
+	conn  *jsonrpc2.Conn
+	trace lsp.Trace
+}
+
+func (c *logClient) setConn(conn *jsonrpc2.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn = conn
+}
+
+func (c *logClient) setTrace(trace lsp.Trace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trace = trace
+}
+
+func (c *logClient) notify(method string, params any) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn != nil {
+		conn.Notify(context.Background(), method, params)
+	}
+}
+
+type logTraceParams struct {
+	Message string `json:"message"`
+	Verbose string `json:"verbose,omitempty"`
+}
+
+type setTraceParams struct {
+	Value lsp.Trace `json:"value"`
+}
+
+// logTrace sends a $/logTrace notification if tracing is on. The verbose part
+// is only computed with verbose tracing.
+func (c *logClient) logTrace(message string, verbose func() string) {
+	c.mu.Lock()
+	trace := c.trace
+	c.mu.Unlock()
+	if trace != "messages" && trace != "verbose" {
+		return
+	}
+	params := logTraceParams{Message: message}
+	if trace == "verbose" {
+		params.Verbose = verbose()
+	}
+	c.notify("$/logTrace", params)
+}
+
+// traceJSON formats a message payload for a verbose trace.
+func traceJSON(label string, v any) string {
+	b, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return fmt.Sprintf("%s: %v", label, err)
+	}
+	return fmt.Sprintf("%s: %s", label, b)
+}
+
+// traceRequest traces a handled request, how long it took and its outcome.
+func (c *logClient) traceRequest(req *jsonrpc2.Request, took time.Duration, res any, rpcErr *jsonrpc2.Error) {
+	message := fmt.Sprintf("Sending response '%s - (%s)'. Processing request took %dms", req.Method, req.ID, took.Milliseconds())
+	if rpcErr != nil {
+		message += fmt.Sprintf(". Request failed: %s (%d).", rpcErr.Message, rpcErr.Code)
+	}
+	c.logTrace(message, func() string {
+		if rpcErr != nil {
+			return traceJSON("Error", rpcErr)
+		}
+		if res == nil {
+			return "No result returned."
+		}
+		return traceJSON("Result", res)
+	})
+}
+
+// traceNotification traces a handled notification and how long it took.
+func (c *logClient) traceNotification(req *jsonrpc2.Request, took time.Duration) {
+	c.logTrace(fmt.Sprintf("Received notification '%s' in %dms.", req.Method, took.Milliseconds()), func() string {
+		if req.Params == nil {
+			return "No parameters provided."
+		}
+		return traceJSON("Params", req.Params)
+	})
+}
+
+// logHandler writes log records with a local handler, e.g. to stderr or a log
+// file, and shows those of at least the client level in the client's output
+// with window/logMessage.
+type logHandler struct {
+	local       slog.Handler
+	client      *logClient
+	clientLevel slog.Level
+	// attrs are the formatted attributes added with WithAttrs, and group the
+	// prefix of the keys added after WithGroup.
+	attrs string
+	group string
+}
+
+func newLogHandler(local slog.Handler, client *logClient) *logHandler {
+	return &logHandler{local: local, client: client, clientLevel: slog.LevelInfo}
+}
+
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.clientLevel || h.local.Enabled(ctx, level)
+}
+
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= h.clientLevel {
+		sb := strings.Builder{}
+		sb.WriteString(r.Message)
+		sb.WriteString(h.attrs)
+		r.Attrs(func(a slog.Attr) bool {
+			writeAttr(&sb, h.group, a)
+			return true
+		})
+		h.client.notify("window/logMessage", lsp.LogMessageParams{Type: messageType(r.Level), Message: sb.String()})
+	}
+	if h.local.Enabled(ctx, r.Level) {
+		return h.local.Handle(ctx, r)
+	}
+	return nil
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	sb := strings.Builder{}
+	sb.WriteString(h.attrs)
+	for _, a := range attrs {
+		writeAttr(&sb, h.group, a)
+	}
+	res := *h
+	res.local = h.local.WithAttrs(attrs)
+	res.attrs = sb.String()
+	return &res
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	res := *h
+	res.local = h.local.WithGroup(name)
+	res.group = h.group + name + "."
+	return &res
+}
+
+func writeAttr(sb *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			writeAttr(sb, group, ga)
+		}
+		return
+	}
+	fmt.Fprintf(sb, " %s%s=%v", group, a.Key, a.Value)
+}
+
+func messageType(level slog.Level) lsp.MessageType {
+	switch {
+	case level >= slog.LevelError:
+		return lsp.MTError
+	case level >= slog.LevelWarn:
+		return lsp.MTWarning
+	case level >= slog.LevelInfo:
+		return lsp.Info
+	}
+	return lsp.Log
+}
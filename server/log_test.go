@@ -0,0 +1,70 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogMessage(t *testing.T) {
+	h := newLspHandler(1)
+	log := captureLog(h)
+	c := connect(t, h)
+
+	h.logger.Debug("not for the client", "n", 1)
+	h.logger.With("uri", "file:///a.kf").Warn("slow analysis", "took", "2s")
+	params := lsp.LogMessageParams{}
+	c.notification("window/logMessage", &params)
+	assert.Equal(t, lsp.LogMessageParams{Type: lsp.MTWarning, Message: "slow analysis uri=file:///a.kf took=2s"}, params)
+	assert.Contains(t, log.String(), `level=WARN msg="slow analysis" uri=file:///a.kf took=2s`)
+	assert.NotContains(t, log.String(), "not for the client")
+}
+
+func TestTrace(t *testing.T) {
+	h := newLspHandler(1)
+	c := dial(t, h)
+	require.NoError(t, c.call("initialize", lsp.InitializeParams{Trace: "messages"}, nil))
+	c.notify("initialized", struct{}{})
+
+	params := logTraceParams{}
+	c.notification("$/logTrace", &params)
+	assert.Regexp(t, `^Sending response 'initialize - \(0\)'. Processing request took \d+ms$`, params.Message)
+	assert.Empty(t, params.Verbose)
+	c.notification("$/logTrace", &params)
+	assert.Regexp(t, `^Received notification 'initialized' in \d+ms.$`, params.Message)
+
+	c.notify("$/setTrace", setTraceParams{Value: "verbose"})
+	c.notification("$/logTrace", &params)
+	assert.Equal(t, "Params: {\n    \"value\": \"verbose\"\n}", params.Verbose)
+
+	err := c.call("textDocument/hover", lsp.TextDocumentPositionParams{}, nil)
+	require.Error(t, err)
+	c.notification("$/logTrace", &params)
+	assert.Regexp(t, `^Sending response 'textDocument/hover - \(1\)'. Processing request took \d+ms. Request failed: method not found: textDocument/hover \(-32601\).$`, params.Message)
+	assert.Contains(t, params.Verbose, `"code": -32601`)
+
+	c.notify("$/setTrace", setTraceParams{Value: "off"})
+	require.NoError(t, c.call("workspace/symbol", lsp.WorkspaceSymbolParams{}, nil))
+	c.open("file:///a.kf", "database d;")
+	for n := range c.notifs {
+		assert.NotEqual(t, "$/logTrace", n.Method)
+		if n.Method == "textDocument/publishDiagnostics" {
+			break
+		}
+	}
+}
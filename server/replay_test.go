@@ -0,0 +1,204 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReplay replays the sessions in testdata against the server. The logs
+// are in the format of the VS Code output of the extension with
+// "kuneiform-vscode.trace.server" set to "verbose". Only the requests and
+// notifications sent by the editor are replayed, and the responses to the
+// requests have to match the logged ones. Notifications sent by the server
+// are ignored since their timing isn't deterministic.
+//
+// testdata/sessions has sessions recorded in VS Code. They are checked in as
+// they were recorded and are never edited to follow the server, as a change
+// breaking one changes what clients see. testdata/synthetic has hand-written
+// sessions, which are updated along with the server.
+func TestReplay(t *testing.T) {
+	files := []string{}
+	for _, dir := range []string{"sessions", "synthetic"} {
+		matches, err := filepath.Glob(filepath.Join("testdata", dir, "*.log"))
+		require.NoError(t, err)
+		files = append(files, matches...)
+	}
+	require.NotEmpty(t, files)
+
+	for _, f := range files {
+		t.Run(filepath.Base(filepath.Dir(f))+"/"+filepath.Base(f), func(t *testing.T) {
+			entries, err := readTrace(f)
+			require.NoError(t, err)
+			replay(t, entries)
+		})
+	}
+}
+
+type traceKind int
+
+const (
+	traceRequest traceKind = iota
+	traceNotification
+	traceResponse
+	traceIgnored
+)
+
+// traceEntry is a single message of a trace log.
+type traceEntry struct {
+	line   int
+	kind   traceKind
+	method string
+	id     string
+	// params of requests and notifications and the result of responses; nil
+	// for responses without a result.
+	body json.RawMessage
+	// errCode is the error code of failed requests, 0 otherwise.
+	errCode int64
+}
+
+var (
+	traceHeader  = regexp.MustCompile(`^\[Trace - [^\]]*\] (Sending request|Sending notification|Received response|Received notification|Received request|Sending response) '([^']*)'(.*)$`)
+	traceMethod  = regexp.MustCompile(`^(.*) - \((.*)\)$`)
+	traceFailure = regexp.MustCompile(`Request failed: .* \((-?\d+)\)\.`)
+)
+
+// readTrace parses a VS Code language client trace log.
+func readTrace(path string) ([]traceEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []traceEntry{}
+	// header is the rest of the header line, after the method.
+	header := ""
+	var body *strings.Builder
+	flush := func() error {
+		if len(entries) == 0 || body == nil {
+			return nil
+		}
+		e := &entries[len(entries)-1]
+		text := strings.TrimSpace(body.String())
+		body = nil
+		if m := traceFailure.FindStringSubmatch(header + text); m != nil {
+			e.errCode, _ = strconv.ParseInt(m[1], 10, 64)
+			return nil
+		}
+		for _, prefix := range []string{"Params:", "Result:"} {
+			if rest, ok := strings.CutPrefix(text, prefix); ok {
+				e.body = json.RawMessage(strings.TrimSpace(rest))
+				if !json.Valid(e.body) {
+					return fmt.Errorf("%s:%d: malformed JSON", path, e.line)
+				}
+			}
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		m := traceHeader.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			if body != nil {
+				body.WriteString(scanner.Text())
+				body.WriteString("\n")
+			}
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+
+		e := traceEntry{line: line, method: m[2]}
+		switch m[1] {
+		case "Sending request":
+			e.kind = traceRequest
+		case "Sending notification":
+			e.kind = traceNotification
+		case "Received response":
+			e.kind = traceResponse
+		default:
+			e.kind = traceIgnored
+		}
+		if mm := traceMethod.FindStringSubmatch(e.method); mm != nil {
+			e.method, e.id = mm[1], mm[2]
+		}
+		entries = append(entries, e)
+		header = m[3]
+		body = &strings.Builder{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, flush()
+}
+
+func replay(t *testing.T, entries []traceEntry) {
+	h := newLspHandler(1)
+	h.exit = func(int) {}
+	c := dial(t, h)
+
+	responses := map[string]traceEntry{}
+	for _, e := range entries {
+		if e.kind == traceResponse {
+			responses[e.id] = e
+		}
+	}
+
+	for _, e := range entries {
+		switch e.kind {
+		case traceNotification:
+			c.notify(e.method, params(e.body))
+		case traceRequest:
+			expected, ok := responses[e.id]
+			require.True(t, ok, "line %d: no response to %s", e.line, e.method)
+
+			result := json.RawMessage{}
+			err := c.call(e.method, params(e.body), &result)
+			if expected.errCode != 0 {
+				require.Error(t, err, "line %d: %s", e.line, e.method)
+				assert.Equal(t, expected.errCode, errorCode(t, err), "line %d: %s", e.line, e.method)
+				continue
+			}
+			require.NoError(t, err, "line %d: %s", e.line, e.method)
+			if expected.body == nil {
+				assert.Equal(t, "null", string(result), "line %d: %s", e.line, e.method)
+			} else {
+				assert.JSONEq(t, string(expected.body), string(result), "line %d: %s", e.line, e.method)
+			}
+		}
+	}
+}
+
+// params returns the recorded params as is, or nil if there were none.
+func params(body json.RawMessage) any {
+	if body == nil {
+		return nil
+	}
+	return body
+}
@@ -0,0 +1,175 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sourcegraph/go-lsp"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+type semanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+type semanticTokensFullOptions struct {
+	Delta bool `json:"delta"`
+}
+
+type semanticTokensOptions struct {
+	Legend semanticTokensLegend       `json:"legend"`
+	Range  bool                       `json:"range"`
+	Full   *semanticTokensFullOptions `json:"full"`
+}
+
+type semanticTokensParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+type semanticTokensRangeParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+	Range        lsp.Range                  `json:"range"`
+}
+
+type semanticTokensDeltaParams struct {
+	TextDocument     lsp.TextDocumentIdentifier `json:"textDocument"`
+	PreviousResultID string                     `json:"previousResultId"`
+}
+
+type semanticTokens struct {
+	ResultID string   `json:"resultId,omitempty"`
+	Data     []uint32 `json:"data"`
+}
+
+type semanticTokensEdit struct {
+	Start       uint32   `json:"start"`
+	DeleteCount uint32   `json:"deleteCount"`
+	Data        []uint32 `json:"data,omitempty"`
+}
+
+type semanticTokensDelta struct {
+	ResultID string               `json:"resultId,omitempty"`
+	Edits    []semanticTokensEdit `json:"edits"`
+}
+
+// semanticTokenTypes are the standard types, followed by the index type which
+// the extension contributes as a subtype of variable.
+var semanticTokenTypes = []string{
+	"keyword", "modifier", "namespace", "struct", "property", "parameter", "variable",
+	"function", "type", "operator", "string", "number", "comment", "index",
+}
+
+var semanticTokenTypeIndex = map[lang.HighlightKind]uint32{
+	lang.HlKeyword:   0,
+	lang.HlModifier:  1,
+	lang.HlNamespace: 2,
+	lang.HlTable:     3,
+	lang.HlColumn:    4,
+	lang.HlParameter: 5,
+	lang.HlVariable:  6,
+	lang.HlFunction:  7,
+	lang.HlType:      8,
+	lang.HlOperator:  9,
+	lang.HlString:    10,
+	lang.HlNumber:    11,
+	lang.HlComment:   12,
+	lang.HlIndex:     13,
+}
+
+// semanticTokenModifiers are in the order of the lang.HighlightMod bits.
+var semanticTokenModifiers = []string{"declaration", "readonly", "defaultLibrary"}
+
+var semanticTokensProvider = &semanticTokensOptions{
+	Legend: semanticTokensLegend{
+		TokenTypes:     semanticTokenTypes,
+		TokenModifiers: semanticTokenModifiers,
+	},
+	Range: true,
+	Full:  &semanticTokensFullOptions{Delta: true},
+}
+
+// encodeSemanticTokens encodes the highlights intersecting [start, end) with the
+// relative encoding of the protocol. Tokens spanning several lines, such as
+// block comments, are split since the protocol doesn't allow multiline tokens.
+func encodeSemanticTokens(text string, hls []lang.Highlight, start int, end int) []uint32 {
+	li := lang.NewLineIndex(text)
+	res := []uint32{}
+	prev := lang.Position{}
+
+	for _, h := range hls {
+		if h.End <= start || h.Start >= end {
+			continue
+		}
+		segStart := h.Start
+		for segStart < h.End {
+			segEnd := h.End
+			if nl := strings.IndexByte(text[segStart:h.End], '\n'); nl >= 0 {
+				segEnd = segStart + nl
+			}
+			s := li.Position(segStart)
+			e := li.Position(segEnd)
+			if e.Character > s.Character {
+				deltaChar := s.Character
+				if s.Line == prev.Line {
+					deltaChar -= prev.Character
+				}
+				res = append(res,
+					uint32(s.Line-prev.Line),
+					uint32(deltaChar),
+					uint32(e.Character-s.Character),
+					semanticTokenTypeIndex[h.Kind],
+					uint32(h.Mods))
+				prev = s
+			}
+			segStart = segEnd + 1
+		}
+	}
+	return res
+}
+
+func diffSemanticTokens(old []uint32, new []uint32) []semanticTokensEdit {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	if prefix == len(old) && prefix == len(new) {
+		return []semanticTokensEdit{}
+	}
+	return []semanticTokensEdit{{
+		Start:       uint32(prefix),
+		DeleteCount: uint32(len(old) - prefix - suffix),
+		Data:        new[prefix : len(new)-suffix],
+	}}
+}
+
+func (l *lspHandler) fullSemanticTokens(sn *snapshot) *semanticTokens {
+	data := encodeSemanticTokens(sn.text, lang.Highlights(sn.file()), 0, len(sn.text))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resultSeq++
+	res := &semanticTokens{
+		ResultID: fmt.Sprint(l.resultSeq),
+		Data:     data,
+	}
+	l.semTokens[string(sn.uri)] = res
+	return res
+}
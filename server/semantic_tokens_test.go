@@ -0,0 +1,72 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+func TestEncodeSemanticTokens(t *testing.T) {
+	text := "database d;\n/* a\nb */ table t {}"
+	hls := lang.Highlights(lang.ParseFile(text))
+
+	assert.Equal(t, []uint32{
+		0, 0, 8, 0, 0, // database
+		0, 9, 1, 2, 1, // d
+		1, 0, 4, 12, 0, // "/* a"
+		1, 0, 4, 12, 0, // "b */"
+		0, 5, 5, 0, 0, // table
+		0, 6, 1, 3, 1, // t
+	}, encodeSemanticTokens(text, hls, 0, len(text)))
+
+	assert.Equal(t, []uint32{
+		2, 5, 5, 0, 0,
+		0, 6, 1, 3, 1,
+	}, encodeSemanticTokens(text, hls, 22, len(text)))
+}
+
+func TestSemanticTokenTypes(t *testing.T) {
+	text := "database d;\ntable t {a int, #idx unique(a)}"
+	data := encodeSemanticTokens(text, lang.Highlights(lang.ParseFile(text)), 0, len(text))
+	types := []string{}
+	for i := 3; i < len(data); i += 5 {
+		types = append(types, semanticTokenTypes[data[i]])
+	}
+	assert.Contains(t, types, "index")
+	assert.NotContains(t, types, "variable")
+}
+
+func TestDiffSemanticTokens(t *testing.T) {
+	assert.Equal(t, []semanticTokensEdit{{Start: 2, DeleteCount: 1, Data: []uint32{7, 8}}},
+		diffSemanticTokens([]uint32{1, 2, 3, 4}, []uint32{1, 2, 7, 8, 4}))
+	assert.Equal(t, []semanticTokensEdit{}, diffSemanticTokens([]uint32{1, 2}, []uint32{1, 2}))
+}
+
+func TestSemanticTokensCapability(t *testing.T) {
+	b, err := json.Marshal(serverCapabilities{
+		ServerCapabilities:     lsp.ServerCapabilities{DocumentSymbolProvider: true},
+		SemanticTokensProvider: semanticTokensProvider,
+	})
+	assert.NoError(t, err)
+
+	caps := map[string]any{}
+	assert.NoError(t, json.Unmarshal(b, &caps))
+	assert.Equal(t, true, caps["documentSymbolProvider"])
+	assert.Contains(t, caps, "semanticTokensProvider")
+}
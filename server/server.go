@@ -16,18 +16,109 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
-	"solomatov.me/kuneiform-vscode/lang"
+	"solomatov.me/kuneiform-for-vscode/lang"
 )
 
 type stdioRWC struct{}
 
+// defaultDiagnosticsDelay is how long diagnostics wait for further edits, so
+// that typing doesn't queue up an analysis per keystroke.
+const defaultDiagnosticsDelay = 200 * time.Millisecond
+
+// clientWatchInterval is how often the server checks that the client's process
+// given with --clientProcessId is still running.
+const clientWatchInterval = 3 * time.Second
+
+// lspHandler handles notifications in the order they arrive on the
+// connection's goroutine, which keeps document updates sequential, and runs
+// requests on worker goroutines against the current document snapshots.
 type lspHandler struct {
-	docs map[string]string
+	docs             *snapshotStore
+	index            *workspaceIndex
+	workers          chan struct{}
+	diagnosticsDelay time.Duration
+	logger           *slog.Logger
+	client           *logClient
+	exit             func(code int)
+
+	mu        sync.Mutex
+	state     serverState
+	config    config
+	fileLint  lang.LintConfig
+	root      string
+	pull      bool
+	included  map[string]bool
+	semTokens map[string]*semanticTokens
+	resultSeq int
+	requests  map[jsonrpc2.ID]context.CancelFunc
+	pending   map[string]*time.Timer
+}
+
+// newLspHandler returns a handler logging with the default logger and to the
+// client it's served to.
+func newLspHandler(workers int) *lspHandler {
+	client := &logClient{}
+	docs := newSnapshotStore()
+	return &lspHandler{
+		docs:             docs,
+		index:            newWorkspaceIndex(docs.isOpen),
+		workers:          make(chan struct{}, workers),
+		diagnosticsDelay: defaultDiagnosticsDelay,
+		logger:           slog.New(newLogHandler(slog.Default().Handler(), client)),
+		client:           client,
+		exit:             os.Exit,
+		semTokens:        map[string]*semanticTokens{},
+		requests:         map[jsonrpc2.ID]context.CancelFunc{},
+		pending:          map[string]*time.Timer{},
+		config:           defaultConfig(lang.LintConfig{}),
+		included:         map[string]bool{},
+	}
+}
+
+type serverCapabilities struct {
+	lsp.ServerCapabilities
+	SemanticTokensProvider *semanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+	FoldingRangeProvider   bool                   `json:"foldingRangeProvider,omitempty"`
+	SelectionRangeProvider bool                   `json:"selectionRangeProvider,omitempty"`
+}
+
+type initializeResult struct {
+	Capabilities serverCapabilities `json:"capabilities"`
+}
+
+// Error codes defined by LSP in addition to the JSON-RPC ones.
+const (
+	codeServerNotInitialized = -32002
+	codeRequestCancelled     = -32800
+	codeRequestFailed        = -32803
+)
+
+type serverState int
+
+const (
+	stateUninitialized serverState = iota
+	stateInitialized
+	stateShutdown
+)
+
+type cancelParams struct {
+	ID jsonrpc2.ID `json:"id"`
 }
 
 func (s *stdioRWC) Close() error {
@@ -43,55 +134,680 @@ func (s *stdioRWC) Write(p []byte) (n int, err error) {
 }
 
 func (l *lspHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Notif {
+		start := time.Now()
+		defer l.recoverPanic(req.Method)
+		if err := l.handleNotification(ctx, conn, req); err != nil {
+			l.logger.Error("notification failed", "method", req.Method, "err", err)
+		}
+		l.client.traceNotification(req, time.Since(start))
+		return
+	}
+
+	if err := l.checkState(req.Method); err != nil {
+		conn.ReplyWithError(ctx, req.ID, err)
+		return
+	}
+	if req.Method == "initialize" || req.Method == "shutdown" {
+		l.reply(ctx, conn, req, nil)
+		return
+	}
+	// The snapshot is taken here rather than on the worker, so that the edits
+	// arriving after the request don't leak into its result.
+	sn := l.requestSnapshot(req)
+
+	ctx, cancel := context.WithCancel(ctx)
+	l.mu.Lock()
+	l.requests[req.ID] = cancel
+	l.mu.Unlock()
+
+	go func() {
+		l.workers <- struct{}{}
+		defer func() {
+			<-l.workers
+			l.mu.Lock()
+			delete(l.requests, req.ID)
+			l.mu.Unlock()
+			cancel()
+		}()
+		l.reply(ctx, conn, req, sn)
+	}()
+}
+
+// checkState refuses requests before initialize and after shutdown.
+func (l *lspHandler) checkState(method string) *jsonrpc2.Error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.state == stateUninitialized && method != "initialize":
+		return &jsonrpc2.Error{Code: codeServerNotInitialized, Message: "server isn't initialized"}
+	case l.state != stateUninitialized && method == "initialize":
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "server is already initialized"}
+	case l.state == stateShutdown:
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "server is shutting down"}
+	}
+	return nil
+}
+
+// requestSnapshot returns the snapshot of the document a request is about, or
+// nil if it isn't about one.
+func (l *lspHandler) requestSnapshot(req *jsonrpc2.Request) *snapshot {
+	if req.Params == nil {
+		return l.docs.get("")
+	}
+	if req.Method == "workspace/executeCommand" {
+		params := executeCommandParams{}
+		if json.Unmarshal(*req.Params, &params) != nil {
+			return nil
+		}
+		if uri, ok := params.documentURI(); ok && l.docs.isOpen(uri) {
+			return l.docs.get(uri)
+		}
+		return nil
+	}
+	if !strings.HasPrefix(req.Method, "textDocument/") {
+		return nil
+	}
+	params := lsp.TextDocumentPositionParams{}
+	json.Unmarshal(*req.Params, &params)
+	return l.docs.get(params.TextDocument.URI)
+}
+
+// reply handles a request on the snapshot taken when it arrived and sends the
+// result or the error.
+func (l *lspHandler) reply(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, sn *snapshot) {
+	start := time.Now()
+	res, err := l.call(ctx, req, func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+		return l.handleRequest(ctx, req, sn)
+	})
+	took := time.Since(start)
+	l.logger.Debug("request", "method", req.Method, "id", req.ID, "took", took)
+	l.client.traceRequest(req, took, res, err)
+	if err != nil {
+		conn.ReplyWithError(context.Background(), req.ID, err)
+		return
+	}
+	conn.Reply(ctx, req.ID, res)
+}
+
+// call runs the handler, turning a cancellation, a failure or a panic into the
+// error to reply with.
+func (l *lspHandler) call(ctx context.Context, req *jsonrpc2.Request, handle func(context.Context, *jsonrpc2.Request) (any, error)) (res any, rpcErr *jsonrpc2.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.logPanic(req.Method, r)
+			res = nil
+			rpcErr = &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: fmt.Sprintf("internal error: %v", r)}
+		}
+	}()
+
+	if ctx.Err() != nil {
+		return nil, &jsonrpc2.Error{Code: codeRequestCancelled, Message: "request cancelled"}
+	}
+	res, err := handle(ctx, req)
+	if ctx.Err() != nil {
+		return nil, &jsonrpc2.Error{Code: codeRequestCancelled, Message: "request cancelled"}
+	}
+	if err == nil {
+		return res, nil
+	}
+	if e, ok := err.(*jsonrpc2.Error); ok {
+		return nil, e
+	}
+	return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: err.Error()}
+}
+
+// exitCode is 0 if the client asked the server to shut down before exiting.
+func (l *lspHandler) exitCode() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.state == stateShutdown {
+		return 0
+	}
+	return 1
+}
+
+func (l *lspHandler) recoverPanic(method string) {
+	if r := recover(); r != nil {
+		l.logPanic(method, r)
+	}
+}
+
+func (l *lspHandler) logPanic(method string, r any) {
+	l.logger.Error("panic", "method", method, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+}
+
+// unmarshalParams decodes the parameters of a request, failing with an
+// InvalidParams error if they are missing or malformed.
+func unmarshalParams(req *jsonrpc2.Request, v any) error {
+	if req.Params == nil {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: "missing params"}
+	}
+	if err := json.Unmarshal(*req.Params, v); err != nil {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (l *lspHandler) handleNotification(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) error {
+	if req.Method == "exit" {
+		l.exit(l.exitCode())
+		return nil
+	}
+	l.mu.Lock()
+	state := l.state
+	l.mu.Unlock()
+	if state != stateInitialized {
+		return nil
+	}
+
 	switch req.Method {
-	case "initialize":
-		params := lsp.InitializeParams{}
-		json.Unmarshal(*req.Params, &params)
-		kind := lsp.TDSKFull
-		res := lsp.InitializeResult{
-			Capabilities: lsp.ServerCapabilities{
-				TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
-					Kind: &kind,
-				},
-				DocumentSymbolProvider: true,
-			},
+	case "initialized":
+		l.mu.Lock()
+		pull := l.pull
+		l.mu.Unlock()
+		if pull {
+			go l.pullSettings(conn)
+		}
+	case "workspace/didChangeConfiguration":
+		params := didChangeConfigurationParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		pull := l.pull
+		l.mu.Unlock()
+		switch {
+		case params.Settings.Kuneiform != nil:
+			l.applySettings(conn, params.Settings.Kuneiform)
+		case pull:
+			go l.pullSettings(conn)
+		}
+	case "$/setTrace":
+		params := setTraceParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return err
+		}
+		l.client.setTrace(params.Value)
+	case "$/cancelRequest":
+		params := cancelParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		cancel, ok := l.requests[params.ID]
+		l.mu.Unlock()
+		if ok {
+			cancel()
 		}
-		conn.Reply(ctx, req.ID, &res)
 	case "textDocument/didOpen":
 		params := lsp.DidOpenTextDocumentParams{}
-		json.Unmarshal(*req.Params, &params)
-		l.docs[string(params.TextDocument.URI)] = params.TextDocument.Text
+		if err := unmarshalParams(req, &params); err != nil {
+			return err
+		}
+		sn := l.docs.set(params.TextDocument.URI, params.TextDocument.Version, params.TextDocument.Text)
+		l.index.update(sn.uri, sn.text)
+		l.scheduleDiagnostics(conn, sn, 0)
 	case "textDocument/didChange":
 		params := lsp.DidChangeTextDocumentParams{}
-		json.Unmarshal(*req.Params, &params)
-		if len(params.ContentChanges) != 1 {
-			panic("Should be exactly one change")
+		if err := unmarshalParams(req, &params); err != nil {
+			return err
+		}
+		// The sync kind is full, so the last change holds the whole text.
+		if len(params.ContentChanges) == 0 {
+			return fmt.Errorf("no content changes")
 		}
-		l.docs[string(params.TextDocument.URI)] = params.ContentChanges[0].Text
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		sn := l.docs.set(params.TextDocument.URI, params.TextDocument.Version, text)
+		l.index.update(sn.uri, sn.text)
+		l.scheduleDiagnostics(conn, sn, l.diagnosticsDelay)
 	case "textDocument/didClose":
 		params := lsp.DidCloseTextDocumentParams{}
-		json.Unmarshal(*req.Params, &params)
-		delete(l.docs, string(params.TextDocument.URI))
+		if err := unmarshalParams(req, &params); err != nil {
+			return err
+		}
+		uri := params.TextDocument.URI
+		l.mu.Lock()
+		l.docs.remove(uri)
+		delete(l.semTokens, string(uri))
+		if t, ok := l.pending[string(uri)]; ok {
+			t.Stop()
+			delete(l.pending, string(uri))
+		}
+		conn.Notify(ctx, "textDocument/publishDiagnostics", &lsp.PublishDiagnosticsParams{
+			URI:         uri,
+			Diagnostics: []lsp.Diagnostic{},
+		})
+		l.mu.Unlock()
+		l.index.load(uri)
+	case "workspace/didChangeWatchedFiles":
+		params := didChangeWatchedFilesParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return err
+		}
+		for _, c := range params.Changes {
+			if l.docs.isOpen(c.URI) {
+				continue
+			}
+			if c.Type == fileDeleted {
+				l.index.remove(c.URI)
+			} else {
+				l.index.load(c.URI)
+			}
+		}
+	}
+	return nil
+}
+
+// handleRequest handles a request, reading the document it's about from sn.
+func (l *lspHandler) handleRequest(ctx context.Context, req *jsonrpc2.Request, sn *snapshot) (any, error) {
+	switch req.Method {
+	case "initialize":
+		params := initializeParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		l.client.setTrace(params.Trace)
+		fileLint, err := loadLintConfig(params.Root())
+		if err != nil {
+			l.logger.Warn("invalid lint configuration", "file", lang.LintConfigFile, "err", err)
+		}
+		for _, r := range params.roots() {
+			go l.index.addFolder(r)
+		}
+		l.mu.Lock()
+		l.state = stateInitialized
+		l.fileLint = fileLint
+		l.config = defaultConfig(fileLint)
+		l.root, _ = uriToPath(params.Root())
+		l.pull = params.Capabilities.Workspace.Configuration
+		l.mu.Unlock()
+		kind := lsp.TDSKFull
+		return &initializeResult{
+			Capabilities: serverCapabilities{
+				ServerCapabilities: lsp.ServerCapabilities{
+					TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
+						Kind: &kind,
+					},
+					DocumentSymbolProvider:          true,
+					WorkspaceSymbolProvider:         true,
+					CodeActionProvider:              true,
+					ExecuteCommandProvider:          &lsp.ExecuteCommandOptions{Commands: commands},
+					DocumentFormattingProvider:      true,
+					DocumentRangeFormattingProvider: true,
+					DocumentOnTypeFormattingProvider: &lsp.DocumentOnTypeFormattingOptions{
+						FirstTriggerCharacter: "}",
+						MoreTriggerCharacter:  []string{";"},
+					},
+				},
+				SemanticTokensProvider: semanticTokensProvider,
+				FoldingRangeProvider:   true,
+				SelectionRangeProvider: true,
+			},
+		}, nil
+	case "shutdown":
+		l.mu.Lock()
+		l.state = stateShutdown
+		l.mu.Unlock()
+		return nil, nil
 	case "textDocument/documentSymbol":
 		params := lsp.DocumentSymbolParams{}
-		json.Unmarshal(*req.Params, &params)
-		text := l.docs[string(params.TextDocument.URI)]
-		f := lang.ParseFile(text)
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return documentSymbols(sn.file()), nil
+	case "workspace/symbol":
+		params := lsp.WorkspaceSymbolParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return l.index.symbols(params.Query, params.Limit), nil
+	case "textDocument/semanticTokens/full":
+		params := semanticTokensParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return l.fullSemanticTokens(sn), nil
+	case "textDocument/semanticTokens/full/delta":
+		params := semanticTokensDeltaParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		l.mu.Lock()
+		prev := l.semTokens[string(sn.uri)]
+		l.mu.Unlock()
+		res := l.fullSemanticTokens(sn)
+		if prev == nil || prev.ResultID != params.PreviousResultID {
+			return res, nil
+		}
+		return &semanticTokensDelta{
+			ResultID: res.ResultID,
+			Edits:    diffSemanticTokens(prev.Data, res.Data),
+		}, nil
+	case "textDocument/semanticTokens/range":
+		params := semanticTokensRangeParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		li := lang.NewLineIndex(sn.text)
+		start := li.Offset(fromPosition(params.Range.Start))
+		end := li.Offset(fromPosition(params.Range.End))
+		return &semanticTokens{
+			Data: encodeSemanticTokens(sn.text, lang.Highlights(sn.file()), start, end),
+		}, nil
+	case "textDocument/foldingRange":
+		params := foldingRangeParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return foldingRanges(sn.file()), nil
+	case "textDocument/selectionRange":
+		params := selectionRangeParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return selectionRanges(sn.file(), params.Positions), nil
+	case "textDocument/codeAction":
+		params := lsp.CodeActionParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return codeActions(sn.uri, sn.text, diagnostics(sn.file(), l.currentConfig()), params.Range), nil
+	case "textDocument/formatting":
+		params := lsp.DocumentFormattingParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		edits := []lsp.TextEdit{}
+		res, err := lang.Format(sn.file(), l.currentConfig().formatOptions(params.Options))
+		if err == nil && res != sn.text {
+			edits = append(edits, lsp.TextEdit{
+				Range:   toRange(lang.NewLineIndex(sn.text), 0, len(sn.text)),
+				NewText: res,
+			})
+		}
+		return edits, nil
+	case "textDocument/rangeFormatting":
+		params := lsp.DocumentRangeFormattingParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		li := lang.NewLineIndex(sn.text)
+		start := li.Offset(fromPosition(params.Range.Start))
+		end := li.Offset(fromPosition(params.Range.End))
+		return toTextEdits(li, lang.FormatRange(sn.file(), start, end, l.currentConfig().formatOptions(params.Options))), nil
+	case "workspace/executeCommand":
+		params := executeCommandParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return l.executeCommand(params, sn)
+	case "textDocument/onTypeFormatting":
+		params := lsp.DocumentOnTypeFormattingParams{}
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		li := lang.NewLineIndex(sn.text)
+		offset := li.Offset(fromPosition(params.Position))
+		return toTextEdits(li, lang.FormatRange(sn.file(), offset-1, offset-1, l.currentConfig().formatOptions(params.Options))), nil
+	}
+	return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+}
 
-		fmt.Fprintf(os.Stderr, "File = %#v", f)
+// scheduleDiagnostics publishes the diagnostics of a snapshot after the delay,
+// replacing the one scheduled for a previous version of the document.
+func (l *lspHandler) scheduleDiagnostics(conn *jsonrpc2.Conn, sn *snapshot, delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t, ok := l.pending[string(sn.uri)]; ok {
+		t.Stop()
+	}
+	l.pending[string(sn.uri)] = time.AfterFunc(delay, func() {
+		defer l.recoverPanic("diagnostics")
+		l.publishDiagnostics(conn, sn)
+	})
+}
+
+// publishDiagnostics analyzes the snapshot and publishes the result if the
+// document hasn't changed or been closed in the meantime.
+func (l *lspHandler) publishDiagnostics(conn *jsonrpc2.Conn, sn *snapshot) {
+	cfg := l.currentConfig()
+	li := lang.NewLineIndex(sn.text)
+	diags := []lsp.Diagnostic{}
+	for _, d := range diagnostics(sn.file(), cfg) {
+		if len(diags) == cfg.maxDiagnostics {
+			break
+		}
+		diags = append(diags, toDiagnostic(li, d))
 	}
 
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.docs.get(sn.uri) != sn {
+		return
+	}
+	delete(l.pending, string(sn.uri))
+	conn.Notify(context.Background(), "textDocument/publishDiagnostics", &lsp.PublishDiagnosticsParams{
+		URI:         sn.uri,
+		Diagnostics: diags,
+	})
 }
 
-func main() {
-	fmt.Fprintln(os.Stderr, "Starting")
-	ctx := context.Background()
-	conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(&stdioRWC{}, jsonrpc2.VSCodeObjectCodec{}), &lspHandler{
-		docs: map[string]string{},
+// diagnostics returns the errors, including what the target version of Kwil
+// lacks, and the lint warnings of a document.
+func diagnostics(fr *lang.FileRoot, cfg config) []lang.Diagnostic {
+	res := append(lang.Check(fr), lang.CheckDialect(fr, cfg.dialect)...)
+	return append(res, lang.Lint(fr, cfg.lint)...)
+}
+
+// loadLintConfig reads the lint configuration from the workspace root, enabling
+// all rules if there is none or it's invalid.
+func loadLintConfig(root lsp.DocumentURI) (lang.LintConfig, error) {
+	dir, ok := uriToPath(root)
+	if !ok {
+		return lang.LintConfig{}, nil
+	}
+	b, err := os.ReadFile(filepath.Join(dir, lang.LintConfigFile))
+	if err != nil {
+		return lang.LintConfig{}, nil
+	}
+	cfg, err := lang.ParseLintConfig(b)
+	if err != nil {
+		return lang.LintConfig{}, err
+	}
+	return cfg, nil
+}
+
+func toDiagnostic(li *lang.LineIndex, d lang.Diagnostic) lsp.Diagnostic {
+	return lsp.Diagnostic{
+		Range:    toRange(li, d.Start, d.End),
+		Severity: lsp.DiagnosticSeverity(d.Severity),
+		Code:     d.Code,
+		Source:   "kuneiform",
+		Message:  d.Message,
+	}
+}
+
+func toPosition(p lang.Position) lsp.Position {
+	return lsp.Position{Line: p.Line, Character: p.Character}
+}
+
+func fromPosition(p lsp.Position) lang.Position {
+	return lang.Position{Line: p.Line, Character: p.Character}
+}
+
+func toTextEdits(li *lang.LineIndex, edits []lang.TextEdit) []lsp.TextEdit {
+	res := []lsp.TextEdit{}
+	for _, e := range edits {
+		res = append(res, lsp.TextEdit{
+			Range:   toRange(li, e.Start, e.End),
+			NewText: e.NewText,
+		})
+	}
+	return res
+}
+
+func toRange(li *lang.LineIndex, start int, end int) lsp.Range {
+	return lsp.Range{
+		Start: toPosition(li.Position(start)),
+		End:   toPosition(li.Position(end)),
+	}
+}
+
+// serve runs the handler on a connection with the LSP base protocol framing.
+func serve(ctx context.Context, rwc io.ReadWriteCloser, h *lspHandler) *jsonrpc2.Conn {
+	conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(rwc, jsonrpc2.VSCodeObjectCodec{}), h)
+	h.client.setConn(conn)
+	return conn
+}
+
+// options are the settings given on the command line.
+type options struct {
+	listen    string
+	socket    int
+	pipe      string
+	logFile   string
+	logLevel  slog.Level
+	clientPID int
+	ignored   []string
+}
+
+const usage = "usage: server [--stdio | --listen=host:port | --socket=port | --pipe=path] [--clientProcessId=pid] [--log-file=file] [--log-level=level]"
+
+// parseArgs reads the command line. Arguments the server doesn't define, such
+// as those vscode-languageclient adds for transports it may support in the
+// future, are returned in ignored rather than refused, so that the client can
+// always start the server.
+func parseArgs(args []string) (options, error) {
+	var opts options
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Bool("stdio", false, "communicate over stdin and stdout, the default")
+	fs.StringVar(&opts.listen, "listen", "", "accept clients on `host:port`, each with its own state")
+	fs.IntVar(&opts.socket, "socket", 0, "connect to the client listening on localhost `port`")
+	fs.StringVar(&opts.pipe, "pipe", "", "connect to the client listening on the Unix domain socket at `path`")
+	fs.IntVar(&opts.clientPID, "clientProcessId", 0, "exit when the process with `pid` exits")
+	fs.StringVar(&opts.logFile, "log-file", "", "append the log to `file` instead of stderr")
+	logLevel := fs.String("log-level", "info", "log messages of at least `level`: debug, info, warn or error")
+
+	known, ignored := knownArgs(fs, args)
+	opts.ignored = ignored
+	if err := fs.Parse(known); err != nil {
+		return opts, err
+	}
+	transports := 0
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "stdio", "listen", "socket", "pipe":
+			transports++
+		}
 	})
+	if transports > 1 {
+		return opts, errors.New("only one transport can be given")
+	}
+	if err := opts.logLevel.UnmarshalText([]byte(*logLevel)); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// knownArgs splits the arguments into the flags defined in fs, with their
+// values, and the rest.
+func knownArgs(fs *flag.FlagSet, args []string) (known []string, ignored []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+			ignored = append(ignored, arg)
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		f := fs.Lookup(name)
+		if f == nil {
+			ignored = append(ignored, arg)
+			continue
+		}
+		known = append(known, arg)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !hasValue && !(ok && b.IsBoolFlag()) && i+1 < len(args) {
+			i++
+			known = append(known, args[i])
+		}
+	}
+	return known, ignored
+}
 
-	fmt.Fprintln(os.Stderr, "Started")
-	defer conn.Close()
+// watchProcess calls exited once the process with the pid is gone. The LSP
+// spec asks servers to exit when the client's process does, in case it died
+// without closing the connection.
+func watchProcess(pid int, interval time.Duration, exited func()) {
+	for processAlive(pid) {
+		time.Sleep(interval)
+	}
+	exited()
+}
 
-	<-conn.DisconnectNotify()
+func main() {
+	opts, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var out io.Writer = os.Stderr
+	if opts.logFile != "" {
+		f, err := os.OpenFile(opts.logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		out = f
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: opts.logLevel})))
+	if len(opts.ignored) > 0 {
+		slog.Info("ignoring unknown arguments", "args", opts.ignored)
+	}
+	if opts.clientPID != 0 {
+		go watchProcess(opts.clientPID, clientWatchInterval, func() {
+			slog.Info("client process exited", "pid", opts.clientPID)
+			os.Exit(1)
+		})
+	}
+	os.Exit(run(opts.listen, opts.socket, opts.pipe))
+}
+
+// run serves clients with the transport chosen on the command line and
+// returns the exit code.
+func run(listen string, socket int, pipe string) int {
+	ctx := context.Background()
+	workers := runtime.NumCPU()
+	switch {
+	case listen != "":
+		l, err := net.Listen("tcp", listen)
+		if err != nil {
+			slog.Error("listen failed", "err", err)
+			return 1
+		}
+		slog.Info("listening", "addr", l.Addr())
+		if err := serveListener(ctx, l, workers); err != nil {
+			slog.Error("accept failed", "err", err)
+			return 1
+		}
+		return 0
+	case socket != 0 || pipe != "":
+		network, addr := "tcp", socketAddr(socket)
+		if pipe != "" {
+			network, addr = "unix", pipe
+		}
+		slog.Info("connecting", "network", network, "addr", addr)
+		code, err := connectBack(ctx, network, addr, workers)
+		if err != nil {
+			slog.Error("connect failed", "err", err)
+			return 1
+		}
+		return code
+	default:
+		slog.Info("serving on stdio")
+		// The client went away without the exit notification when this returns.
+		return serveConn(ctx, &stdioRWC{}, workers)
+	}
 }
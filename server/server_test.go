@@ -0,0 +1,359 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequests(t *testing.T) {
+	const uri = "file:///test.kf"
+	tests := []struct {
+		name   string
+		text   string
+		edits  []lsp.TextEdit
+		method string
+		params any
+		expect string
+	}{
+		{
+			name:   "document symbols",
+			text:   "database d;\ntable t {\n  a int\n}",
+			method: "textDocument/documentSymbol",
+			params: lsp.DocumentSymbolParams{TextDocument: textDoc(uri)},
+			expect: `[
+				{"name": "d", "kind": 3, "range": {"start": {"line": 0, "character": 0}, "end": {"line": 0, "character": 11}},
+				 "selectionRange": {"start": {"line": 0, "character": 9}, "end": {"line": 0, "character": 10}}},
+				{"name": "t", "kind": 23, "range": {"start": {"line": 1, "character": 0}, "end": {"line": 3, "character": 1}},
+				 "selectionRange": {"start": {"line": 1, "character": 6}, "end": {"line": 1, "character": 7}},
+				 "children": [{"name": "a", "detail": "int", "kind": 8,
+				   "range": {"start": {"line": 2, "character": 2}, "end": {"line": 2, "character": 7}},
+				   "selectionRange": {"start": {"line": 2, "character": 2}, "end": {"line": 2, "character": 3}}}]}
+			]`,
+		},
+		{
+			name:   "document symbols after an edit",
+			text:   "database d;\ntable t {\n  a int\n}",
+			edits:  []lsp.TextEdit{{Range: rng(1, 6, 1, 7), NewText: "users"}},
+			method: "workspace/symbol",
+			params: lsp.WorkspaceSymbolParams{Query: "usr"},
+			expect: `[{"name": "users", "kind": 23, "location": {"uri": "file:///test.kf",
+				"range": {"start": {"line": 1, "character": 6}, "end": {"line": 1, "character": 11}}}}]`,
+		},
+		{
+			name:   "formatting",
+			text:   "database d;table t {a int}",
+			method: "textDocument/formatting",
+			params: lsp.DocumentFormattingParams{TextDocument: textDoc(uri)},
+			expect: `[{"range": {"start": {"line": 0, "character": 0}, "end": {"line": 0, "character": 26}},
+				"newText": "database d;\n\ntable t {\n    a int\n}\n"}]`,
+		},
+		{
+			name:   "formatting with syntax errors",
+			text:   "database d;\ntable t {a int}\naction a() {",
+			method: "textDocument/formatting",
+			params: lsp.DocumentFormattingParams{TextDocument: textDoc(uri)},
+			expect: `[{"range": {"start": {"line": 0, "character": 0}, "end": {"line": 2, "character": 12}},
+				"newText": "database d;\ntable t {\n    a int\n}\naction a() {"}]`,
+		},
+		{
+			name:   "on type formatting at the start",
+			text:   "database d;",
+			method: "textDocument/onTypeFormatting",
+			params: lsp.DocumentOnTypeFormattingParams{TextDocument: textDoc(uri), Position: lsp.Position{}, Ch: ";"},
+			expect: `[]`,
+		},
+		{
+			name:   "folding ranges",
+			text:   "database d;\naction a() {\n  SELECT 1;\n}",
+			method: "textDocument/foldingRange",
+			params: foldingRangeParams{TextDocument: textDoc(uri)},
+			expect: `[{"startLine": 1, "endLine": 2, "kind": "region"}]`,
+		},
+		{
+			name:   "quick fix",
+			text:   "database d;\naction a() public view {\n  SELECT $x;\n}",
+			method: "textDocument/codeAction",
+			params: lsp.CodeActionParams{TextDocument: textDoc(uri), Range: rng(2, 10, 2, 10)},
+			expect: `[{"title": "Declare '$x' as a parameter of 'a'", "kind": "quickfix", "isPreferred": true,
+				"diagnostics": [{"range": {"start": {"line": 2, "character": 9}, "end": {"line": 2, "character": 11}},
+					"severity": 1, "code": "undefined-variable", "source": "kuneiform", "message": "undefined variable '$x'"}],
+				"edit": {"changes": {"file:///test.kf": [{"range": {"start": {"line": 1, "character": 9}, "end": {"line": 1, "character": 9}}, "newText": "$x"}]}}}]`,
+		},
+		{
+			name:   "generated SQL",
+			text:   "database d;\ntable t {\n  id int primary\n}",
+			method: "workspace/executeCommand",
+			params: lsp.ExecuteCommandParams{Command: "kuneiform.generateSql", Arguments: []any{uri}},
+			expect: `"CREATE SCHEMA IF NOT EXISTS d;\n\nCREATE TABLE d.t (\n    id INT8 PRIMARY KEY\n);\n"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := connect(t, newLspHandler(1))
+			c.open(uri, tc.text)
+			for _, e := range tc.edits {
+				c.edit(uri, e.Range, e.NewText)
+			}
+
+			res := json.RawMessage{}
+			require.NoError(t, c.call(tc.method, tc.params, &res))
+			assert.JSONEq(t, tc.expect, string(res))
+		})
+	}
+}
+
+func TestDiagnosticsFollowEdits(t *testing.T) {
+	const uri = "file:///test.kf"
+	h := newLspHandler(1)
+	h.diagnosticsDelay = 0
+	c := connect(t, h)
+
+	c.open(uri, "database d;\ntable t { id int primary }\naction a() public view { SELECT id FROM t; }")
+	assert.Empty(t, c.diagnostics(uri).Diagnostics)
+
+	c.edit(uri, rng(2, 40, 2, 41), "users")
+	ds := c.diagnostics(uri).Diagnostics
+	require.Equal(t, 1, len(ds))
+	assert.Equal(t, "unknown table 'users'", ds[0].Message)
+	assert.Equal(t, rng(2, 40, 2, 45), ds[0].Range)
+
+	c.close(uri)
+	assert.Empty(t, c.diagnostics(uri).Diagnostics)
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	const uri = "file:///a.kf"
+	c := connect(t, newLspHandler(4))
+	c.open(uri, "database d;\ntable t { id int primary }")
+	c.diagnostics(uri)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				assert.NoError(t, c.call("textDocument/documentSymbol", lsp.DocumentSymbolParams{TextDocument: textDoc(uri)}, nil))
+				assert.NoError(t, c.call("textDocument/semanticTokens/full", semanticTokensParams{TextDocument: textDoc(uri)}, nil))
+				assert.NoError(t, c.call("workspace/symbol", lsp.WorkspaceSymbolParams{Query: "t"}, nil))
+			}
+		}()
+	}
+	for v := 2; v < 50; v++ {
+		c.change(uri, fmt.Sprintf("database d;\ntable t%d { id int primary }", v))
+	}
+	wg.Wait()
+
+	symbols := []documentSymbol{}
+	require.NoError(t, c.call("textDocument/documentSymbol", lsp.DocumentSymbolParams{TextDocument: textDoc(uri)}, &symbols))
+	assert.Equal(t, "t49", symbols[1].Name)
+}
+
+func TestCancelRequest(t *testing.T) {
+	h := newLspHandler(1)
+	c := connect(t, h)
+
+	// Occupy the only worker, so that the request waits until it's cancelled.
+	h.workers <- struct{}{}
+	id := jsonrpc2.ID{Num: 100}
+	w, err := c.conn.DispatchCall(context.Background(), "workspace/symbol", lsp.WorkspaceSymbolParams{}, jsonrpc2.PickID(id))
+	require.NoError(t, err)
+	c.notify("$/cancelRequest", cancelParams{ID: id})
+
+	// Notifications are handled in order, so the cancellation was processed once the document is.
+	c.open("file:///a.kf", "database d;")
+	c.diagnostics("file:///a.kf")
+	<-h.workers
+
+	err = w.Wait(context.Background(), nil)
+	require.Error(t, err)
+	assert.Equal(t, int64(codeRequestCancelled), errorCode(t, err))
+}
+
+func TestRequestSnapshot(t *testing.T) {
+	const uri = "file:///a.kf"
+	h := newLspHandler(1)
+	c := connect(t, h)
+	c.open(uri, "database d;\ntable t{a int}")
+	c.diagnostics(uri)
+
+	// Occupy the only worker, so that the request waits while the document changes.
+	h.workers <- struct{}{}
+	w, err := c.conn.DispatchCall(context.Background(), "textDocument/formatting", lsp.DocumentFormattingParams{TextDocument: textDoc(uri)})
+	require.NoError(t, err)
+	c.change(uri, "database d;\ntable users{a int}")
+	c.diagnostics(uri)
+	<-h.workers
+
+	edits := []lsp.TextEdit{}
+	require.NoError(t, w.Wait(context.Background(), &edits))
+	assert.Equal(t, []lsp.TextEdit{{Range: rng(0, 0, 1, 14), NewText: "database d;\n\ntable t {\n    a int\n}\n"}}, edits)
+}
+
+func TestDebouncedDiagnostics(t *testing.T) {
+	const uri = "file:///a.kf"
+	h := newLspHandler(1)
+	h.diagnosticsDelay = 100 * time.Millisecond
+	c := connect(t, h)
+
+	c.open(uri, "database d;")
+	assert.Empty(t, c.diagnostics(uri).Diagnostics)
+
+	for v := 2; v < 10; v++ {
+		c.change(uri, "database d;\naction a() { SELECT * FROM t; }")
+	}
+	c.change(uri, "database d;\naction a() {")
+	ds := c.diagnostics(uri).Diagnostics
+	assert.Equal(t, 1, len(ds))
+	assert.Equal(t, "expected '}'", ds[0].Message)
+
+	select {
+	case n := <-c.notifs:
+		t.Fatalf("unexpected notification %s", n.Method)
+	case <-time.After(2 * h.diagnosticsDelay):
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	h := newLspHandler(1)
+	exitCode := make(chan int, 1)
+	h.exit = func(code int) { exitCode <- code }
+	c := dial(t, h)
+
+	err := c.call("workspace/symbol", lsp.WorkspaceSymbolParams{}, nil)
+	assert.Equal(t, int64(codeServerNotInitialized), errorCode(t, err))
+
+	require.NoError(t, c.call("initialize", lsp.InitializeParams{}, nil))
+	c.notify("initialized", struct{}{})
+	err = c.call("initialize", lsp.InitializeParams{}, nil)
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidRequest), errorCode(t, err))
+
+	err = c.call("textDocument/hover", lsp.TextDocumentPositionParams{}, nil)
+	assert.Equal(t, int64(jsonrpc2.CodeMethodNotFound), errorCode(t, err))
+	err = c.call("workspace/symbol", "query", nil)
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), errorCode(t, err))
+	err = c.call("workspace/symbol", nil, nil)
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), errorCode(t, err))
+
+	require.NoError(t, c.call("shutdown", nil, nil))
+	err = c.call("workspace/symbol", lsp.WorkspaceSymbolParams{}, nil)
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidRequest), errorCode(t, err))
+
+	c.notify("exit", nil)
+	assert.Equal(t, 0, <-exitCode)
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	h := newLspHandler(1)
+	exitCode := make(chan int, 1)
+	h.exit = func(code int) { exitCode <- code }
+	c := connect(t, h)
+
+	c.notify("exit", nil)
+	assert.Equal(t, 1, <-exitCode)
+}
+
+func TestMalformedNotifications(t *testing.T) {
+	const uri = "file:///a.kf"
+	h := newLspHandler(1)
+	log := captureLog(h)
+	c := connect(t, h)
+
+	c.notify("textDocument/didChange", "garbage")
+	c.notify("textDocument/didChange", lsp.DidChangeTextDocumentParams{})
+	c.open(uri, "database d;")
+	c.diagnostics(uri)
+
+	assert.Contains(t, log.String(), "json: cannot unmarshal string")
+	assert.Contains(t, log.String(), `msg="notification failed" method=textDocument/didChange err="no content changes"`)
+}
+
+func TestPanicRecovery(t *testing.T) {
+	h := newLspHandler(1)
+	log := captureLog(h)
+
+	_, err := h.call(context.Background(), &jsonrpc2.Request{Method: "test"}, func(context.Context, *jsonrpc2.Request) (any, error) {
+		panic("boom")
+	})
+	assert.Equal(t, int64(jsonrpc2.CodeInternalError), err.Code)
+	assert.Equal(t, "internal error: boom", err.Message)
+	assert.Contains(t, log.String(), `msg=panic method=test panic=boom stack="goroutine`)
+}
+
+func TestGenerateSQLErrors(t *testing.T) {
+	c := connect(t, newLspHandler(1))
+	c.open("file:///a.kf", "database d;\ntable t { at timestamp }")
+
+	err := c.call("workspace/executeCommand", lsp.ExecuteCommandParams{Command: "kuneiform.generateSql", Arguments: []any{"file:///a.kf"}}, nil)
+	require.Error(t, err)
+	assert.Equal(t, "jsonrpc2: code -32803 message: can't generate SQL, the schema has errors: 2:14: unknown type 'timestamp'", err.Error())
+
+	c.open("file:///b.kf", "database d;\ntable t { id int primary }\naction a() public { INSERT INTO t (id) VALUES (@nonce); }")
+	sql := ""
+	require.NoError(t, c.call("workspace/executeCommand", lsp.ExecuteCommandParams{Command: "kuneiform.generateSql", Arguments: []any{"file:///b.kf"}}, &sql))
+	assert.Equal(t, "-- 3:48: unknown context variable '@nonce'\n\nCREATE SCHEMA IF NOT EXISTS d;\n\nCREATE TABLE d.t (\n    id INT8 PRIMARY KEY\n);\n", sql)
+
+	err = c.call("workspace/executeCommand", lsp.ExecuteCommandParams{Command: "kuneiform.generateSql"}, nil)
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), errorCode(t, err))
+	err = c.call("workspace/executeCommand", lsp.ExecuteCommandParams{Command: "kuneiform.nothing"}, nil)
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), errorCode(t, err))
+}
+
+func TestParseArgs(t *testing.T) {
+	// The arguments vscode-languageclient 9 starts the server with for the
+	// stdio transport.
+	opts, err := parseArgs([]string{"--stdio", "--clientProcessId=1234"})
+	require.NoError(t, err)
+	assert.Equal(t, options{clientPID: 1234, logLevel: slog.LevelInfo}, opts)
+
+	opts, err = parseArgs([]string{"--node-ipc", "--listen", "127.0.0.1:9000", "-log-level=debug", "extra"})
+	require.NoError(t, err)
+	assert.Equal(t, options{listen: "127.0.0.1:9000", logLevel: slog.LevelDebug, ignored: []string{"--node-ipc", "extra"}}, opts)
+
+	_, err = parseArgs([]string{"--stdio", "--socket=5000"})
+	assert.Error(t, err)
+	_, err = parseArgs([]string{"--log-level=loud"})
+	assert.Error(t, err)
+	_, err = parseArgs([]string{"--socket=port"})
+	assert.Error(t, err)
+}
+
+func TestWatchProcess(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	require.NoError(t, cmd.Run())
+	exited := make(chan struct{})
+	go watchProcess(cmd.Process.Pid, time.Millisecond, func() { close(exited) })
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the exit of the process wasn't noticed")
+	}
+
+	assert.True(t, processAlive(os.Getpid()))
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"sync"
+
+	"github.com/sourcegraph/go-lsp"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+// snapshot is an immutable version of an open document. Requests work on the
+// snapshot current when they arrive, so edits arriving after them don't affect
+// them even if they wait for a worker.
+type snapshot struct {
+	uri     lsp.DocumentURI
+	version int
+	text    string
+
+	once sync.Once
+	fr   *lang.FileRoot
+}
+
+// file returns the syntax tree, parsing the text on first use.
+func (s *snapshot) file() *lang.FileRoot {
+	s.once.Do(func() {
+		s.fr = lang.ParseFile(s.text)
+	})
+	return s.fr
+}
+
+// snapshotStore holds the open documents, keyed by normalized URI.
+type snapshotStore struct {
+	mu   sync.RWMutex
+	docs map[string]*snapshot
+}
+
+func newSnapshotStore() *snapshotStore {
+	return &snapshotStore{docs: map[string]*snapshot{}}
+}
+
+// get returns the current snapshot of a document, or an empty one if it isn't open.
+func (s *snapshotStore) get(uri lsp.DocumentURI) *snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sn, ok := s.docs[string(normalizeURI(uri))]; ok {
+		return sn
+	}
+	return &snapshot{uri: uri}
+}
+
+func (s *snapshotStore) isOpen(uri lsp.DocumentURI) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.docs[string(normalizeURI(uri))]
+	return ok
+}
+
+// all returns the current snapshots of the open documents.
+func (s *snapshotStore) all() []*snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []*snapshot{}
+	for _, sn := range s.docs {
+		res = append(res, sn)
+	}
+	return res
+}
+
+func (s *snapshotStore) set(uri lsp.DocumentURI, version int, text string) *snapshot {
+	sn := &snapshot{uri: uri, version: version, text: text}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[string(normalizeURI(uri))] = sn
+	return sn
+}
+
+func (s *snapshotStore) remove(uri lsp.DocumentURI) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.docs, string(normalizeURI(uri)))
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"github.com/sourcegraph/go-lsp"
+	"solomatov.me/kuneiform-for-vscode/lang"
+)
+
+type foldingRangeParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+type foldingRange struct {
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+	Kind      string `json:"kind,omitempty"`
+}
+
+type selectionRangeParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+	Positions    []lsp.Position             `json:"positions"`
+}
+
+type selectionRange struct {
+	Range  lsp.Range       `json:"range"`
+	Parent *selectionRange `json:"parent,omitempty"`
+}
+
+func foldingRanges(fr *lang.FileRoot) []foldingRange {
+	res := []foldingRange{}
+	for _, f := range lang.FoldingRanges(fr, lang.NewLineIndex(fr.Text())) {
+		res = append(res, foldingRange{
+			StartLine: f.StartLine,
+			EndLine:   f.EndLine,
+			Kind:      string(f.Kind),
+		})
+	}
+	return res
+}
+
+// selectionRanges returns a chain of ranges from the innermost to the whole
+// file for every requested position, as the protocol requires one result per position.
+func selectionRanges(fr *lang.FileRoot, positions []lsp.Position) []*selectionRange {
+	li := lang.NewLineIndex(fr.Text())
+
+	res := []*selectionRange{}
+	for _, p := range positions {
+		rs := lang.SelectionRanges(fr, li.Offset(fromPosition(p)))
+		var sr *selectionRange
+		for i := len(rs) - 1; i >= 0; i-- {
+			sr = &selectionRange{
+				Range:  toRange(li, rs[i].Start, rs[i].End),
+				Parent: sr,
+			}
+		}
+		if sr == nil {
+			sr = &selectionRange{Range: lsp.Range{Start: p, End: p}}
+		}
+		res = append(res, sr)
+	}
+	return res
+}
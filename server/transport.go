@@ -0,0 +1,70 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+)
+
+// serveConn runs a new handler on the connection until the client goes away
+// and returns the code the server should exit with.
+func serveConn(ctx context.Context, rwc io.ReadWriteCloser, workers int) int {
+	h := newLspHandler(workers)
+	conn := serve(ctx, rwc, h)
+	<-conn.DisconnectNotify()
+	return h.exitCode()
+}
+
+// connectBack connects to a client which listens for the server, the way
+// vscode-languageclient does for the socket and pipe transports.
+func connectBack(ctx context.Context, network string, addr string, workers int) (int, error) {
+	c, err := net.Dial(network, addr)
+	if err != nil {
+		return 0, err
+	}
+	return serveConn(ctx, c, workers), nil
+}
+
+// serveListener serves every accepted connection with its own handler until
+// the listener is closed. The exit notification only closes the connection it
+// arrives on, so one client can't stop the server for the others.
+func serveListener(ctx context.Context, l net.Listener, workers int) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			slog.Info("client connected", "addr", c.RemoteAddr())
+			h := newLspHandler(workers)
+			h.exit = func(int) { c.Close() }
+			conn := serve(ctx, c, h)
+			<-conn.DisconnectNotify()
+			slog.Info("client disconnected", "addr", c.RemoteAddr())
+		}()
+	}
+}
+
+// socketAddr is the address of the port vscode-languageclient listens on.
+func socketAddr(port int) string {
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
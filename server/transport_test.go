@@ -0,0 +1,85 @@
+// Copyright 2024 kuneiform-for-vscode contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() { done <- serveListener(context.Background(), l, 1) }()
+
+	clients := []*testClient{}
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		client := newTestClient(t, c)
+		require.NoError(t, client.call("initialize", lsp.InitializeParams{}, nil))
+		client.notify("initialized", struct{}{})
+		clients = append(clients, client)
+	}
+
+	// Every client has its own documents.
+	clients[0].open("file:///a.kf", "database d;\ntable users { id int primary }")
+	clients[0].diagnostics("file:///a.kf")
+	symbols := []lsp.SymbolInformation{}
+	require.NoError(t, clients[0].call("workspace/symbol", lsp.WorkspaceSymbolParams{Query: "users"}, &symbols))
+	assert.Equal(t, 1, len(symbols))
+	require.NoError(t, clients[1].call("workspace/symbol", lsp.WorkspaceSymbolParams{Query: "users"}, &symbols))
+	assert.Empty(t, symbols)
+
+	// Exit only disconnects the client which sent it.
+	clients[0].notify("exit", nil)
+	<-clients[0].conn.DisconnectNotify()
+	assert.NoError(t, clients[1].call("workspace/symbol", lsp.WorkspaceSymbolParams{}, nil))
+
+	l.Close()
+	assert.NoError(t, <-done)
+}
+
+func TestConnectBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kf.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	type result struct {
+		code int
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		code, err := connectBack(context.Background(), "unix", path, 1)
+		done <- result{code, err}
+	}()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	c := newTestClient(t, conn)
+	require.NoError(t, c.call("initialize", lsp.InitializeParams{}, nil))
+	require.NoError(t, c.call("shutdown", nil, nil))
+	conn.Close()
+
+	assert.Equal(t, result{code: 0}, <-done)
+}
@@ -15,18 +15,39 @@ import * as vscode from 'vscode';
 import * as path from 'path'
 import * as lc from 'vscode-languageclient/node'
 
-export function activate(_context: vscode.ExtensionContext) {
+export function activate(context: vscode.ExtensionContext) {
     let serverPath = path.join(__dirname, '..', 'server', 'server');
     let serverOpts: lc.ServerOptions = {
         command: serverPath,
         transport: lc.TransportKind.stdio
     };
     let clientOpts: lc.LanguageClientOptions = {
-        documentSelector: [{ scheme: 'file', language: 'kuneiform' }]
+        documentSelector: [{ scheme: 'file', language: 'kuneiform' }],
+        synchronize: {
+            configurationSection: 'kuneiform',
+            fileEvents: vscode.workspace.createFileSystemWatcher('**/*.kf')
+        }
     };
 
     let client = new lc.LanguageClient('kuneiform-vscode', 'kuneiform-vscode', serverOpts, clientOpts);
     client.start();
+
+    context.subscriptions.push(vscode.commands.registerCommand('kuneiform.showGeneratedSql', async () => {
+        let editor = vscode.window.activeTextEditor;
+        if (!editor || editor.document.languageId !== 'kuneiform') {
+            return;
+        }
+        try {
+            let sql: string = await client.sendRequest(lc.ExecuteCommandRequest.type, {
+                command: 'kuneiform.generateSql',
+                arguments: [editor.document.uri.toString()]
+            });
+            let doc = await vscode.workspace.openTextDocument({ language: 'sql', content: sql });
+            await vscode.window.showTextDocument(doc, vscode.ViewColumn.Beside);
+        } catch (e) {
+            vscode.window.showErrorMessage(e instanceof Error ? e.message : String(e));
+        }
+    }));
 }
 
 export function deactivate() { }